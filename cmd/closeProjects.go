@@ -18,7 +18,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 )
 
@@ -34,21 +33,29 @@ var closeProjectsCmd = &cobra.Command{
 Closes the list or specified projects. A project can be specified as either
 the name of the project or as its UUID.
 `,
-	Run: func(cmd *cobra.Command, args []string) {
-		projects := gns3.Connect().Projects()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		projects := ctl.Projects()
+		var errs []error
 		for _, id := range args {
-			p, err := projects.Get(id)
+			p, err := projects.GetContext(cmd.Context(), id)
 			if err != nil {
 				fmt.Printf("ERROR: %s: %v\n", id, err)
+				errs = append(errs, err)
 			} else {
-				_, err = projects.Close(p.ProjectId)
+				_, err = projects.CloseContext(cmd.Context(), p.ProjectId)
 				if err == nil {
 					fmt.Println(p.ProjectId)
 				} else {
 					fmt.Printf("ERROR: %s: %v\n", id, err)
+					errs = append(errs, err)
 				}
 			}
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
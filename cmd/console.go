@@ -0,0 +1,164 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// consoleCmd represents the console command
+//
+//nolint:exhaustruct
+var consoleCmd = &cobra.Command{
+	Use:   "console [flags] NODE",
+	Short: "Connect to the console of a node",
+	Long: `
+Connects the terminal to the telnet console of a node, which must be
+started. Keys are passed to the node as they are typed until the escape
+character, ^] unless changed by --escape, is typed to detach. With --log
+the output of the node is also written to a file.
+
+  gns3ctl console -p lab r1 --log r1.log
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		escapeFlag, _ := cmd.Flags().GetString("escape")
+		escape, err := parseEscape(escapeFlag)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if logFile, _ := cmd.Flags().GetString("log"); logFile != "" {
+			log, err := os.Create(logFile)
+			if err != nil {
+				return fmt.Errorf("open log file: %w", err)
+			}
+			defer log.Close()
+			out = io.MultiWriter(os.Stdout, log)
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		console, err := ctl.Nodes(project.ProjectId).ConsoleContext(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		defer console.Close()
+		go func() {
+			<-cmd.Context().Done()
+			console.Close()
+		}()
+
+		fmt.Fprintf(os.Stderr, "Connected to %s (%s).\r\n", args[0], console.RemoteAddr())
+		if escape >= 0 {
+			fmt.Fprintf(os.Stderr, "Escape character is '%s'.\r\n", escapeFlag)
+		}
+		fd := int(os.Stdin.Fd())
+		if isTerminal(fd) {
+			restore, err := makeRaw(fd)
+			if err != nil {
+				return fmt.Errorf("set terminal to raw mode: %w", err)
+			}
+			defer func() { _ = restore() }()
+		}
+
+		// the session ends when either the node or the user is done
+		done := make(chan error, 2)
+		go func() {
+			_, err := io.Copy(out, console)
+			done <- err
+		}()
+		go func() {
+			done <- sendKeys(console, os.Stdin, escape)
+		}()
+		err = <-done
+		fmt.Fprint(os.Stderr, "\r\nConnection closed.\r\n")
+		if err != nil && cmd.Context().Err() == nil && !errors.Is(err, io.EOF) && !errors.Is(err, errDetached) {
+			return fmt.Errorf("console: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(consoleCmd)
+	consoleCmd.Flags().String("log", "", "also write the output of the node to this file")
+	consoleCmd.Flags().String("escape", "^]", "character typed to detach, as ^X, or none")
+}
+
+var errDetached = errors.New("detached")
+
+// parseEscape returns the byte of an escape character given as ^X, or a
+// single character, or -1 for none.
+func parseEscape(escape string) (int, error) {
+	switch {
+	case escape == "none":
+		return -1, nil
+	case len(escape) == 2 && escape[0] == '^' && escape[1] >= '?' && escape[1] <= '_':
+		return int(escape[1]) ^ 0x40, nil
+	case len(escape) == 2 && escape[0] == '^' && escape[1] >= 'a' && escape[1] <= 'z':
+		return int(strings.ToUpper(escape[1:])[0]) ^ 0x40, nil
+	case len(escape) == 1:
+		return int(escape[0]), nil
+	}
+	return 0, &usageError{err: fmt.Errorf("invalid escape character '%s', expected ^X, a single character or none", escape)}
+}
+
+// sendKeys copies the keys typed to the console until the escape character
+// is typed, when errDetached is returned, or in ends.
+func sendKeys(console io.Writer, in io.Reader, escape int) error {
+	buf := make([]byte, 1024)
+	for {
+		count, err := in.Read(buf)
+		data := buf[:count]
+		detach := false
+		if escape >= 0 {
+			if i := bytes.IndexByte(data, byte(escape)); i >= 0 {
+				data, detach = data[:i], true
+			}
+		}
+		if len(data) > 0 {
+			if _, werr := console.Write(data); werr != nil {
+				return werr
+			}
+		}
+		if detach {
+			return errDetached
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// cpCmd represents the cp command
+//
+//nolint:exhaustruct
+var cpCmd = &cobra.Command{
+	Use:   "cp [flags] NODE:PATH LOCAL | LOCAL NODE:PATH",
+	Short: "Copy files to and from the directory of a node",
+	Long: `
+Copies a file between the local machine and the directory of a node, through
+the controller, so the controller need not be on the local machine. The PATH
+of the file is relative to the node's directory and LOCAL may be a directory,
+to keep the name of the file, or - for stdin or stdout.
+
+  gns3ctl cp -p lab pc1:startup.vpc ./pc1.vpc
+  gns3ctl cp -p lab ./interfaces alpine-1:etc/network/interfaces
+`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		srcNode, srcPath, srcRemote := splitNodePath(args[0])
+		dstNode, dstPath, dstRemote := splitNodePath(args[1])
+		if srcRemote == dstRemote {
+			return &usageError{err: errors.New("exactly one of the source and destination must be given as NODE:PATH")}
+		}
+		if (srcRemote && srcPath == "") || (srcPath == "-" && (dstPath == "" || strings.HasSuffix(dstPath, "/"))) {
+			return &usageError{err: errors.New("the PATH of the file on the node must be given")}
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		nctl := ctl.Nodes(project.ProjectId)
+
+		if srcRemote {
+			data, err := nctl.ReadFileContext(cmd.Context(), srcNode, srcPath)
+			if err != nil {
+				return fmt.Errorf("read '%s': %w", args[0], err)
+			}
+			if dstPath == "-" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if info, err := os.Stat(dstPath); err == nil && info.IsDir() {
+				dstPath = filepath.Join(dstPath, path.Base(srcPath))
+			}
+			return os.WriteFile(dstPath, data, 0644)
+		}
+
+		var data []byte
+		if srcPath == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(srcPath)
+		}
+		if err != nil {
+			return fmt.Errorf("read '%s': %w", srcPath, err)
+		}
+		if dstPath == "" || strings.HasSuffix(dstPath, "/") {
+			dstPath += filepath.Base(srcPath)
+		}
+		if err := nctl.WriteFileContext(cmd.Context(), dstNode, dstPath, data); err != nil {
+			return fmt.Errorf("write '%s': %w", args[1], err)
+		}
+		return nil
+	},
+}
+
+// splitNodePath splits NODE:PATH into the node and the path, returning
+// false for a local path.
+func splitNodePath(arg string) (string, string, bool) {
+	node, file, ok := strings.Cut(arg, ":")
+	if !ok || node == "" || strings.ContainsAny(node, `/\`) {
+		return "", arg, false
+	}
+	return node, file, true
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+}
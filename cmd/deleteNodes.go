@@ -0,0 +1,70 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// deleteNodesCmd represents the delete nodes command
+//
+//nolint:exhaustruct
+var deleteNodesCmd = &cobra.Command{
+	Use:     "nodes [flags] NODE [NODE...]",
+	Aliases: []string{"node", "no"},
+	Short:   "Delete the named nodes",
+	Long: `
+Delete the list of named nodes, along with their links. A node can be
+specified either by the name or the UUID of the node.
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+
+		nodes := ctl.Nodes(project.ProjectId)
+		var errs []error
+		for _, id := range args {
+			uuid, err := nodes.DeleteContext(cmd.Context(), id)
+			if err == nil {
+				fmt.Println(uuid)
+			} else if !errors.Is(err, gns3.ErrNotFound) || !viper.GetBool("ignore-not-found") {
+				fmt.Printf("ERROR: %s: %v\n", id, err)
+				errs = append(errs, err)
+			}
+		}
+		return collectErrors(errs, len(args))
+	},
+}
+
+func init() {
+	deleteCmd.AddCommand(deleteNodesCmd)
+}
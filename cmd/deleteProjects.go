@@ -36,16 +36,23 @@ Delete the list of named projects. A project can be specified either by the
 name or the UUID of the project.
 `,
 	Args: cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		projects := gns3.Connect().Projects()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		projects := ctl.Projects()
+		var errs []error
 		for _, id := range args {
-			uuid, err := projects.Delete(id)
+			uuid, err := projects.DeleteContext(cmd.Context(), id)
 			if err == nil {
 				fmt.Println(uuid)
 			} else if !errors.Is(err, gns3.ErrNotFound) || !viper.GetBool("ignore-not-found") {
 				fmt.Printf("ERROR: %s: %v\n", id, err)
+				errs = append(errs, err)
 			}
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
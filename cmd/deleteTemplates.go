@@ -36,16 +36,23 @@ Delete the list of named templates. A template can be specified either by the
 name or the UUID of the template.
 `,
 	Args: cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		templates := gns3.Connect().Templates()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		templates := ctl.Templates()
+		var errs []error
 		for _, id := range args {
-			uuid, err := templates.Delete(id)
+			uuid, err := templates.DeleteContext(cmd.Context(), id)
 			if err == nil {
 				fmt.Println(uuid)
 			} else if !errors.Is(err, gns3.ErrNotFound) || !viper.GetBool("ignore-not-found") {
 				fmt.Printf("ERROR: %s: %v\n", id, err)
+				errs = append(errs, err)
 			}
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+//
+//nolint:exhaustruct
+var diffCmd = &cobra.Command{
+	Use:     "diff [flags] FILE|DIR|- [FILE|DIR|-...]",
+	Aliases: []string{"plan"},
+	Short:   "Display the changes load would make for a network document",
+	Long: `
+Compares a YAML network document to the project of the same name on the GNS3
+server and displays the templates, nodes and links that would be created (+),
+updated (~), replaced (-/+) or deleted (-) by loading it. Nothing is changed
+on the server.
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prune, _ := cmd.Flags().GetBool("prune")
+		layout, _ := cmd.Flags().GetString("layout")
+		opts := &loadOptions{dryRun: true, prune: prune, layout: layout}
+
+		values, err := renderValues(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		return forEachNetwork(args, values, func(source string, network *gns3.Network) error {
+			_, _, err := loadNetwork(cmd.Context(), ctl, network, opts)
+			return err
+		}, nil)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	addRenderFlags(diffCmd)
+	diffCmd.Flags().Bool("prune", false, "include nodes in the project that are not in the network document")
+	diffCmd.Flags().String("layout", "", "layout for nodes without coordinates, overriding the document. One of auto, layered, force, grid, none")
+}
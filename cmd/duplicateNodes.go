@@ -0,0 +1,77 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// duplicateNodesCmd represents the duplicate nodes command
+//
+//nolint:exhaustruct
+var duplicateNodesCmd = &cobra.Command{
+	Use:     "nodes [flags] NODE [NODE...]",
+	Aliases: []string{"no", "node"},
+	Short:   "Duplicate the specified nodes",
+	Long: `
+Creates a copy of each of the specified nodes, including its disks and
+configuration but not its links. The copy is placed --dx and --dy from the
+original and named by the server after it, for example PC1 is copied to PC2.
+Nodes must be stopped to be duplicated.
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		dx, _ := cmd.Flags().GetInt("dx")
+		dy, _ := cmd.Flags().GetInt("dy")
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+
+		nodes := ctl.Nodes(project.ProjectId)
+		var errs []error
+		for _, no := range args {
+			node, err := nodes.GetContext(cmd.Context(), no)
+			if err == nil {
+				node, err = nodes.DuplicateContext(cmd.Context(), node.NodeId, node.X+dx, node.Y+dy, node.Z)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				fmt.Printf("%s => %v\n", no, err)
+				continue
+			}
+			fmt.Printf("%s duplicated as %s (%s)\n", no, node.Name, node.NodeId)
+		}
+		return collectErrors(errs, len(args))
+	},
+}
+
+func init() {
+	duplicateCmd.AddCommand(duplicateNodesCmd)
+	duplicateNodesCmd.Flags().Int("dx", 50, "horizontal offset of each copy from its original")
+	duplicateNodesCmd.Flags().Int("dy", 50, "vertical offset of each copy from its original")
+}
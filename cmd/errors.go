@@ -0,0 +1,122 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+// Exit codes returned by gns3ctl so that scripts can branch on the category
+// of a failure.
+const (
+	ExitOK                = 0
+	ExitError             = 1
+	ExitUsage             = 2
+	ExitNotFound          = 3
+	ExitConflict          = 4
+	ExitUnauthorized      = 5
+	ExitServerUnavailable = 6
+	ExitValidation        = 7
+	ExitNotReady          = 8
+)
+
+var ErrUsage = errors.New("usage")
+
+// usageError marks err as caused by how the command was invoked without
+// changing its message.
+type usageError struct {
+	err error
+}
+
+func (u *usageError) Error() string {
+	return u.err.Error()
+}
+
+func (u *usageError) Unwrap() error {
+	return u.err
+}
+
+func (u *usageError) Is(target error) bool {
+	return target == ErrUsage
+}
+
+// connectError marks err as preventing the client from being set up, so
+// that the server is unavailable to the command.
+type connectError struct {
+	err error
+}
+
+func (c *connectError) Error() string {
+	return fmt.Sprintf("connect: %v", c.err)
+}
+
+func (c *connectError) Unwrap() error {
+	return c.err
+}
+
+func (c *connectError) Is(target error) bool {
+	return target == gns3.ErrServerUnavailable
+}
+
+// exitCode maps an error returned from a command to the process exit code.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrUsage):
+		return ExitUsage
+	case errors.Is(err, gns3.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, gns3.ErrConflict):
+		return ExitConflict
+	case errors.Is(err, gns3.ErrUnauthorized):
+		return ExitUnauthorized
+	case errors.Is(err, gns3.ErrServerUnavailable):
+		return ExitServerUnavailable
+	case errors.Is(err, gns3.ErrValidation):
+		return ExitValidation
+	case errors.Is(err, gns3.ErrNotReady):
+		return ExitNotReady
+	}
+	return ExitError
+}
+
+// multiError is returned by commands that operate on a list of resources and
+// report each failure as it happens. It unwraps to the first failure so that
+// the exit code reflects it.
+type multiError struct {
+	errs  []error
+	total int
+}
+
+func (m *multiError) Error() string {
+	return fmt.Sprintf("%d of %d operations failed", len(m.errs), m.total)
+}
+
+func (m *multiError) Unwrap() error {
+	return m.errs[0]
+}
+
+// collectErrors returns nil if errs is empty, otherwise a multiError.
+func collectErrors(errs []error, total int) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &multiError{errs: errs, total: total}
+}
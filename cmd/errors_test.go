@@ -0,0 +1,76 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/viper"
+)
+
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{errors.New("boom"), ExitError},
+		{&usageError{err: errors.New("bad flag")}, ExitUsage},
+		{&gns3.HttpError{Status: http.StatusNotFound}, ExitNotFound},
+		{fmt.Errorf("get: %w", gns3.ErrNotFound), ExitNotFound},
+		{&gns3.HttpError{Status: http.StatusConflict}, ExitConflict},
+		{&gns3.HttpError{Status: http.StatusUnauthorized}, ExitUnauthorized},
+		{&gns3.HttpError{Status: http.StatusServiceUnavailable}, ExitServerUnavailable},
+		{&gns3.RequestError{Err: errors.New("connection refused")}, ExitServerUnavailable},
+		{&gns3.RequestError{Err: context.DeadlineExceeded}, ExitError},
+		{&connectError{err: errors.New("read CA file")}, ExitServerUnavailable},
+		{&gns3.HttpError{Status: http.StatusBadRequest}, ExitValidation},
+		{gns3.ErrNotReady, ExitNotReady},
+		{collectErrors([]error{gns3.ErrNotFound, gns3.ErrConflict}, 3), ExitNotFound},
+	} {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("%v: got %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestArgsUsageError(t *testing.T) {
+	wrapArgs(rootCmd)
+	rootCmd.SetArgs([]string{"version", "extra"})
+	defer rootCmd.SetArgs(nil)
+	if err := rootCmd.ExecuteContext(context.Background()); exitCode(err) != ExitUsage {
+		t.Errorf("got %v, exit %d, want %d", err, exitCode(err), ExitUsage)
+	}
+}
+
+func TestConnectDownloadBufferSize(t *testing.T) {
+	old := viper.Get("download-buffer-size")
+	defer viper.Set("download-buffer-size", old)
+
+	viper.Set("download-buffer-size", "lots")
+	if _, err := connect(); exitCode(err) != ExitUsage {
+		t.Errorf("invalid size: got %v, exit %d, want %d", err, exitCode(err), ExitUsage)
+	}
+	viper.Set("download-buffer-size", "4M")
+	if _, err := connect(); err != nil {
+		t.Errorf("valid size: %v", err)
+	}
+}
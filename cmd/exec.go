@@ -0,0 +1,251 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// execCmd represents the exec command
+//
+//nolint:exhaustruct
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] NODE [NODE...] -- COMMAND",
+	Short: "Run commands on the consoles of nodes",
+	Long: `
+Runs a command on the telnet console of each node and prints its output,
+taking the command to be done when the node's prompt is shown again:
+
+  gns3ctl exec -p lab pc1 -- ping 10.0.0.2
+
+With --file the commands are read from a file, one per line, skipping
+blank lines and lines starting with #, and run in turn on each node, with
+up to --parallel nodes at a time. When there is more than one node or
+command each output is headed by the node and command.
+
+The prompt is recognised by a regular expression matched against the last
+line of output once the console has been quiet for a moment, with
+defaults for VPCS, IOS-style and Linux nodes. --prompt replaces it for
+every node, or for a type of node when given as TYPE=REGEX, and may be
+repeated:
+
+  gns3ctl exec -p lab --all -f checks.txt --prompt 'qemu=^\S+# ?$'
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		flags := cmd.Flags()
+		all, _ := flags.GetBool("all")
+		parallel, _ := flags.GetInt("parallel")
+		file, _ := flags.GetString("file")
+		timeout, _ := flags.GetDuration("command-timeout")
+
+		nodeArgs, commands := args, []string(nil)
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			nodeArgs = args[:dash]
+			if command := strings.Join(args[dash:], " "); command != "" {
+				commands = append(commands, command)
+			}
+		}
+		switch {
+		case file != "" && len(commands) > 0:
+			return &usageError{err: errors.New("a command cannot be given with --file")}
+		case file == "" && len(commands) == 0:
+			return &usageError{err: errors.New("a command, after --, or --file must be given")}
+		case all && len(nodeArgs) > 0:
+			return &usageError{err: errors.New("nodes cannot be given with --all")}
+		case !all && len(nodeArgs) == 0:
+			return &usageError{err: errors.New("at least one node, or --all, must be given")}
+		case parallel < 1:
+			return &usageError{err: fmt.Errorf("--parallel must be at least 1, not %d", parallel)}
+		case timeout <= 0:
+			return &usageError{err: errors.New("--command-timeout must be greater than zero")}
+		}
+		if file != "" {
+			var err error
+			if commands, err = readCommands(file); err != nil {
+				return err
+			}
+		}
+		promptFlags, _ := flags.GetStringArray("prompt")
+		prompts, prompt, err := parsePrompts(promptFlags)
+		if err != nil {
+			return err
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		nctl := ctl.Nodes(project.ProjectId)
+		nodes, err := nctl.ListContext(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to retrieve nodes: %w", err)
+		}
+		byName := map[string]*gns3.Node{}
+		for _, node := range nodes {
+			byName[node.NodeId] = node
+			byName[node.Name] = node
+			if all {
+				nodeArgs = append(nodeArgs, node.Name)
+			}
+		}
+
+		headed := len(nodeArgs) > 1 || len(commands) > 1
+		var mu sync.Mutex
+		errs := runParallel(cmd.Context(), nodeArgs, parallel, func(ctx context.Context, name string) error {
+			node, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("node '%s': %w", name, gns3.ErrNotFound)
+			}
+			nodePrompt := prompt
+			if p, ok := prompts[node.NodeType]; ok {
+				nodePrompt = p
+			}
+			outputs, err := execCommands(ctx, nctl, node, nodePrompt, commands, timeout)
+
+			// print each node's output together
+			mu.Lock()
+			defer mu.Unlock()
+			for i, output := range outputs {
+				if headed {
+					fmt.Printf("==> %s: %s <==\n", node.Name, commands[i])
+				}
+				if output = strings.TrimRight(output, "\n"); output != "" {
+					fmt.Println(output)
+				}
+			}
+			return err
+		})
+		var failed []error
+		for i, name := range nodeArgs {
+			if errs[i] != nil {
+				failed = append(failed, errs[i])
+				fmt.Fprintf(os.Stderr, "%s => %v\n", name, errs[i])
+			}
+		}
+		return collectErrors(failed, len(nodeArgs))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().StringP("file", "f", "", "read the commands to run from a file, - for stdin")
+	execCmd.Flags().Bool("all", false, "run on every node of the project")
+	execCmd.Flags().Int("parallel", 4, "number of nodes to run on at the same time")
+	execCmd.Flags().StringArray("prompt", nil, "regular expression matching the prompt, or TYPE=REGEX for a type of node")
+	execCmd.Flags().Duration("command-timeout", 30*time.Second, "how long to wait for each command to finish")
+}
+
+// execCommands runs commands in turn on the console of a node, returning
+// the output of those that finished.
+func execCommands(ctx context.Context, nctl *gns3.Nodes, node *gns3.Node, prompt string,
+	commands []string, timeout time.Duration) ([]string, error) {
+	sctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	session, err := nctl.SessionContext(sctx, node.NodeId, prompt)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	outputs := make([]string, 0, len(commands))
+	for _, command := range commands {
+		cctx, cancel := context.WithTimeout(ctx, timeout)
+		output, err := session.Run(cctx, command)
+		cancel()
+		if err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+var nodeTypeRE = regexp.MustCompile(`^[a-z_]+$`)
+
+// parsePrompts returns the prompts given for types of node, as TYPE=REGEX,
+// and the prompt given for every other node, if any.
+func parsePrompts(flags []string) (map[string]string, string, error) {
+	prompts := map[string]string{}
+	prompt := ""
+	for _, flag := range flags {
+		re := flag
+		nodeType, typed, ok := strings.Cut(flag, "=")
+		if ok && nodeTypeRE.MatchString(nodeType) {
+			re = typed
+		} else {
+			nodeType = ""
+		}
+		if _, err := regexp.Compile(re); err != nil {
+			return nil, "", &usageError{err: fmt.Errorf("invalid prompt '%s': %w", re, err)}
+		}
+		if nodeType != "" {
+			prompts[nodeType] = re
+		} else {
+			prompt = re
+		}
+	}
+	return prompts, prompt, nil
+}
+
+// readCommands reads the commands from a file, or stdin for -, skipping
+// blank lines and comments.
+func readCommands(file string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("read commands: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var commands []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			commands = append(commands, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read commands: %w", err)
+	}
+	if len(commands) == 0 {
+		return nil, &usageError{err: fmt.Errorf("no commands found in %s", file)}
+	}
+	return commands, nil
+}
@@ -0,0 +1,223 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// exportNetworkCmd represents the export network command
+//
+//nolint:exhaustruct
+var exportNetworkCmd = &cobra.Command{
+	Use:     "network [flags]",
+	Aliases: []string{"net", "networks"},
+	Short:   "Export a project as a YAML network document",
+	Long: `
+Writes the nodes and links of a project as a network document that can be
+given to the load command. Nodes created from a template reference the
+template by name, and the configuration of VPCS nodes is read back from their
+startup.vpc file.
+
+Appliances are not recorded, so the templates referenced by the document must
+already exist on the server it is loaded into.
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// No project, no network
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+
+		network, err := exportNetwork(cmd.Context(), ctl, project)
+		if err != nil {
+			return err
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		switch output {
+		case "json":
+			j, _ := json.MarshalIndent(network, "", "  ")
+			fmt.Println(string(j))
+		default:
+			fallthrough
+		case "yaml":
+			y, err := marshalNetwork(network)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(y))
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportNetworkCmd)
+	exportNetworkCmd.Flags().StringP("output", "o", "yaml", "Output format. One of yaml, json")
+}
+
+// exportNetwork builds the network document describing a project.
+func exportNetwork(ctx context.Context, ctl *gns3.Gns3, project *gns3.Project) (*gns3.Network, error) {
+	nctl := ctl.Nodes(project.ProjectId)
+	nodes, err := nctl.ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve nodes: %w", err)
+	}
+	links, err := ctl.Links(project.ProjectId).ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve links: %w", err)
+	}
+	templates, err := ctl.Templates().ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve templates: %w", err)
+	}
+	templateNames := make(map[string]string, len(templates))
+	for _, t := range templates {
+		templateNames[t.TemplateId] = t.Name
+	}
+
+	network := &gns3.Network{
+		ApiVersion: gns3.NetworkApiVersion,
+		Kind:       gns3.NetworkKind,
+		Metadata:   gns3.NetworkMetadata{Name: project.Name},
+	}
+
+	// Sort so that the document is stable across exports
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	names := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		names[node.NodeId] = node.Name
+		x, y := node.X, node.Y
+		spec := gns3.NetworkNode{
+			Name: node.Name,
+			X:    &x,
+			Y:    &y,
+			Z:    node.Z,
+		}
+		if name, ok := templateNames[node.TemplateId]; ok && node.TemplateId != "" {
+			spec.Template = name
+		} else {
+			spec.Type = node.NodeType
+		}
+		if node.ComputeId != viper.GetString("compute") {
+			spec.ComputeId = node.ComputeId
+		}
+		// GNS3 labels nodes with their name unless told otherwise
+		if text, _ := node.Label["text"].(string); text != "" && text != node.Name {
+			spec.Label = text
+		}
+		if strings.ToLower(node.NodeType) == gns3.TypeVpcs {
+			spec.Config, err = readVpcsStartup(ctx, nctl, node.NodeId)
+			if err != nil {
+				return nil, fmt.Errorf("node '%s': %w", node.Name, err)
+			}
+		}
+		network.Spec.Nodes = append(network.Spec.Nodes, spec)
+	}
+
+	for _, link := range links {
+		aEnd, zEnd, ok := liveLinkEnds(link, names)
+		if !ok {
+			continue
+		}
+		network.Spec.Links = append(network.Spec.Links, gns3.NetworkLink{AEnd: aEnd, ZEnd: zEnd})
+	}
+	sort.Slice(network.Spec.Links, func(i, j int) bool {
+		a, b := network.Spec.Links[i], network.Spec.Links[j]
+		return specLinkDesc(&a) < specLinkDesc(&b)
+	})
+
+	return network, nil
+}
+
+// marshalNetwork writes a network document as YAML with the same library,
+// yaml.v3, that is used to parse it, so that what is exported reads back the
+// same when it is loaded.
+func marshalNetwork(network *gns3.Network) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(network); err != nil {
+		return nil, fmt.Errorf("encode network: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("encode network: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readVpcsStartup reads the configuration of a VPCS node back from its
+// startup.vpc file through the controller. It returns nil if there is no
+// file.
+func readVpcsStartup(ctx context.Context, nctl *gns3.Nodes, nodeID string) (*gns3.NodeConfig, error) {
+	data, err := nctl.ReadFileContext(ctx, nodeID, "startup.vpc")
+	if errors.Is(err, gns3.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read VPCS startup file: %w", err)
+	}
+
+	var config gns3.NodeConfig
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 3 && fields[0] == "set" && fields[1] == "pcname":
+			config.Name = fields[2]
+			found = true
+		case len(fields) >= 2 && fields[0] == "ip":
+			config.Address = fields[1]
+			if len(fields) > 2 {
+				config.Netmask = fields[2]
+			}
+			if len(fields) > 3 {
+				config.Gateway = fields[3]
+			}
+			found = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read VPCS startup file: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &config, nil
+}
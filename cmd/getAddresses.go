@@ -0,0 +1,122 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// getAddressesCmd represents the get addresses command
+//
+//nolint:exhaustruct
+var getAddressesCmd = &cobra.Command{
+	Use:     "addresses [flags] [NODE...]",
+	Aliases: []string{"addr", "address"},
+	Short:   "List the addresses allocated to nodes",
+	Long: `
+Lists the addresses allocated to the nodes of a project from the ipam pools
+of its network document, as recorded by load, for every node or for the
+nodes given.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		addresses, err := readAddresses(cmd.Context(), ctl, project)
+		if err == nil && len(addresses) == 0 {
+			err = gns3.ErrNotFound
+		}
+		if errors.Is(err, gns3.ErrNotFound) {
+			return fmt.Errorf("project '%s' has no allocated addresses: %w", pname, err)
+		} else if err != nil {
+			return err
+		}
+
+		var errs []error
+		if len(args) > 0 {
+			byNode := map[string][]gns3.Address{}
+			for _, addr := range addresses {
+				byNode[addr.Node] = append(byNode[addr.Node], addr)
+			}
+			addresses = nil
+			for _, name := range args {
+				if _, ok := byNode[name]; !ok {
+					errs = append(errs, fmt.Errorf("node '%s' has no allocated addresses: %w", name, gns3.ErrNotFound))
+					continue
+				}
+				addresses = append(addresses, byNode[name]...)
+			}
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		switch output {
+		case "json":
+			j, _ := json.Marshal(addresses)
+			fmt.Println(string(j))
+		case "yaml":
+			y, _ := yaml.Marshal(addresses)
+			fmt.Println(string(y))
+		default:
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", "NODE", "POOL", "ADDRESS", "GATEWAY", "PEER", "INTERFACE")
+			for _, addr := range addresses {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", addr.Node, addr.Pool, addr.Address, addr.Gateway, addr.Peer, addr.Interface)
+			}
+			tw.Flush()
+		}
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return collectErrors(errs, len(args))
+	},
+}
+
+// readAddresses returns the addresses recorded in a project by load.
+func readAddresses(ctx context.Context, ctl *gns3.Gns3, project *gns3.Project) ([]gns3.Address, error) {
+	data, err := ctl.Projects().ReadFileContext(ctx, project.ProjectId, gns3.AddressesFile)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []gns3.Address
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		return nil, fmt.Errorf("read %s: %w", gns3.AddressesFile, err)
+	}
+	return addresses, nil
+}
+
+func init() {
+	getCmd.AddCommand(getAddressesCmd)
+	getAddressesCmd.Flags().StringP("output", "o", "columns", "Output format. One of json yaml, columns")
+}
@@ -21,7 +21,6 @@ import (
 	"os"
 	"text/tabwriter"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 )
@@ -33,7 +32,7 @@ var getAppliancesCmd = &cobra.Command{
 	Use:     "appliances [flags] [APPLIANCE...]",
 	Short:   "Query the GNS3 server appliances",
 	Aliases: []string{"ap", "app", "appliance"},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
 		val, _ := cmd.Flags().GetString("output")
 		switch val {
@@ -44,10 +43,15 @@ var getAppliancesCmd = &cobra.Command{
 		case "json":
 		case "yaml":
 		}
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		var errs []error
 		if len(args) == 0 {
-			appliances, err := gns3.Connect().Appliances().List()
+			appliances, err := ctl.Appliances().ListContext(cmd.Context())
 			if err != nil {
-				panic(err)
+				return err
 			}
 			val, _ := cmd.Flags().GetString("output")
 			switch val {
@@ -72,8 +76,9 @@ var getAppliancesCmd = &cobra.Command{
 				fallthrough
 			case "columns":
 				for _, id := range args {
-					a, err := gns3.Connect().Appliances().Get(id)
+					a, err := ctl.Appliances().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", id, "", "", "", "", err.Error())
 					} else {
 						fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\n", a.Name, a.Category, a.ProductName, a.VendorName, a.Builtin, a.Status)
@@ -85,8 +90,9 @@ var getAppliancesCmd = &cobra.Command{
 			case "yaml":
 				list := []interface{}{}
 				for _, id := range args {
-					appliance, err := gns3.Connect().Appliances().Get(id)
+					appliance, err := ctl.Appliances().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						nf := map[string]string{
 							"name":  id,
 							"error": err.Error(),
@@ -114,6 +120,7 @@ var getAppliancesCmd = &cobra.Command{
 		case "json":
 		case "yaml":
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -21,7 +21,6 @@ import (
 	"os"
 	"text/tabwriter"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 )
@@ -33,7 +32,7 @@ var getComputesCmd = &cobra.Command{
 	Use:     "computes [flags] [COMPUTE...]",
 	Short:   "Query the GNS3 compute nodes",
 	Aliases: []string{"co", "comp", "compute"},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
 		output, _ := cmd.Flags().GetString("output")
 		switch output {
@@ -44,10 +43,15 @@ var getComputesCmd = &cobra.Command{
 		case "json":
 		case "yaml":
 		}
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		var errs []error
 		if len(args) == 0 {
-			computes, err := gns3.Connect().Computes().List()
+			computes, err := ctl.Computes().ListContext(cmd.Context())
 			if err != nil {
-				panic(err)
+				return err
 			}
 			output, _ = cmd.Flags().GetString("output")
 			switch output {
@@ -72,8 +76,9 @@ var getComputesCmd = &cobra.Command{
 				fallthrough
 			case "columns":
 				for _, id := range args {
-					compute, err := gns3.Connect().Computes().Get(id)
+					compute, err := ctl.Computes().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Fprintf(tw, "%s\t%s\t%s\n", id, "", err.Error())
 					} else {
 						fmt.Fprintf(tw, "%s\t%s\t%s\n", compute.ComputeId, compute.Name, compute.Host)
@@ -85,8 +90,9 @@ var getComputesCmd = &cobra.Command{
 			case "yaml":
 				list := []interface{}{}
 				for _, id := range args {
-					template, err := gns3.Connect().Templates().Get(id)
+					template, err := ctl.Templates().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						nf := map[string]string{
 							"name":  id,
 							"error": err.Error(),
@@ -114,6 +120,7 @@ var getComputesCmd = &cobra.Command{
 		case "json":
 		case "yaml":
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -22,7 +22,6 @@ import (
 	"strings"
 	"text/tabwriter"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
@@ -39,18 +38,19 @@ var getLinksCmd = &cobra.Command{
 	Use:     "links [flags] [LINK...]",
 	Aliases: []string{"li", "link"},
 	Short:   "Query a GNS3 server network links",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctl := gns3.Connect()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
 
 		pname := viper.GetString("project")
 		if pname == "" {
-			fmt.Printf("ERROR: a project context must be specified")
-			return
+			return ErrNoProjectSpecified
 		}
-		project, err := ctl.Projects().Get(pname)
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
 		if err != nil {
-			fmt.Printf("ERROR: project `%s` not found\n", pname)
-			return
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
 		}
 
 		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
@@ -62,10 +62,11 @@ var getLinksCmd = &cobra.Command{
 			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", "UUID", "TYPE", "SUSPEND", "NODES")
 		case "json", "yaml", "name", "id":
 		}
+		var errs []error
 		if len(args) == 0 {
-			links, err := ctl.Links(project.ProjectId).List()
+			links, err := ctl.Links(project.ProjectId).ListContext(cmd.Context())
 			if err != nil {
-				panic(err)
+				return err
 			}
 			switch output {
 			default:
@@ -74,7 +75,7 @@ var getLinksCmd = &cobra.Command{
 				for _, l := range links {
 					var nodes []string
 					for _, n := range l.Nodes {
-						info, err := ctl.Nodes(project.ProjectId).Get(n.NodeId)
+						info, err := ctl.Nodes(project.ProjectId).GetContext(cmd.Context(), n.NodeId)
 						if err == nil {
 							nodes = append(nodes, fmt.Sprintf("%s(%d)", info.Name, n.PortNumber))
 						}
@@ -99,13 +100,14 @@ var getLinksCmd = &cobra.Command{
 				fallthrough
 			case "columns":
 				for _, id := range args {
-					l, err := ctl.Links(project.ProjectId).Get(id)
+					l, err := ctl.Links(project.ProjectId).GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", id, "", "", err.Error())
 					} else {
 						var nodes []string
 						for _, n := range l.Nodes {
-							info, err := ctl.Nodes(project.ProjectId).Get(n.NodeId)
+							info, err := ctl.Nodes(project.ProjectId).GetContext(cmd.Context(), n.NodeId)
 							if err == nil {
 								nodes = append(nodes, fmt.Sprintf("%s(%d)", info.Name, n.PortNumber))
 							}
@@ -115,8 +117,9 @@ var getLinksCmd = &cobra.Command{
 				}
 			case "name", "id":
 				for _, id := range args {
-					l, err := ctl.Links(project.ProjectId).Get(id)
+					l, err := ctl.Links(project.ProjectId).GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Printf("%s => %s\n", id, err.Error())
 					} else {
 						fmt.Println(l.LinkId)
@@ -128,8 +131,9 @@ var getLinksCmd = &cobra.Command{
 			case "yaml":
 				list := []interface{}{}
 				for _, id := range args {
-					template, err := ctl.Templates().Get(id)
+					template, err := ctl.Templates().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						nf := map[string]string{
 							"name":  id,
 							"error": err.Error(),
@@ -155,6 +159,7 @@ var getLinksCmd = &cobra.Command{
 			tw.Flush()
 		case "json", "yaml", "name", "id":
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -36,9 +36,9 @@ const (
 	virshTmpl = `virsh net-dhcp-leases default --mac {{.MacAddress}} | tail -2 | head -1 | awk '{print $5}' | sed -e 's;/.*;;'`
 )
 
-var ErrNoTemplateFile = errors.New("a template file must be specified")
-var ErrNoProjectSpecified = errors.New("a project must be specified")
-var ErrProjectNotFound = errors.New("project not found")
+var ErrNoTemplateFile error = &usageError{err: errors.New("a template file must be specified")}
+var ErrNoProjectSpecified error = &usageError{err: errors.New("a project must be specified")}
+var ErrProjectNotFound = fmt.Errorf("project not found: %w", gns3.ErrNotFound)
 
 // getNodesCmd represents the getNodes command
 //
@@ -67,8 +67,11 @@ var getNodesCmd = &cobra.Command{
 			return ErrNoProjectSpecified
 		}
 
-		ctl := gns3.Connect()
-		project, err := ctl.Projects().Get(pname)
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
 		if err != nil {
 			return fmt.Errorf("project '%s' not found: %w", pname, err)
 		}
@@ -77,13 +80,13 @@ var getNodesCmd = &cobra.Command{
 
 		// Fetch nodes, either whole list or by ID/name
 		if len(args) == 0 {
-			nodes, err = ctl.Nodes(project.ProjectId).List()
+			nodes, err = ctl.Nodes(project.ProjectId).ListContext(cmd.Context())
 			if err != nil {
 				return fmt.Errorf("unable to retrieve nodes: %w", err)
 			}
 		} else {
 			for _, id := range args {
-				n, err := ctl.Nodes(project.ProjectId).Get(id)
+				n, err := ctl.Nodes(project.ProjectId).GetContext(cmd.Context(), id)
 
 				// If a query for a named node fails, save the error to
 				// report at the end of the output, mimics kubectl
@@ -170,13 +173,10 @@ var getNodesCmd = &cobra.Command{
 			}
 		}
 
-		if len(errs) > 0 {
-			for _, err := range errs {
-				fmt.Fprintf(os.Stderr, "Error from server: %s\n", err)
-			}
-			os.Exit(1)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "Error from server: %s\n", err)
 		}
-		return nil
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -21,7 +21,6 @@ import (
 	"os"
 	"text/tabwriter"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 )
@@ -33,8 +32,11 @@ var getProjectsCmd = &cobra.Command{
 	Use:     "projects [PROJECT...]",
 	Short:   "Query the projects from a GNS3 server",
 	Aliases: []string{"project", "proj", "pr"},
-	Run: func(cmd *cobra.Command, args []string) {
-		ctl := gns3.Connect()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
 
 		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
 		output, _ := cmd.Flags().GetString("output")
@@ -45,10 +47,11 @@ var getProjectsCmd = &cobra.Command{
 			fmt.Fprintf(tw, "%s\t%s\t%s\n", "UUID", "NAME", "STATUS")
 		case "json", "yaml", "name", "id":
 		}
+		var errs []error
 		if len(args) == 0 {
-			projects, err := ctl.Projects().List()
+			projects, err := ctl.Projects().ListContext(cmd.Context())
 			if err != nil {
-				panic(err)
+				return err
 			}
 			switch output {
 			default:
@@ -79,8 +82,9 @@ var getProjectsCmd = &cobra.Command{
 				fallthrough
 			case "columns":
 				for _, id := range args {
-					project, err := ctl.Projects().Get(id)
+					project, err := ctl.Projects().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Fprintf(tw, "%s\t%s\t%s\n", id, "", err.Error())
 					} else {
 						fmt.Fprintf(tw, "%s\t%s\t%s\n", project.ProjectId, project.Name, project.Status)
@@ -92,8 +96,9 @@ var getProjectsCmd = &cobra.Command{
 			case "yaml":
 				list := []interface{}{}
 				for _, id := range args {
-					template, err := ctl.Projects().Get(id)
+					template, err := ctl.Projects().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						nf := map[string]string{
 							"name":  id,
 							"error": err.Error(),
@@ -112,8 +117,9 @@ var getProjectsCmd = &cobra.Command{
 				}
 			case "name":
 				for _, id := range args {
-					project, err := ctl.Projects().Get(id)
+					project, err := ctl.Projects().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Printf("%s => %s\n", id, err.Error())
 					} else {
 						fmt.Println(project.Name)
@@ -121,8 +127,9 @@ var getProjectsCmd = &cobra.Command{
 				}
 			case "id":
 				for _, id := range args {
-					project, err := ctl.Projects().Get(id)
+					project, err := ctl.Projects().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Printf("%s => %s\n", id, err.Error())
 					} else {
 						fmt.Println(project.ProjectId)
@@ -137,6 +144,7 @@ var getProjectsCmd = &cobra.Command{
 			tw.Flush()
 		case "json", "yaml", "name", "id":
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
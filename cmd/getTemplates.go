@@ -21,7 +21,6 @@ import (
 	"os"
 	"text/tabwriter"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 )
@@ -33,7 +32,7 @@ var getTemplatesCmd = &cobra.Command{
 	Use:     "templates [flags] [TEMPLATE...]",
 	Short:   "Query templates from the GNS3 server",
 	Aliases: []string{"t", "te", "temp", "temps", "template"},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
 		val, _ := cmd.Flags().GetString("output")
 		switch val {
@@ -44,11 +43,15 @@ var getTemplatesCmd = &cobra.Command{
 		case "json":
 		case "yaml":
 		}
-		ctl := gns3.Connect()
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		var errs []error
 		if len(args) == 0 {
-			templates, err := ctl.Templates().List()
+			templates, err := ctl.Templates().ListContext(cmd.Context())
 			if err != nil {
-				panic(err)
+				return err
 			}
 			val, _ := cmd.Flags().GetString("output")
 			switch val {
@@ -73,8 +76,9 @@ var getTemplatesCmd = &cobra.Command{
 				fallthrough
 			case "columns":
 				for _, id := range args {
-					template, err := ctl.Templates().Get(id)
+					template, err := ctl.Templates().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", id, "", "", "", err.Error())
 					} else {
 						fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", template.TemplateId, template.Name, template.Category, template.TemplateType, template.Builtin)
@@ -86,8 +90,9 @@ var getTemplatesCmd = &cobra.Command{
 			case "yaml":
 				list := []interface{}{}
 				for _, id := range args {
-					template, err := ctl.Templates().Get(id)
+					template, err := ctl.Templates().GetContext(cmd.Context(), id)
 					if err != nil {
+						errs = append(errs, err)
 						nf := map[string]string{
 							"name":  id,
 							"error": err.Error(),
@@ -115,6 +120,7 @@ var getTemplatesCmd = &cobra.Command{
 		case "json":
 		case "yaml":
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -61,18 +61,21 @@ var getTopologyCmd = &cobra.Command{
 			return ErrNoProjectSpecified
 		}
 
-		ctl := gns3.Connect()
-		project, err := ctl.Projects().Get(pname)
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
 		if err != nil {
 			return fmt.Errorf("project '%s' not found: %w", pname, err)
 		}
 
 		// Fetch nodes and links
-		nodes, err := ctl.Nodes(project.ProjectId).List()
+		nodes, err := ctl.Nodes(project.ProjectId).ListContext(cmd.Context())
 		if err != nil {
 			return fmt.Errorf("unable to retrieve nodes: %w", err)
 		}
-		links, err := ctl.Links(project.ProjectId).List()
+		links, err := ctl.Links(project.ProjectId).ListContext(cmd.Context())
 		if err != nil {
 			return fmt.Errorf("unable to retrieve links: %w", err)
 		}
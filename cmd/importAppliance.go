@@ -20,7 +20,6 @@ import (
 	"os"
 	"path"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 )
 
@@ -32,29 +31,36 @@ var importApplianceCmd = &cobra.Command{
 	Aliases: []string{"ap", "app", "appliance"},
 	Args:    cobra.MinimumNArgs(1),
 	Short:   "Import appliance definitions from files",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctl := gns3.Connect()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
 		apps := ctl.Appliances()
 		templates := ctl.Templates()
+		var errs []error
 		for _, filename := range args {
 			file, err := os.Open(filename)
 			if err != nil {
+				errs = append(errs, err)
 				fmt.Printf("ERROR: '%s': %v\n", filename, err)
 				continue
 			}
-			a, t, err := apps.Import(file, path.Dir(filename))
+			a, t, err := apps.ImportContext(cmd.Context(), file, path.Dir(filename))
 			if err != nil {
+				errs = append(errs, err)
 				fmt.Printf("ERROR: '%s': %v\n", filename, err)
 			} else {
 				fmt.Println(a.Name)
 				// try creating the template for the appliance
-				existing, err := templates.Get(t.Name)
+				existing, err := templates.GetContext(cmd.Context(), t.Name)
 				if err == nil {
 					fmt.Println("Template", existing.Name, "already present")
 				} else {
 					// create the template
-					created, err := templates.Create(t)
+					created, err := templates.CreateContext(cmd.Context(), t)
 					if err != nil {
+						errs = append(errs, err)
 						fmt.Printf("ERROR: creating template '%s': %v\n", t.Name, err)
 					} else {
 						fmt.Println("Template", created.Name, "type", created.TemplateType, "created")
@@ -62,8 +68,8 @@ var importApplianceCmd = &cobra.Command{
 				}
 			}
 			file.Close()
-
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -0,0 +1,120 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// layoutCmd represents the layout command
+//
+//nolint:exhaustruct
+var layoutCmd = &cobra.Command{
+	Use:   "layout [flags]",
+	Short: "Arrange the nodes of a project",
+	Long: `
+Moves every node of a project to the position given by a layout algorithm,
+one of:
+
+  auto     layered if the project has links, otherwise grid
+  layered  rows by distance from the NAT and cloud nodes, or from the most
+           connected nodes, such as the spines of a fabric
+  force    force directed, linked nodes are drawn together
+  grid     rows and columns in name order
+
+With --dry-run the new positions are printed but the nodes are not moved.
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		algorithm, _ := cmd.Flags().GetString("algorithm")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if algorithm == gns3.LayoutNone {
+			return &usageError{err: fmt.Errorf("layout '%s' would not move any nodes", algorithm)}
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		nctl := ctl.Nodes(project.ProjectId)
+		nodes, err := nctl.ListContext(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to retrieve nodes: %w", err)
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		links, err := ctl.Links(project.ProjectId).ListContext(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to retrieve links: %w", err)
+		}
+
+		// Lay out a network holding just the nodes and links
+		var network gns3.Network
+		names := make(map[string]string, len(nodes))
+		live := make(map[string]*gns3.Node, len(nodes))
+		for _, node := range nodes {
+			names[node.NodeId] = node.Name
+			live[node.Name] = node
+			network.Spec.Nodes = append(network.Spec.Nodes, gns3.NetworkNode{Name: node.Name, Type: node.NodeType})
+		}
+		for _, link := range links {
+			if a, z, ok := liveLinkEnds(link, names); ok {
+				network.Spec.Links = append(network.Spec.Links, gns3.NetworkLink{AEnd: a, ZEnd: z})
+			}
+		}
+		if err := network.Layout(algorithm, true); err != nil {
+			return err
+		}
+
+		var errs []error
+		moved := 0
+		for i := range network.Spec.Nodes {
+			spec := &network.Spec.Nodes[i]
+			node := live[spec.Name]
+			if node.X == *spec.X && node.Y == *spec.Y {
+				continue
+			}
+			moved++
+			if !dryRun {
+				if _, err := nctl.UpdateContext(cmd.Context(), node.NodeId, &gns3.NodeUpdate{X: spec.X, Y: spec.Y}); err != nil {
+					errs = append(errs, err)
+					fmt.Printf("NODE: %s (%s) => %v\n", node.Name, node.NodeId, err)
+					continue
+				}
+			}
+			fmt.Printf("NODE: %s (%s) moved to %d,%d\n", node.Name, node.NodeId, *spec.X, *spec.Y)
+		}
+		return collectErrors(errs, moved)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(layoutCmd)
+	layoutCmd.Flags().String("algorithm", gns3.LayoutAuto, "layout algorithm. One of auto, layered, force, grid")
+	layoutCmd.Flags().Bool("dry-run", false, "print the new positions without moving the nodes")
+}
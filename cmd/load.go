@@ -16,51 +16,25 @@ limitations under the License.
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
+	"io/fs"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
-	"gopkg.in/yaml.v2"
 )
 
-const (
-	vpcsStartupTempl = `# Startup Configuration
-set pcname %s
-ip %s %s %s
-`
-)
-
-type linkRef struct {
-	nodeId  string
-	port    int
-	adapter int
-}
-
-type linkIndex struct {
-	aEnd, zEnd linkRef
-}
-
-type linkState struct {
-	suspend bool
-	linkId  string
-}
-
-type linkInfo struct {
-	aEndName, zEndName string
-}
-
 // loadCmd represents the load command
 //
 //nolint:exhaustruct
 var loadCmd = &cobra.Command{
-	Use:     "load [flags] FILE [FILE...]",
+	Use:     "load [flags] FILE|DIR|- [FILE|DIR|-...]",
 	Aliases: []string{"apply"},
 	Short:   "Loads a project into the GNS3 environment",
 	Long: `
@@ -69,287 +43,298 @@ project, the nodes in that project, and the links between the nodes, according
 to the specification in the YAML network document.
 
 The name of the project is specified in the YAML network document, and if a
-project with this name already exists, then the loading of the YAML network
-document will report and error.
+project with this name already exists, then it is converged to the document:
+missing templates, nodes and links are created, nodes whose position differs
+are updated, nodes whose template, type or compute differs are replaced, and
+links that are not in the document are deleted. Nodes that are not in the
+document are only deleted when --prune is specified.
+
+A file may hold several network documents separated by '---', a directory is
+searched recursively for .yaml and .yml files, and '-' reads from stdin. The
+documents are loaded in order and, when there is more than one, a summary of
+each project is displayed at the end.
+
+Each file is rendered as a Go template before it is parsed, with the values
+from --values files and --set overrides available as .Values. See the render
+command for the helpers available.
+
+Nodes without x and y coordinates keep their position if they already exist,
+otherwise they are placed by the layout given in the document's spec.layout,
+or by --layout. The default, auto, is layered for networks with links and a
+grid for those without.
+
+With --dry-run the changes are printed, as with the diff command, but not
+made. Links that need more ports than a new node will have are reported
+when its type or template gives its number of ports, rather than once the
+node is created.
 `,
 	Args: cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		for _, filename := range args {
-			pr, err := doLoad(filename)
-			if err == nil {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		prune, _ := cmd.Flags().GetBool("prune")
+		layout, _ := cmd.Flags().GetString("layout")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		opts := &loadOptions{dryRun: dryRun, prune: prune, layout: layout, parallel: parallel}
+		values, err := renderValues(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		var results []loadResult
+		err = forEachNetwork(args, values, func(source string, network *gns3.Network) error {
+			plan, pr, err := loadNetwork(cmd.Context(), ctl, network, opts)
+			results = append(results, loadResult{source: source, network: network, plan: plan, err: err})
+			if pr != nil {
 				fmt.Println(pr.ProjectId)
-			} else {
-				fmt.Printf("ERROR: %s: %v\n", filename, err)
 			}
+			return err
+		}, func(source string, err error) {
+			results = append(results, loadResult{source: source, err: err})
+		})
+		if len(results) > 1 {
+			printLoadSummary(os.Stdout, results, opts)
 		}
+		return err
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(loadCmd)
+	loadCmd.Flags().Bool("dry-run", false, "print the changes that would be made without making them")
+	loadCmd.Flags().Bool("prune", false, "delete nodes in the project that are not in the network document")
+	loadCmd.Flags().String("layout", "", "layout for nodes without coordinates, overriding the document. One of auto, layered, force, grid, none")
+	loadCmd.Flags().Int("parallel", 4, "number of nodes to start at the same time")
+	addRenderFlags(loadCmd)
 }
 
-func doLoad(filename string) (*gns3.Project, error) {
-	var network gns3.Network
-	// Open and parse the file as YAML
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-	decoder := yaml.NewDecoder(file)
-	err = decoder.Decode(&network)
-	if err != nil {
-		return nil, err
-	}
+// loadResult records the outcome of loading one network document, or of
+// reading a file when network is nil.
+type loadResult struct {
+	source  string
+	network *gns3.Network
+	plan    *networkPlan
+	err     error
+}
 
-	ctl := gns3.Connect()
-	var project *gns3.Project
-	project, err = ctl.Projects().Get(network.Metadata.Name)
-	if err != nil {
-		project, err = ctl.Projects().Create(&gns3.Project{Name: network.Metadata.Name})
+// isNetworkFile returns true if the name has a YAML extension.
+func isNetworkFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// expandInputs replaces each directory in args with the YAML files found
+// beneath it, in lexical order.
+func expandInputs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		if arg == "-" {
+			files = append(files, arg)
+			continue
+		}
+		info, err := os.Stat(arg)
 		if err != nil {
-			return nil, fmt.Errorf("create: %w", err)
+			return nil, err
 		}
-		fmt.Printf("PROJECT: %s (%s) created\n", project.Name, project.ProjectId)
-	} else {
-		fmt.Printf("PROJECT: %s (%s) exists\n", project.Name, project.ProjectId)
-	}
-
-	if len(network.Spec.Appliances) > 0 {
-		apps := ctl.Appliances()
-		templates := ctl.Templates()
-		var reader io.ReadCloser
-		for _, ref := range network.Spec.Appliances {
-			// Attempt to parse as url
-			u, err := url.Parse(ref)
-			if err != nil || u.Scheme == "" {
-				// not able to parse the URL, or contains
-				// no schema, then assume a local file reference
-				file, err := os.Open(ref)
-				if err != nil {
-					return nil, fmt.Errorf("ERROR: '%s': %w", ref, err)
-				}
-				reader = file
-			} else if err != nil {
-				return nil, fmt.Errorf("ERROR: unable to parse appliance reference '%s': %w", ref, err)
-			} else {
-				if strings.HasPrefix(strings.ToLower(u.Scheme), "http") {
-					resp, err := http.Get(u.String())
-					if err != nil {
-						return nil, fmt.Errorf("ERROR: unable to fetch appliance '%s': %w", ref, err)
-					}
-					reader = resp.Body
-				} else {
-					return nil, fmt.Errorf("ERROR: unsupported appliance URL '%s'", ref)
-				}
-			}
-			defer reader.Close()
-			a, t, err := apps.Import(reader, path.Dir(ref))
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		found := 0
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
-				return nil, fmt.Errorf("ERROR: '%s': %w\n", ref, err)
-			} else {
-				fmt.Println(a.Name)
-				// try creating the template for the appliance
-				existing, err := templates.Get(t.Name)
-				if err == nil {
-					fmt.Println("Template", existing.Name, "already present")
-				} else {
-					// create the template
-					created, err := templates.Create(t)
-					if err != nil {
-						return nil, fmt.Errorf("ERROR: creating template '%s': %w\n", t.Name, err)
-					} else {
-						fmt.Println("Template", created.Name, "type", created.TemplateType, "created")
-					}
-				}
+				return err
+			}
+			if !d.IsDir() && isNetworkFile(path) {
+				files = append(files, path)
+				found++
 			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("%s: no .yaml or .yml files found", arg)
 		}
 	}
+	return files, nil
+}
 
-	nctl := ctl.Nodes(project.ProjectId)
-	for _, node := range network.Spec.Nodes {
-		computeID := node.ComputeId
-		if computeID == "" {
-			computeID = viper.GetString("compute")
+// addRenderFlags adds the flags that supply values to network documents.
+func addRenderFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("values", nil, "YAML file of values available to network documents as .Values, may be repeated")
+	cmd.Flags().StringArray("set", nil, "set a value, as key=value with a dotted key, overriding the values files, may be repeated")
+	cmd.Flags().StringArray("set-string", nil, "set a value as --set does, but always as a string, may be repeated")
+}
+
+// renderValues reads the values files, in order, and applies the overrides
+// given on the command line, those of --set-string last.
+func renderValues(cmd *cobra.Command) (gns3.Values, error) {
+	values := gns3.Values{}
+	files, _ := cmd.Flags().GetStringArray("values")
+	for _, filename := range files {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
 		}
-		resp, err := nctl.Get(node.Name)
+		more, err := gns3.ReadValues(file)
+		file.Close()
 		if err != nil {
-			if node.Template != "" {
-				var t *gns3.Template
-				t, err = ctl.Templates().Get(node.Template)
-				if err != nil {
-					return nil, fmt.Errorf("unknown template: %w", err)
-				}
-				resp, err = nctl.CreateUsingTemplate(&gns3.Node{
-					Name:      node.Name,
-					NodeType:  node.Type,
-					ComputeId: computeID,
-					X:         node.X,
-					Y:         node.Y,
-					Z:         node.Z}, t)
-			} else {
-				symbol := ""
-				switch strings.ToLower(node.Type) {
-				case gns3.TypeVpcs:
-					symbol = gns3.SymbolVpcs
-				case gns3.TypeNat:
-					symbol = gns3.SymbolCloud
-				case gns3.TypeRouter:
-					symbol = gns3.SymbolRouter
-				case gns3.TypeEthernetSwitch:
-					symbol = gns3.SymbolEthernetSwitch
-				case gns3.TypeMultilayerSwitch:
-					symbol = gns3.SymbolMultilayerSwitch
-				case gns3.TypeFirewall:
-					symbol = gns3.SymbolFirewall
-				default:
-					symbol = fmt.Sprintf(":/symbols/classic/%s.svg", strings.ToLower(node.Type))
-				}
-				resp, err = nctl.Create(&gns3.Node{
-					Name:      node.Name,
-					NodeType:  node.Type,
-					ComputeId: computeID,
-					Symbol:    symbol,
-					X:         node.X,
-					Y:         node.Y,
-					Z:         node.Z})
-			}
-			if err != nil {
-				return nil, fmt.Errorf("node create: %w", err)
-			}
-			switch strings.ToLower(node.Type) {
-			case gns3.TypeVpcs:
-				if node.Config != nil {
-					// If we are a VPCS and have a config, we will write out a
-					// startup.vpc files
-					filename := fmt.Sprintf("%s/projects/%s/project-files/vpcs/%s/startup.vpc",
-						viper.GetString("base-directory"), project.ProjectId, resp.NodeId)
-					err := os.MkdirAll(path.Dir(filename), 0755)
-					if err != nil {
-						return nil, fmt.Errorf("create VPCS startup file: %w", err)
-					}
-					data := fmt.Sprintf(vpcsStartupTempl, node.Config.Name, node.Config.Address, node.Config.Netmask, node.Config.Gateway)
-					err = os.WriteFile(filename, []byte(data), 0644)
-					fmt.Printf("STARTUP: %s\n", filename)
-					if err != nil {
-						return nil, fmt.Errorf("create VPCS startup file: %w", err)
-					}
-				}
-			default:
-			}
-			fmt.Printf("NODE: %s (%s) created\n", resp.Name, resp.NodeId)
-		} else {
-			fmt.Printf("NODE: %s (%s) exists\n", resp.Name, resp.NodeId)
+			return nil, fmt.Errorf("'%s': %w", filename, err)
+		}
+		values.Merge(more)
+	}
+	sets, _ := cmd.Flags().GetStringArray("set")
+	for _, set := range sets {
+		if err := values.Set(set); err != nil {
+			return nil, &usageError{err: err}
+		}
+	}
+	sets, _ = cmd.Flags().GetStringArray("set-string")
+	for _, set := range sets {
+		if err := values.SetString(set); err != nil {
+			return nil, &usageError{err: err}
 		}
 	}
+	return values, nil
+}
 
-	lctl := ctl.Links(project.ProjectId)
-	links, err := lctl.List()
-	if err != nil {
-		return nil, fmt.Errorf("%w: error listing links", err)
+// readFile reads a file, or stdin if the filename is "-".
+func readFile(filename string) ([]byte, error) {
+	if filename == "-" {
+		return io.ReadAll(os.Stdin)
 	}
+	return os.ReadFile(filename)
+}
 
-	presentLinks := make(map[linkIndex]linkState, len(links))
+// renderFile reads a file, or stdin if the filename is "-", and renders it
+// with the values.
+func renderFile(filename string, values gns3.Values) ([]byte, error) {
+	data, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return gns3.RenderNetwork(filepath.Base(filename), data, values)
+}
 
-	for _, link := range links {
-		if len(link.Nodes) != 2 {
-			continue
-		}
+// renderedError marks the problems found in a network document that was
+// changed by rendering, whose locations are in the rendered output rather
+// than the file.
+type renderedError struct {
+	err error
+}
 
-		aEnd := linkRef{
-			nodeId:  link.Nodes[0].NodeId,
-			port:    link.Nodes[0].PortNumber,
-			adapter: link.Nodes[0].AdapterNumber,
-		}
+func (r *renderedError) Error() string {
+	return r.err.Error()
+}
 
-		zEnd := linkRef{
-			nodeId:  link.Nodes[1].NodeId,
-			port:    link.Nodes[1].PortNumber,
-			adapter: link.Nodes[1].AdapterNumber,
-		}
+func (r *renderedError) Unwrap() error {
+	return r.err
+}
 
-		state := linkState{suspend: link.Suspend, linkId: link.LinkId}
-		presentLinks[linkIndex{aEnd: aEnd, zEnd: zEnd}] = state
+// readNetworks renders, parses and validates the YAML network documents in
+// a file, or stdin if the filename is "-".
+func readNetworks(filename string, values gns3.Values) ([]*gns3.Network, error) {
+	source, err := readFile(filename)
+	if err != nil {
+		return nil, err
 	}
+	data, err := gns3.RenderNetwork(filepath.Base(filename), source, values)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := gns3.ParseNetworks(bytes.NewReader(data))
+	if err != nil && !bytes.Equal(source, data) {
+		return nil, &renderedError{err: err}
+	}
+	return networks, err
+}
 
-	createLinks := make(map[linkIndex]linkInfo, len(network.Spec.Links))
-
-	var keep []string
-	for _, link := range network.Spec.Links {
-		a, err := nctl.Get(link.AEnd.Name)
-		if err != nil {
-			return nil, fmt.Errorf("unable to find a-end: %w", err)
+// forEachNetwork calls fn, in order, for each network document in the files
+// named by args, rendered with the values. Each failure is reported as it
+// happens and the failures are returned together. When failed is not nil it
+// is called for each file that could not be read or parsed.
+func forEachNetwork(args []string, values gns3.Values, fn func(source string, network *gns3.Network) error,
+	failed func(source string, err error)) error {
+	files, err := expandInputs(args)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	total := 0
+	for _, filename := range files {
+		source := filename
+		if filename == "-" {
+			source = "stdin"
 		}
-		z, err := nctl.Get(link.ZEnd.Name)
+		networks, err := readNetworks(filename, values)
 		if err != nil {
-			return nil, fmt.Errorf("unable to find z-end: %w", err)
-		}
-		aEnd := linkRef{nodeId: a.NodeId, port: link.AEnd.Port, adapter: link.AEnd.Adapter}
-		zEnd := linkRef{nodeId: z.NodeId, port: link.ZEnd.Port, adapter: link.ZEnd.Adapter}
-		key := linkIndex{aEnd: aEnd, zEnd: zEnd}
-		keyToggle := linkIndex{aEnd: zEnd, zEnd: aEnd}
-
-		state, ok := presentLinks[key]
-		if !ok {
-			state, ok = presentLinks[keyToggle]
-		}
-
-		if ok {
-			if state.suspend {
-				// resume this link
-				keep = append(keep, state.linkId)
+			total++
+			errs = append(errs, err)
+			printFileError(source, err)
+			if failed != nil {
+				failed(source, err)
 			}
-
-			delete(presentLinks, key)
-			delete(presentLinks, keyToggle)
-
-			fmt.Printf("Link: %s already exists. Suspended state: %v\n", state.linkId, state.suspend)
 			continue
 		}
-
-		createLinks[key] = linkInfo{aEndName: link.AEnd.Name, zEndName: link.ZEnd.Name}
-	}
-
-	// delete all invalid links not created for this project
-	for _, state := range presentLinks {
-		fmt.Printf("Deleting stale link: %s\n", state.linkId)
-		if _, err := lctl.Delete(state.linkId); err != nil {
-			fmt.Printf("Error %v deleting link %s\n", err, state.linkId)
+		for i, network := range networks {
+			total++
+			name := source
+			if len(networks) > 1 {
+				name = fmt.Sprintf("%s[%d]", source, i)
+			}
+			if err := fn(name, network); err != nil {
+				errs = append(errs, err)
+				printFileError(name, err)
+			}
 		}
 	}
+	return collectErrors(errs, total)
+}
 
-	for index, inf := range createLinks {
-		var resp *gns3.Link
-		var e error
-		resp, e = lctl.Create(&gns3.Link{ProjectId: project.ProjectId, LinkType: "ethernet", Suspend: true, Nodes: []gns3.NodeRef{
-			gns3.NodeRef{NodeId: index.aEnd.nodeId, AdapterNumber: index.aEnd.adapter, PortNumber: index.aEnd.port},
-			gns3.NodeRef{NodeId: index.zEnd.nodeId, AdapterNumber: index.zEnd.adapter, PortNumber: index.zEnd.port},
-		}})
-		if e != nil {
-			return nil, fmt.Errorf("link create: %w", e)
-		}
-
-		fmt.Printf("LINK: %s (%s-%s) created\n", resp.LinkId, inf.aEndName, inf.zEndName)
-		keep = append(keep, resp.LinkId)
+// loadNetwork converges the server to a network document. On a dry run the
+// plan is printed and no project is returned.
+func loadNetwork(ctx context.Context, ctl *gns3.Gns3, network *gns3.Network, opts *loadOptions) (*networkPlan, *gns3.Project, error) {
+	plan, err := computePlan(ctx, ctl, network, opts)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	// start all nodes
-	for _, node := range network.Spec.Nodes {
-		err := nctl.Start(node.Name)
-		if err != nil {
-			fmt.Printf("NODE: %s: start failed\n", node.Name)
-		} else {
-			fmt.Printf("NODE: %s: started\n", node.Name)
-		}
+	if opts.dryRun {
+		plan.print(os.Stdout)
+		return plan, nil, nil
 	}
+	project, err := plan.apply(ctx, ctl, opts)
+	return plan, project, err
+}
 
-	for _, id := range keep {
-		_, e := lctl.Resume(id)
-		if e != nil {
-			return nil, fmt.Errorf("resume failed: %w", e)
+// printLoadSummary displays a line for each network document loaded.
+func printLoadSummary(w io.Writer, results []loadResult, opts *loadOptions) {
+	tw := tabwriter.NewWriter(w, 0, 0, 4, ' ', 0)
+	defer tw.Flush()
+	fmt.Fprintln(tw, "PROJECT\tSOURCE\tRESULT\tCREATE\tUPDATE\tREPLACE\tDELETE")
+	for _, r := range results {
+		result := "loaded"
+		switch {
+		case r.err != nil:
+			result = "failed"
+		case r.plan.empty():
+			result = "unchanged"
+		case opts.dryRun:
+			result = "planned"
+		}
+		counts := map[planAction]int{}
+		if r.plan != nil {
+			counts = r.plan.counts()
 		}
+		name := "-"
+		if r.network != nil {
+			name = r.network.Metadata.Name
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\n", name, r.source, result,
+			counts[planCreate], counts[planUpdate], counts[planReplace], counts[planDelete])
 	}
-
-	return project, nil
 }
@@ -0,0 +1,560 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/ciena/gns3ctl/pkg/gns3/gns3test"
+	"github.com/spf13/viper"
+)
+
+const testNetwork = `
+apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - name: sw1
+      type: ethernet_switch
+      x: 0
+      y: 0
+    - name: pc1
+      type: vpcs
+      x: -100
+      y: 100
+    - name: pc2
+      type: vpcs
+      x: 100
+      y: 100
+  links:
+    - aEnd:
+        name: pc1
+      zEnd:
+        name: sw1
+    - aEnd:
+        name: pc2
+      zEnd:
+        name: sw1
+`
+
+// testServer starts a fake controller and points the global configuration
+// at it for the duration of the test.
+func testServer(t *testing.T) (*gns3test.Server, *gns3.Gns3) {
+	t.Helper()
+	s := gns3test.NewServer()
+	t.Cleanup(s.Close)
+	for key, value := range map[string]interface{}{
+		"address":               s.URL,
+		"username":              gns3test.DefaultUser,
+		"password":              gns3test.DefaultPassword,
+		"retry-initial-backoff": "1ms",
+	} {
+		old := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, old) })
+	}
+	ctl, err := connect()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return s, ctl
+}
+
+// parseNetwork parses a network document, applying edit to the text first.
+func parseNetwork(t *testing.T, doc string, edit ...string) *gns3.Network {
+	t.Helper()
+	doc = strings.NewReplacer(edit...).Replace(doc)
+	network, err := gns3.ParseNetwork(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return network
+}
+
+// planSummary returns the actions of the plan as "action kind name" lines.
+func planSummary(plan *networkPlan) []string {
+	var lines []string
+	for _, c := range plan.templateChanges {
+		lines = append(lines, string(c.action)+" template "+c.name)
+	}
+	for _, c := range plan.nodeChanges {
+		name := ""
+		if c.spec != nil {
+			name = c.spec.Name
+		} else {
+			name = c.live.Name
+		}
+		lines = append(lines, string(c.action)+" node "+name)
+	}
+	for _, c := range plan.linkChanges {
+		lines = append(lines, string(c.action)+" link "+c.desc)
+	}
+	return lines
+}
+
+func TestLoadCreatesNetwork(t *testing.T) {
+	s, ctl := testServer(t)
+	ctx := context.Background()
+
+	plan, project, err := loadNetwork(ctx, ctl, parseNetwork(t, testNetwork), &loadOptions{parallel: 1})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	counts := plan.counts()
+	if counts[planCreate] != 6 {
+		t.Errorf("got %d creates, want 6 (project, 3 nodes, 2 links): %v", counts[planCreate], planSummary(plan))
+	}
+
+	if projects := s.Projects(); len(projects) != 1 || projects[0].ProjectId != project.ProjectId {
+		t.Fatalf("projects: got %+v", projects)
+	}
+	var names []string
+	for _, n := range s.Nodes(project.ProjectId) {
+		names = append(names, n.Name)
+	}
+	if got := strings.Join(names, ","); got != "pc1,pc2,sw1" {
+		t.Errorf("nodes: got %s", got)
+	}
+	if links := s.Links(project.ProjectId); len(links) != 2 {
+		t.Errorf("links: got %d, want 2", len(links))
+	}
+
+	// Loading the same document again makes no changes
+	plan, err = computePlan(ctx, ctl, parseNetwork(t, testNetwork), &loadOptions{})
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if !plan.empty() {
+		t.Errorf("reload: got changes %v", planSummary(plan))
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	_, ctl := testServer(t)
+	ctx := context.Background()
+
+	// YAML 1.1 reads an unquoted y as a boolean
+	network := parseNetwork(t, testNetwork, "pc2", "y")
+	_, project, err := loadNetwork(ctx, ctl, network, &loadOptions{parallel: 1})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	exported, err := exportNetwork(ctx, ctl, project)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	doc, err := marshalNetwork(exported)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	plan, err := computePlan(ctx, ctl, parseNetwork(t, string(doc)), &loadOptions{})
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if !plan.empty() {
+		t.Errorf("exported network: got changes %v\n%s", planSummary(plan), doc)
+	}
+}
+
+func TestLoadDryRun(t *testing.T) {
+	s, ctl := testServer(t)
+
+	plan, project, err := loadNetwork(context.Background(), ctl, parseNetwork(t, testNetwork),
+		&loadOptions{dryRun: true})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if project != nil || len(s.Projects()) != 0 {
+		t.Errorf("dry run created a project")
+	}
+	if plan.empty() {
+		t.Errorf("dry run planned no changes")
+	}
+	for _, r := range s.Requests() {
+		if !strings.HasPrefix(r, "GET ") {
+			t.Errorf("dry run made request %s", r)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	const removed = `
+    - aEnd:
+        name: pc2
+      zEnd:
+        name: sw1
+`
+	for _, tc := range []struct {
+		name  string
+		edit  []string
+		prune bool
+		want  []string
+	}{
+		{
+			name: "unchanged",
+		},
+		{
+			name: "moved",
+			edit: []string{"x: -100", "x: -150"},
+			want: []string{"update node pc1"},
+		},
+		{
+			name: "replaced",
+			edit: []string{"type: ethernet_switch", "type: ethernet_hub"},
+			want: []string{
+				"replace node sw1",
+				"delete link pc1 0/0 <-> sw1 0/0", "delete link pc2 0/0 <-> sw1 0/1",
+				"create link pc1 0/0 <-> sw1 (auto)", "create link pc2 0/0 <-> sw1 (auto)",
+			},
+		},
+		{
+			name: "link removed",
+			edit: []string{removed, "\n"},
+			want: []string{"delete link pc2 0/0 <-> sw1 0/1"},
+		},
+		{
+			name: "node removed",
+			edit: []string{"    - name: pc2\n      type: vpcs\n      x: 100\n      y: 100\n", "", removed, "\n"},
+			want: []string{"delete link pc2 0/0 <-> sw1 0/1"},
+		},
+		{
+			name:  "node pruned",
+			edit:  []string{"    - name: pc2\n      type: vpcs\n      x: 100\n      y: 100\n", "", removed, "\n"},
+			prune: true,
+			want:  []string{"delete node pc2", "delete link pc2 0/0 <-> sw1 0/1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ctl := testServer(t)
+			ctx := context.Background()
+			if _, _, err := loadNetwork(ctx, ctl, parseNetwork(t, testNetwork), &loadOptions{parallel: 1}); err != nil {
+				t.Fatalf("load: %v", err)
+			}
+
+			plan, err := computePlan(ctx, ctl, parseNetwork(t, testNetwork, tc.edit...), &loadOptions{prune: tc.prune})
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if got, want := strings.Join(planSummary(plan), "\n"), strings.Join(tc.want, "\n"); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+
+			// Applying the plan converges the project to the document
+			if _, err := plan.apply(ctx, ctl, &loadOptions{prune: tc.prune, parallel: 1}); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			plan, err = computePlan(ctx, ctl, parseNetwork(t, testNetwork, tc.edit...), &loadOptions{prune: tc.prune})
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if !plan.empty() {
+				t.Errorf("after apply: got changes %v", planSummary(plan))
+			}
+		})
+	}
+}
+
+func TestForEachNetwork(t *testing.T) {
+	dir := t.TempDir()
+	second := strings.Replace(testNetwork, "name: lab", "name: lab2", 1)
+	files := map[string]string{
+		"a.yaml":     testNetwork + "---\n" + second,
+		"b.yml":      "apiVersion: ciena.io/v1\nkind: Network\nmetadata: {}\n",
+		"ignore.txt": "not a network",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sources []string
+	err := forEachNetwork([]string{dir}, gns3.Values{}, func(source string, network *gns3.Network) error {
+		sources = append(sources, source+"="+network.Metadata.Name)
+		return nil
+	}, func(source string, err error) {
+		sources = append(sources, source+"=failed")
+	})
+	want := filepath.Join(dir, "a.yaml") + "[0]=lab," + filepath.Join(dir, "a.yaml") + "[1]=lab2," +
+		filepath.Join(dir, "b.yml") + "=failed"
+	if got := strings.Join(sources, ","); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	var multi *multiError
+	if !errors.As(err, &multi) || len(multi.errs) != 1 || multi.total != 3 {
+		t.Fatalf("got error %v, want 1 of 3 failed", err)
+	}
+	if !errors.Is(err, gns3.ErrValidation) {
+		t.Errorf("got %v, want a validation error", err)
+	}
+}
+
+func TestStartNetworkFailures(t *testing.T) {
+	s, ctl := testServer(t)
+	ctx := context.Background()
+	network := parseNetwork(t, testNetwork)
+	_, project, err := loadNetwork(ctx, ctl, network, &loadOptions{parallel: 1})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	nctl := ctl.Nodes(project.ProjectId)
+	if err := nctl.StopAllContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	nodes := map[string]*gns3.Node{}
+	for _, n := range s.Nodes(project.ProjectId) {
+		n := n
+		nodes[n.Name] = &n
+	}
+
+	// nothing depends on pc2, so the rest of the network is started
+	s.Fail("POST", fmt.Sprintf("v2/projects/%s/nodes/%s/start", project.ProjectId, nodes["pc2"].NodeId),
+		http.StatusInternalServerError, 10)
+	err = startNetwork(ctx, nctl, network, nodes, nil, 1, nil)
+	var multi *multiError
+	if !errors.As(err, &multi) || len(multi.errs) != 1 || multi.total != 3 {
+		t.Fatalf("got error %v, want 1 of 3 failed", err)
+	}
+	if !strings.Contains(multi.errs[0].Error(), "node 'pc2'") || exitCode(err) == ExitOK {
+		t.Errorf("got %v, exit %d", multi.errs[0], exitCode(err))
+	}
+	for _, n := range s.Nodes(project.ProjectId) {
+		if want := n.Name != "pc2"; (n.Status == gns3test.StatusStarted) != want {
+			t.Errorf("node %s: status %s", n.Name, n.Status)
+		}
+	}
+}
+
+func TestPruneOrder(t *testing.T) {
+	_, ctl := testServer(t)
+	ctx := context.Background()
+	if _, _, err := loadNetwork(ctx, ctl, parseNetwork(t, testNetwork), &loadOptions{parallel: 1}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	empty := parseNetwork(t, "apiVersion: ciena.io/v1\nkind: Network\nmetadata:\n  name: lab\n")
+	for i := 0; i < 5; i++ {
+		plan, err := computePlan(ctx, ctl, empty, &loadOptions{prune: true})
+		if err != nil {
+			t.Fatalf("plan: %v", err)
+		}
+		want := "delete node pc1\ndelete node pc2\ndelete node sw1\n" +
+			"delete link pc1 0/0 <-> sw1 0/0\ndelete link pc2 0/0 <-> sw1 0/1"
+		if got := strings.Join(planSummary(plan), "\n"); got != want {
+			t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func TestPrintLoadSummary(t *testing.T) {
+	network := parseNetwork(t, testNetwork)
+	results := []loadResult{
+		{source: "a.yaml", network: network, plan: &networkPlan{network: network}},
+		{source: "b.yaml", err: errors.New("parse error")},
+	}
+	var buf strings.Builder
+	printLoadSummary(&buf, results, &loadOptions{})
+	want := `PROJECT    SOURCE    RESULT    CREATE    UPDATE    REPLACE    DELETE
+lab        a.yaml    loaded    1         0         0          0
+-          b.yaml    failed    0         0         0          0
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestReadNetworksRendered(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"plain.yaml":    "apiVersion: ciena.io/v1\nkind: Network\nmetadata: {}\n",
+		"rendered.yaml": "apiVersion: ciena.io/v1\nkind: Network\n{{/* a comment */}}\nmetadata: {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rendered *renderedError
+	_, err := readNetworks(filepath.Join(dir, "plain.yaml"), gns3.Values{})
+	if err == nil || errors.As(err, &rendered) {
+		t.Errorf("plain: got %v, want an error not marked as rendered", err)
+	}
+	_, err = readNetworks(filepath.Join(dir, "rendered.yaml"), gns3.Values{})
+	if !errors.As(err, &rendered) || !errors.Is(err, gns3.ErrValidation) {
+		t.Errorf("rendered: got %v, want a validation error marked as rendered", err)
+	}
+}
+
+func TestCloudInitImageRemoved(t *testing.T) {
+	const doc = `
+apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - name: vm1
+      type: qemu
+      cloudInit:
+        hostname: vm1
+`
+	s, ctl := testServer(t)
+	ctx := context.Background()
+	if _, err := ctl.Computes().Create(&gns3.Compute{ComputeId: "remote"}); err != nil {
+		t.Fatalf("create compute: %v", err)
+	}
+
+	_, project, err := loadNetwork(ctx, ctl, parseNetwork(t, doc), &loadOptions{parallel: 1})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	nodes := s.Nodes(project.ProjectId)
+	if len(nodes) != 1 {
+		t.Fatalf("nodes: got %+v", nodes)
+	}
+	first := nodes[0]
+	if _, ok := s.File(first.NodeId, gns3.CloudInitImage); !ok {
+		t.Fatalf("cloud-init image not written to the node")
+	}
+	if got, want := first.Properties["cdrom_image"], first.NodeDirectory+"/"+gns3.CloudInitImage; got != want {
+		t.Errorf("cdrom_image: got %v, want %s", got, want)
+	}
+
+	// the image of a replaced node goes with it
+	_, _, err = loadNetwork(ctx, ctl, parseNetwork(t, doc, "type: qemu", "type: qemu\n      compute_id: remote"),
+		&loadOptions{parallel: 1})
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if _, ok := s.File(first.NodeId, gns3.CloudInitImage); ok {
+		t.Errorf("cloud-init image of the replaced node left behind")
+	}
+	nodes = s.Nodes(project.ProjectId)
+	if len(nodes) != 1 || nodes[0].NodeId == first.NodeId {
+		t.Fatalf("nodes after replace: got %+v", nodes)
+	}
+	second := nodes[0]
+	if _, ok := s.File(second.NodeId, gns3.CloudInitImage); !ok {
+		t.Errorf("cloud-init image not written to the new node")
+	}
+
+	// and so does that of a pruned node
+	_, _, err = loadNetwork(ctx, ctl, parseNetwork(t, doc, "vm1", "vm2"),
+		&loadOptions{prune: true, parallel: 1})
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if _, ok := s.File(second.NodeId, gns3.CloudInitImage); ok {
+		t.Errorf("cloud-init image of the pruned node left behind")
+	}
+}
+
+func TestLoadAddresses(t *testing.T) {
+	const ipam = `
+  ipam:
+    pools:
+      - name: uplink
+        subnet: 10.255.0.0/30
+        prefix: 31
+        links: [pc1, sw1]
+      - name: lan
+        subnet: 10.0.0.0/24
+        gateway: 10.0.0.1
+        nodes: [pc1, pc2]
+`
+	const pc2 = "    - name: pc2\n      type: vpcs\n      x: 100\n      y: 100\n"
+	s, ctl := testServer(t)
+	ctx := context.Background()
+	s.AddTemplate(gns3.Template{Name: "PC", TemplateType: "vpcs"})
+
+	recorded := func(project *gns3.Project) string {
+		t.Helper()
+		addresses, err := readAddresses(ctx, ctl, project)
+		if err != nil {
+			t.Fatalf("read addresses: %v", err)
+		}
+		var lines []string
+		for _, addr := range addresses {
+			lines = append(lines, strings.Join([]string{addr.Node, addr.Pool, addr.Address, addr.Interface}, " "))
+		}
+		return strings.Join(lines, "\n")
+	}
+	startup := func(project *gns3.Project, name string) string {
+		t.Helper()
+		for _, n := range s.Nodes(project.ProjectId) {
+			if n.Name == name {
+				data, _ := s.File(n.NodeId, "startup.vpc")
+				return string(data)
+			}
+		}
+		t.Fatalf("node %s not found", name)
+		return ""
+	}
+
+	// a node made from a vpcs template is configured with its address, and
+	// the ports picked for links are recorded
+	network := parseNetwork(t, testNetwork+ipam, pc2, "    - name: pc2\n      template: PC\n")
+	_, project, err := loadNetwork(ctx, ctl, network, &loadOptions{parallel: 1})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := `sw1 uplink 10.255.0.1/31 Ethernet0
+pc1 uplink 10.255.0.0/31 Ethernet0
+pc1 lan 10.0.0.2/24 
+pc2 lan 10.0.0.3/24 `
+	if got := recorded(project); got != want {
+		t.Errorf("recorded:\n%s\nwant:\n%s", got, want)
+	}
+	if got := startup(project, "pc2"); !strings.Contains(got, "ip 10.0.0.3 255.255.255.0 10.0.0.1\n") {
+		t.Errorf("pc2 startup.vpc:\n%s", got)
+	}
+
+	// the nodes that remain keep their addresses
+	network = parseNetwork(t, testNetwork+ipam, pc2, "",
+		"    - aEnd:\n        name: pc2\n      zEnd:\n        name: sw1\n", "",
+		"nodes: [pc1, pc2]", "nodes: [pc3, pc1]",
+		"    - name: pc1\n", "    - name: pc3\n      type: vpcs\n    - name: pc1\n")
+	if _, _, err := loadNetwork(ctx, ctl, network, &loadOptions{prune: true, parallel: 1}); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	want = `sw1 uplink 10.255.0.1/31 Ethernet0
+pc3 lan 10.0.0.3/24 
+pc1 uplink 10.255.0.0/31 Ethernet0
+pc1 lan 10.0.0.2/24 `
+	if got := recorded(project); got != want {
+		t.Errorf("reloaded:\n%s\nwant:\n%s", got, want)
+	}
+
+	// and once the pools are removed, so are the addresses
+	if _, _, err := loadNetwork(ctx, ctl, parseNetwork(t, testNetwork), &loadOptions{parallel: 1}); err != nil {
+		t.Fatalf("load without ipam: %v", err)
+	}
+	if got := recorded(project); got != "" {
+		t.Errorf("without ipam:\n%s", got)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// nodeOperation is a change of state applied by the node commands, such as
+// start nodes. The operation on every node is nil when the controller has
+// no bulk endpoint for it.
+type nodeOperation struct {
+	done string
+	one  func(*gns3.Nodes, context.Context, string) error
+	all  func(*gns3.Nodes, context.Context) error
+}
+
+// addNodeOperationFlags adds the flags used by runNodeOperation.
+func addNodeOperationFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all", false, "apply to every node of the project")
+	cmd.Flags().Int("parallel", 1, "number of nodes to apply to at the same time")
+}
+
+// runNodeOperation applies op to the nodes named by args, or to every node
+// of the project with --all, and prints the outcome for each node. The
+// nodes are listed once, up front, and --parallel of them are changed at a
+// time. With --all the controller's bulk endpoint is used if there is one,
+// unless --parallel is given, when the nodes are changed one by one.
+func runNodeOperation(cmd *cobra.Command, args []string, op nodeOperation) error {
+	pname := viper.GetString("project")
+	if pname == "" {
+		return ErrNoProjectSpecified
+	}
+	all, _ := cmd.Flags().GetBool("all")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	switch {
+	case all && len(args) > 0:
+		return &usageError{err: errors.New("nodes cannot be given with --all")}
+	case !all && len(args) == 0:
+		return &usageError{err: errors.New("at least one node, or --all, must be given")}
+	case parallel < 1:
+		return &usageError{err: fmt.Errorf("--parallel must be at least 1, not %d", parallel)}
+	}
+
+	ctl, err := connect()
+	if err != nil {
+		return err
+	}
+	project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+	if err != nil {
+		return fmt.Errorf("project '%s' not found: %w", pname, err)
+	}
+	nctl := ctl.Nodes(project.ProjectId)
+
+	if all && op.all != nil && !cmd.Flags().Changed("parallel") {
+		if err := op.all(nctl, cmd.Context()); err != nil {
+			return err
+		}
+		nodes, err := nctl.ListContext(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to retrieve nodes: %w", err)
+		}
+		for _, node := range nodes {
+			fmt.Printf("%s %s\n", node.Name, node.Status)
+		}
+		return nil
+	}
+
+	nodes, err := nctl.ListContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("unable to retrieve nodes: %w", err)
+	}
+	ids := map[string]string{}
+	for _, node := range nodes {
+		ids[node.NodeId] = node.NodeId
+		ids[node.Name] = node.NodeId
+		if all {
+			args = append(args, node.Name)
+		}
+	}
+
+	errs := runParallel(cmd.Context(), args, parallel, func(ctx context.Context, no string) error {
+		id, ok := ids[no]
+		if !ok {
+			return gns3.ErrNotFound
+		}
+		return op.one(nctl, ctx, id)
+	})
+	var failed []error
+	for i, no := range args {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			fmt.Printf("%s => %v\n", no, errs[i])
+		} else {
+			fmt.Printf("%s %s\n", no, op.done)
+		}
+	}
+	return collectErrors(failed, len(args))
+}
+
+// runParallel calls fn for each item, with at most parallel calls running
+// at a time, and returns the error from each call in the order of items.
+func runParallel(ctx context.Context, items []string, parallel int, fn func(context.Context, string) error) []error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(ctx, item)
+		}(i, item)
+	}
+	wg.Wait()
+	return errs
+}
+
+// startNetwork starts the nodes of a network in the order of their
+// dependencies, a wave at a time, with up to parallel nodes of a wave
+// starting together. After each wave started, if it is not nil, is called
+// with the nodes started so far. The nodes of the wave that have readiness
+// checks, or that other nodes depend on, are then waited for, as are the
+// nodes named in configure, whose configuration is delivered once they are
+// ready. Nodes that others depend on must start and become ready for the
+// rest of the network to be started, the others are carried on past and
+// their start failures returned together once the network is started.
+func startNetwork(ctx context.Context, nctl *gns3.Nodes, network *gns3.Network, nodes map[string]*gns3.Node,
+	configure map[string]bool, parallel int, started func(map[string]bool) error) error {
+	waves, err := network.StartOrder()
+	if err != nil {
+		return err
+	}
+	dependedOn := map[string]bool{}
+	for _, node := range network.Spec.Nodes {
+		for _, dep := range node.DependsOn {
+			dependedOn[dep] = true
+		}
+	}
+
+	up := map[string]bool{}
+	var startFailed []error
+	for _, wave := range waves {
+		ids := make([]string, len(wave))
+		for i, node := range wave {
+			live, ok := nodes[node.Name]
+			if !ok {
+				return fmt.Errorf("node '%s': %w", node.Name, gns3.ErrNotFound)
+			}
+			ids[i] = live.NodeId
+		}
+		errs := runParallel(ctx, ids, parallel, nctl.StartContext)
+
+		var wait []*gns3.NetworkNode
+		var waitIDs []string
+		for i, node := range wave {
+			if errs[i] != nil {
+				fmt.Printf("NODE: %s: start failed: %v\n", node.Name, errs[i])
+				if dependedOn[node.Name] {
+					return fmt.Errorf("node '%s' must start before the nodes that depend on it: %w", node.Name, errs[i])
+				}
+				startFailed = append(startFailed, fmt.Errorf("node '%s': start: %w", node.Name, errs[i]))
+				continue
+			}
+			fmt.Printf("NODE: %s: started\n", node.Name)
+			up[node.Name] = true
+			if node.Readiness != nil || dependedOn[node.Name] || configure[node.Name] {
+				wait = append(wait, node)
+				waitIDs = append(waitIDs, ids[i])
+			}
+		}
+		if started != nil {
+			if err := started(up); err != nil {
+				return err
+			}
+		}
+
+		specs := make(map[string]*gns3.NetworkNode, len(wait))
+		for i, node := range wait {
+			specs[waitIDs[i]] = node
+		}
+		errs = runParallel(ctx, waitIDs, len(waitIDs), func(ctx context.Context, id string) error {
+			spec := specs[id]
+			if err := nctl.WaitReadyContext(ctx, id, spec.Readiness); err != nil || !configure[spec.Name] {
+				return err
+			}
+			return nctl.ConfigureContext(ctx, nodes[spec.Name], spec)
+		})
+		var failed []error
+		for i, node := range wait {
+			if errs[i] != nil {
+				failed = append(failed, errs[i])
+				fmt.Printf("NODE: %s: %v\n", node.Name, errs[i])
+				continue
+			}
+			fmt.Printf("NODE: %s: ready\n", node.Name)
+			if configure[node.Name] {
+				nodeType := nodes[node.Name].NodeType
+				fmt.Printf("CONFIG: %s (%s)\n", node.Name, node.ConfigFor(nodeType).MethodFor(nodeType))
+			}
+		}
+		if err := collectErrors(append(startFailed, failed...), len(network.Spec.Nodes)); err != nil {
+			return err
+		}
+	}
+	return collectErrors(startFailed, len(network.Spec.Nodes))
+}
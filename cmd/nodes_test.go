@@ -0,0 +1,230 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/ciena/gns3ctl/pkg/gns3/gns3test"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// execute runs the command line given by args, then returns the flags of
+// every command to their defaults so they do not leak into other tests.
+func execute(t *testing.T, args ...string) error {
+	t.Helper()
+	wrapArgs(rootCmd)
+	rootCmd.SetArgs(args)
+	defer rootCmd.SetArgs(nil)
+	defer resetFlags(rootCmd)
+	return rootCmd.ExecuteContext(context.Background())
+}
+
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			_ = slice.Replace(nil)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// bodyRecorder sits in front of a fake controller and records the bodies
+// of the PUT requests sent to it.
+type bodyRecorder struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+}
+
+func recordBodies(t *testing.T, s *gns3test.Server) *bodyRecorder {
+	t.Helper()
+	rec := &bodyRecorder{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		if r.Method == http.MethodPut {
+			var body map[string]interface{}
+			_ = json.Unmarshal(data, &body)
+			rec.mu.Lock()
+			rec.bodies = append(rec.bodies, body)
+			rec.mu.Unlock()
+		}
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(proxy.Close)
+	old := viper.Get("address")
+	viper.Set("address", proxy.URL)
+	t.Cleanup(func() { viper.Set("address", old) })
+	return rec
+}
+
+// testProject creates a project named lab holding nodes.
+func testProject(t *testing.T, ctl *gns3.Gns3, nodes ...*gns3.Node) *gns3.Project {
+	t.Helper()
+	project, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes {
+		node.ComputeId = "local"
+		if _, err := ctl.Nodes(project.ProjectId).Create(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return project
+}
+
+func TestSetNode(t *testing.T) {
+	s, ctl := testServer(t)
+	project := testProject(t, ctl, &gns3.Node{Name: "r1", NodeType: "qemu", X: 10, Y: 20,
+		Properties: map[string]interface{}{"adapters": 2, "ram": 256}})
+	rec := recordBodies(t, s)
+
+	if err := execute(t, "set", "node", "-p", "lab", "r1"); exitCode(err) != ExitUsage {
+		t.Errorf("nothing to set: got %v, exit %d", err, exitCode(err))
+	}
+
+	err := execute(t, "set", "node", "-p", "lab", "r1", "--x", "100", "--property", "ram=512", "--property", "cpus=2")
+	if err != nil {
+		t.Fatalf("set node: %v", err)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.bodies) != 1 {
+		t.Fatalf("got %d updates, want 1", len(rec.bodies))
+	}
+	body := rec.bodies[0]
+	if len(body) != 2 || body["x"] != float64(100) {
+		t.Errorf("update: got %v, want only x and properties", body)
+	}
+	if props, _ := body["properties"].(map[string]interface{}); len(props) != 2 ||
+		props["ram"] != float64(512) || props["cpus"] != float64(2) {
+		t.Errorf("update properties: got %v", body["properties"])
+	}
+
+	live := s.Nodes(project.ProjectId)[0]
+	if live.X != 100 || live.Y != 20 || live.Name != "r1" {
+		t.Errorf("node: got %s at %d,%d", live.Name, live.X, live.Y)
+	}
+	if live.Properties["adapters"] != float64(2) || live.Properties["ram"] != float64(512) || live.Properties["cpus"] != float64(2) {
+		t.Errorf("properties not merged: %v", live.Properties)
+	}
+}
+
+func TestNodeOperationAll(t *testing.T) {
+	s, ctl := testServer(t)
+	project := testProject(t, ctl, &gns3.Node{Name: "pc1", NodeType: "vpcs"}, &gns3.Node{Name: "pc2", NodeType: "vpcs"})
+	nodesPath := "v2/projects/" + project.ProjectId + "/nodes"
+	// posts returns the POST requests made by run
+	posts := func(run func() error) []string {
+		t.Helper()
+		from := len(s.Requests())
+		if err := run(); err != nil {
+			t.Fatal(err)
+		}
+		var list []string
+		for _, r := range s.Requests()[from:] {
+			if strings.HasPrefix(r, "POST ") {
+				list = append(list, strings.TrimPrefix(r, "POST "+nodesPath))
+			}
+		}
+		sort.Strings(list)
+		return list
+	}
+
+	got := posts(func() error { return execute(t, "start", "nodes", "-p", "lab", "--all") })
+	if want := "/start"; strings.Join(got, ",") != want {
+		t.Errorf("--all: got %v, want %s", got, want)
+	}
+
+	var want []string
+	for _, n := range s.Nodes(project.ProjectId) {
+		want = append(want, "/"+n.NodeId+"/stop")
+	}
+	sort.Strings(want)
+	got = posts(func() error { return execute(t, "stop", "nodes", "-p", "lab", "--all", "--parallel", "2") })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("--all --parallel: got %v, want %v", got, want)
+	}
+	for _, n := range s.Nodes(project.ProjectId) {
+		if n.Status != gns3test.StatusStopped {
+			t.Errorf("node %s: status %s", n.Name, n.Status)
+		}
+	}
+
+	err := execute(t, "start", "nodes", "-p", "lab", "pc1", "pc3")
+	if exitCode(err) != ExitNotFound {
+		t.Errorf("unknown node: got %v, exit %d, want %d", err, exitCode(err), ExitNotFound)
+	}
+	if n, _ := ctl.Nodes(project.ProjectId).Get("pc1"); n == nil || n.Status != gns3test.StatusStarted {
+		t.Errorf("pc1 not started alongside the unknown node: %+v", n)
+	}
+}
+
+func TestWait(t *testing.T) {
+	_, ctl := testServer(t)
+	project := testProject(t, ctl, &gns3.Node{Name: "pc1", NodeType: "vpcs"}, &gns3.Node{Name: "pc2", NodeType: "vpcs"})
+	nctl := ctl.Nodes(project.ProjectId)
+
+	err := execute(t, "wait", "nodes", "-p", "lab", "pc1", "--timeout", "200ms")
+	if exitCode(err) != ExitNotReady {
+		t.Errorf("wait nodes: got %v, exit %d, want %d", err, exitCode(err), ExitNotReady)
+	}
+	if err := nctl.Start("pc1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := execute(t, "wait", "nodes", "-p", "lab", "pc1", "--timeout", "5s"); err != nil {
+		t.Errorf("wait nodes: %v", err)
+	}
+
+	err = execute(t, "wait", "project", "lab", "--timeout", "200ms")
+	if exitCode(err) != ExitNotReady {
+		t.Errorf("wait project: got %v, exit %d, want %d", err, exitCode(err), ExitNotReady)
+	}
+	// the last node starts while the project is waited for
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = nctl.Start("pc2")
+	}()
+	start := time.Now()
+	if err := execute(t, "wait", "project", "lab", "--for", "all-started", "--timeout", "10s"); err != nil {
+		t.Errorf("wait project: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("wait project: took %v", elapsed)
+	}
+}
@@ -18,7 +18,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 )
 
@@ -30,22 +29,29 @@ var openProjectsCmd = &cobra.Command{
 	Aliases: []string{"project", "proj", "pr"},
 	Args:    cobra.MinimumNArgs(1),
 	Short:   "Open one or more projects on the GNS3 server",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctl := gns3.Connect()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
 		projects := ctl.Projects()
+		var errs []error
 		for _, id := range args {
-			p, err := projects.Get(id)
+			p, err := projects.GetContext(cmd.Context(), id)
 			if err != nil {
 				fmt.Printf("NOT FOUND: %s: %v\n", id, err)
+				errs = append(errs, err)
 				continue
 			}
-			err = projects.Open(p.ProjectId)
+			err = projects.OpenContext(cmd.Context(), p.ProjectId)
 			if err != nil {
 				fmt.Printf("ERROR: unable to open '%s': %v\n", id, err)
+				errs = append(errs, err)
 				continue
 			}
 			fmt.Println(p.ProjectId)
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
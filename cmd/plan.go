@@ -0,0 +1,1023 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/viper"
+)
+
+type planAction string
+
+const (
+	planCreate  planAction = "create"
+	planUpdate  planAction = "update"
+	planReplace planAction = "replace"
+	planDelete  planAction = "delete"
+)
+
+var planSymbols = map[planAction]string{
+	planCreate:  "+",
+	planUpdate:  "~",
+	planReplace: "-/+",
+	planDelete:  "-",
+}
+
+// loadOptions controls how a network document is reconciled with the server.
+type loadOptions struct {
+	dryRun   bool
+	prune    bool
+	layout   string
+	parallel int
+}
+
+// applianceRef is an appliance referenced from a network document, read
+// into memory so that it can be inspected while planning.
+type applianceRef struct {
+	reference string
+	data      []byte
+	appliance gns3.Appliance
+}
+
+type templateChange struct {
+	action    planAction
+	name      string
+	reference string
+}
+
+type nodeChange struct {
+	action  planAction
+	spec    *gns3.NetworkNode
+	live    *gns3.Node
+	update  *gns3.NodeUpdate
+	reasons []string
+}
+
+type linkChange struct {
+	action planAction
+	spec   *gns3.NetworkLink
+	live   *gns3.Link
+	desc   string
+}
+
+// networkPlan is the set of changes required to converge the server to a
+// network document.
+type networkPlan struct {
+	network    *gns3.Network
+	project    *gns3.Project
+	appliances []*applianceRef
+	templates  map[string]*gns3.Template
+	liveNodes  map[string]*gns3.Node
+
+	templateChanges []templateChange
+	nodeChanges     []nodeChange
+	linkChanges     []linkChange
+
+	// unchanged nodes and links that are present as specified
+	keepNodes []*gns3.Node
+	keepLinks []*gns3.Link
+
+	// links are the links of the network, by index, with the ends of those
+	// that are present as they are on the server and the ports of the
+	// others resolved as they are created
+	links []*gns3.NetworkLink
+	// recorded is true if the project has a file of addresses, which is
+	// kept up to date even once there are none
+	recorded bool
+}
+
+// readAppliance reads an appliance from a local file or http(s) URL.
+func readAppliance(ctx context.Context, ref string) ([]byte, error) {
+	// Attempt to parse as url
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme == "" {
+		// not able to parse the URL, or contains
+		// no schema, then assume a local file reference
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", ref, err)
+		}
+		return data, nil
+	}
+	if !strings.HasPrefix(strings.ToLower(u.Scheme), "http") {
+		return nil, fmt.Errorf("unsupported appliance URL '%s'", ref)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch appliance '%s': %w", ref, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch appliance '%s': %w", ref, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("unable to fetch appliance '%s': %s", ref, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// specComputeID returns the compute of a node in the network document,
+// defaulting to the compute flag.
+func specComputeID(node *gns3.NetworkNode) string {
+	if node.ComputeId != "" {
+		return node.ComputeId
+	}
+	return viper.GetString("compute")
+}
+
+func specLinkDesc(link *gns3.NetworkLink) string {
+	return fmt.Sprintf("%s <-> %s", link.AEnd, link.ZEnd)
+}
+
+// portKey identifies a port of a node by the node's name.
+type portKey struct {
+	name          string
+	adapter, port int
+}
+
+func endKey(end gns3.LinkEnd) portKey {
+	adapter, port := end.Numbers()
+	return portKey{name: end.Name, adapter: adapter, port: port}
+}
+
+// liveLinkEnds returns the ends of a link on the server, named after their
+// nodes, or false if the link is not between two known nodes.
+func liveLinkEnds(link *gns3.Link, names map[string]string) (gns3.LinkEnd, gns3.LinkEnd, bool) {
+	if len(link.Nodes) != 2 {
+		return gns3.LinkEnd{}, gns3.LinkEnd{}, false
+	}
+	var ends [2]gns3.LinkEnd
+	for i, n := range link.Nodes {
+		name, ok := names[n.NodeId]
+		if !ok {
+			return gns3.LinkEnd{}, gns3.LinkEnd{}, false
+		}
+		ends[i] = gns3.FixedLinkEnd(name, n.AdapterNumber, n.PortNumber)
+	}
+	return ends[0], ends[1], true
+}
+
+// endMatches returns true if a live link end satisfies a link end from a
+// network document, which matches any port of the node if it is not fixed.
+func endMatches(spec, live gns3.LinkEnd) bool {
+	if spec.Name != live.Name {
+		return false
+	}
+	return !spec.Fixed() || endKey(spec) == endKey(live)
+}
+
+// portName returns the name of the port of a node used by a fixed link
+// end, or its adapter and port numbers if the node does not name it.
+func portName(node *gns3.Node, end gns3.LinkEnd) string {
+	adapter, port := end.Numbers()
+	for _, p := range node.Ports {
+		if p.AdapterNumber == adapter && p.PortNumber == port && p.Name != "" {
+			return p.Name
+		}
+	}
+	return fmt.Sprintf("%d/%d", adapter, port)
+}
+
+// portAllocator resolves link ends that give an interface name, or no port
+// at all, to ports of the nodes whose ports are known.
+type portAllocator struct {
+	ports map[string][]*gns3.Port
+	used  map[portKey]bool
+}
+
+func newPortAllocator() *portAllocator {
+	return &portAllocator{ports: map[string][]*gns3.Port{}, used: map[portKey]bool{}}
+}
+
+// use marks the port of a fixed link end as in use.
+func (a *portAllocator) use(end gns3.LinkEnd) {
+	if end.Fixed() {
+		a.used[endKey(end)] = true
+	}
+}
+
+// named resolves a link end that gives an interface name.
+func (a *portAllocator) named(end gns3.LinkEnd) (gns3.LinkEnd, error) {
+	ports, ok := a.ports[end.Name]
+	if end.Fixed() || end.Interface == "" || !ok {
+		return end, nil
+	}
+	for _, p := range ports {
+		if strings.EqualFold(p.Name, end.Interface) || strings.EqualFold(p.ShortName, end.Interface) {
+			return gns3.FixedLinkEnd(end.Name, p.AdapterNumber, p.PortNumber), nil
+		}
+	}
+	return end, &gns3.NetworkError{Message: fmt.Sprintf("node '%s' has no interface '%s'", end.Name, end.Interface)}
+}
+
+// pick resolves a link end that gives no port to the first free port of
+// the node.
+func (a *portAllocator) pick(end gns3.LinkEnd) (gns3.LinkEnd, error) {
+	ports, ok := a.ports[end.Name]
+	if end.Fixed() || end.Interface != "" || !ok {
+		return end, nil
+	}
+	for _, p := range ports {
+		key := portKey{name: end.Name, adapter: p.AdapterNumber, port: p.PortNumber}
+		if !a.used[key] {
+			a.used[key] = true
+			return gns3.FixedLinkEnd(end.Name, p.AdapterNumber, p.PortNumber), nil
+		}
+	}
+	return end, &gns3.NetworkError{Message: fmt.Sprintf("node '%s' is out of ports (%d in use)", end.Name, len(ports))}
+}
+
+// resolve resolves both ends of each link, first those that give an
+// interface name, so that their ports are not picked for other links.
+func (a *portAllocator) resolve(links []*gns3.NetworkLink) error {
+	var err error
+	for _, link := range links {
+		if link.AEnd, err = a.named(link.AEnd); err != nil {
+			return err
+		}
+		if link.ZEnd, err = a.named(link.ZEnd); err != nil {
+			return err
+		}
+		a.use(link.AEnd)
+		a.use(link.ZEnd)
+	}
+	for _, link := range links {
+		if link.AEnd, err = a.pick(link.AEnd); err != nil {
+			return err
+		}
+		if link.ZEnd, err = a.pick(link.ZEnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plannedPorts returns the number of ports a node that is yet to be created
+// will have, and false if it is not known until the node is created. NAT
+// and VPCS nodes have a single port, QEMU and Docker nodes one for each of
+// the adapters given by the node's properties or its template.
+func plannedPorts(spec *gns3.NetworkNode, template *gns3.Template) (int, bool) {
+	nodeType := spec.Type
+	if template != nil {
+		nodeType = template.TemplateType
+	}
+	switch strings.ToLower(nodeType) {
+	case gns3.TypeNat, gns3.TypeVpcs:
+		return 1, true
+	case gns3.TemplateTypeQemu, gns3.TemplateTypeDocker:
+		switch adapters := spec.Properties["adapters"].(type) {
+		case int:
+			return adapters, adapters > 0
+		case float64:
+			return int(adapters), adapters > 0
+		}
+		switch {
+		case template != nil && template.Qemu != nil && template.Qemu.Adapters > 0:
+			return template.Qemu.Adapters, true
+		case template != nil && template.Docker != nil && template.Docker.Adapters > 0:
+			return template.Docker.Adapters, true
+		}
+	}
+	return 0, false
+}
+
+// checkPlannedPorts returns an error if the links use more ports of a node
+// that is yet to be created than it will have, as given by ports.
+func checkPlannedPorts(ports map[string]int, links []*gns3.NetworkLink) error {
+	inUse := map[string]int{}
+	for _, link := range links {
+		for _, name := range []string{link.AEnd.Name, link.ZEnd.Name} {
+			available, ok := ports[name]
+			if !ok {
+				continue
+			}
+			if inUse[name]++; inUse[name] > available {
+				return &gns3.NetworkError{Message: fmt.Sprintf("node '%s' is out of ports (%d in use)", name, available)}
+			}
+		}
+	}
+	return nil
+}
+
+// computePlan compares a network document to the state of the server.
+func computePlan(ctx context.Context, ctl *gns3.Gns3, network *gns3.Network, opts *loadOptions) (*networkPlan, error) {
+	plan := &networkPlan{
+		network:   network,
+		templates: map[string]*gns3.Template{},
+		liveNodes: map[string]*gns3.Node{},
+	}
+
+	project, err := ctl.Projects().GetContext(ctx, network.Metadata.Name)
+	if err != nil && !errors.Is(err, gns3.ErrNotFound) {
+		return nil, err
+	}
+	plan.project = project
+
+	// Nodes keep the addresses recorded by the last load
+	if project != nil {
+		previous, err := readAddresses(ctx, ctl, project)
+		switch {
+		case errors.Is(err, gns3.ErrNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			plan.recorded = true
+			if err := network.KeepAddresses(previous); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Templates are global to the server, so they are only ever created
+	templates, err := ctl.Templates().ListContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list templates: %w", err)
+	}
+	for i := range templates {
+		plan.templates[templates[i].Name] = &templates[i]
+		plan.templates[templates[i].TemplateId] = &templates[i]
+	}
+	planned := map[string]bool{}
+	for _, ref := range network.Spec.Appliances {
+		data, err := readAppliance(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		app := &applianceRef{reference: ref, data: data}
+		if err := json.Unmarshal(data, &app.appliance); err != nil {
+			return nil, fmt.Errorf("'%s': %w", ref, err)
+		}
+		plan.appliances = append(plan.appliances, app)
+		name := app.appliance.TemplateName()
+		if _, ok := plan.templates[name]; !ok && !planned[name] {
+			planned[name] = true
+			plan.templateChanges = append(plan.templateChanges,
+				templateChange{action: planCreate, name: name, reference: ref})
+		}
+	}
+
+	var liveLinks []*gns3.Link
+	if project != nil {
+		nodes, err := ctl.Nodes(project.ProjectId).ListContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list nodes: %w", err)
+		}
+		for _, n := range nodes {
+			plan.liveNodes[n.Name] = n
+		}
+		liveLinks, err = ctl.Links(project.ProjectId).ListContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%w: error listing links", err)
+		}
+	}
+
+	// Nodes without coordinates stay where they are on the server, the
+	// rest are laid out
+	for i := range network.Spec.Nodes {
+		spec := &network.Spec.Nodes[i]
+		if live, ok := plan.liveNodes[spec.Name]; ok {
+			if spec.X == nil {
+				x := live.X
+				spec.X = &x
+			}
+			if spec.Y == nil {
+				y := live.Y
+				spec.Y = &y
+			}
+		}
+	}
+	if err := network.Layout(opts.layout, false); err != nil {
+		return nil, err
+	}
+
+	// Nodes that are deleted, or replaced, lose their links
+	gone := map[string]bool{}
+	newPorts := map[string]int{}
+	inSpec := map[string]bool{}
+	for i := range network.Spec.Nodes {
+		spec := &network.Spec.Nodes[i]
+		inSpec[spec.Name] = true
+
+		var template *gns3.Template
+		if spec.Template != "" {
+			template = plan.templates[spec.Template]
+			if template == nil && !planned[spec.Template] {
+				return nil, fmt.Errorf("node '%s': unknown template '%s': %w", spec.Name, spec.Template, gns3.ErrNotFound)
+			}
+		}
+
+		live, ok := plan.liveNodes[spec.Name]
+		if !ok {
+			if ports, known := plannedPorts(spec, template); known {
+				newPorts[spec.Name] = ports
+			}
+			plan.nodeChanges = append(plan.nodeChanges, nodeChange{action: planCreate, spec: spec})
+			continue
+		}
+
+		var replace []string
+		if computeID := specComputeID(spec); live.ComputeId != computeID {
+			replace = append(replace, fmt.Sprintf("compute_id: %s => %s", live.ComputeId, computeID))
+		}
+		switch {
+		case spec.Template != "" && template == nil:
+			replace = append(replace, fmt.Sprintf("template: => %s", spec.Template))
+		case template != nil && live.TemplateId != "":
+			if live.TemplateId != template.TemplateId {
+				replace = append(replace, fmt.Sprintf("template: %s => %s", live.TemplateId, template.Name))
+			}
+		case template != nil:
+			if live.NodeType != template.TemplateType {
+				replace = append(replace, fmt.Sprintf("type: %s => %s", live.NodeType, template.TemplateType))
+			}
+		case !strings.EqualFold(live.NodeType, spec.Type):
+			replace = append(replace, fmt.Sprintf("type: %s => %s", live.NodeType, spec.Type))
+		}
+		if len(replace) > 0 {
+			gone[spec.Name] = true
+			if ports, known := plannedPorts(spec, template); known {
+				newPorts[spec.Name] = ports
+			}
+			plan.nodeChanges = append(plan.nodeChanges, nodeChange{action: planReplace, spec: spec, live: live, reasons: replace})
+			continue
+		}
+
+		update := &gns3.NodeUpdate{}
+		var changes []string
+		if spec.X != nil && live.X != *spec.X {
+			update.X = spec.X
+			changes = append(changes, fmt.Sprintf("x: %d => %d", live.X, *spec.X))
+		}
+		if spec.Y != nil && live.Y != *spec.Y {
+			update.Y = spec.Y
+			changes = append(changes, fmt.Sprintf("y: %d => %d", live.Y, *spec.Y))
+		}
+		if live.Z != spec.Z {
+			z := spec.Z
+			update.Z = &z
+			changes = append(changes, fmt.Sprintf("z: %d => %d", live.Z, spec.Z))
+		}
+		changes = append(changes, attributeChanges(spec, live, update)...)
+		if len(changes) > 0 {
+			plan.nodeChanges = append(plan.nodeChanges, nodeChange{action: planUpdate, spec: spec, live: live, update: update, reasons: changes})
+			continue
+		}
+		plan.keepNodes = append(plan.keepNodes, live)
+	}
+
+	// Nodes are visited by name so that the plan is the same every time
+	liveNames := make([]string, 0, len(plan.liveNodes))
+	for name := range plan.liveNodes {
+		liveNames = append(liveNames, name)
+	}
+	sort.Strings(liveNames)
+
+	if opts.prune {
+		for _, name := range liveNames {
+			if !inSpec[name] {
+				gone[name] = true
+				plan.nodeChanges = append(plan.nodeChanges, nodeChange{action: planDelete, live: plan.liveNodes[name]})
+			}
+		}
+	}
+
+	// Match the links by the names of the nodes at their ends, and the
+	// ports given for them
+	names := map[string]string{}
+	alloc := newPortAllocator()
+	for _, name := range liveNames {
+		n := plan.liveNodes[name]
+		names[n.NodeId] = name
+		if !gone[name] {
+			alloc.ports[name] = n.Ports
+		}
+	}
+	type liveLink struct {
+		aEnd, zEnd gns3.LinkEnd
+		link       *gns3.Link
+		claimed    bool
+	}
+	var ends []*liveLink
+	for _, link := range liveLinks {
+		aEnd, zEnd, ok := liveLinkEnds(link, names)
+		if ok {
+			ends = append(ends, &liveLink{aEnd: aEnd, zEnd: zEnd, link: link})
+		}
+	}
+	// Links are visited by their ends, whatever order the server lists them in
+	sort.SliceStable(ends, func(i, j int) bool {
+		return fmt.Sprintf("%s <-> %s", ends[i].aEnd, ends[i].zEnd) <
+			fmt.Sprintf("%s <-> %s", ends[j].aEnd, ends[j].zEnd)
+	})
+	var present []*liveLink
+	for _, live := range ends {
+		if gone[live.aEnd.Name] || gone[live.zEnd.Name] {
+			plan.linkChanges = append(plan.linkChanges, linkChange{action: planDelete, live: live.link,
+				desc: fmt.Sprintf("%s <-> %s", live.aEnd, live.zEnd)})
+			continue
+		}
+		present = append(present, live)
+	}
+
+	specs := make([]*gns3.NetworkLink, 0, len(network.Spec.Links))
+	for i := range network.Spec.Links {
+		spec := network.Spec.Links[i]
+		if spec.AEnd, err = alloc.named(spec.AEnd); err != nil {
+			return nil, err
+		}
+		if spec.ZEnd, err = alloc.named(spec.ZEnd); err != nil {
+			return nil, err
+		}
+		specs = append(specs, &spec)
+	}
+	plan.links = specs
+
+	// The ports of nodes yet to be created are only picked once they are,
+	// so check now that those whose ports are known have enough of them
+	if err := checkPlannedPorts(newPorts, specs); err != nil {
+		return nil, err
+	}
+
+	// Links with fixed ports are matched first, so that links whose ports
+	// are picked do not claim them
+	var unmatched []*gns3.NetworkLink
+	for _, fixed := range []bool{true, false} {
+		for _, spec := range specs {
+			if (spec.AEnd.Fixed() && spec.ZEnd.Fixed()) != fixed {
+				continue
+			}
+			var match *liveLink
+			for _, live := range present {
+				if !live.claimed &&
+					((endMatches(spec.AEnd, live.aEnd) && endMatches(spec.ZEnd, live.zEnd)) ||
+						(endMatches(spec.AEnd, live.zEnd) && endMatches(spec.ZEnd, live.aEnd))) {
+					match = live
+					break
+				}
+			}
+			if match == nil {
+				unmatched = append(unmatched, spec)
+				continue
+			}
+			match.claimed = true
+			if endMatches(spec.AEnd, match.aEnd) && endMatches(spec.ZEnd, match.zEnd) {
+				spec.AEnd, spec.ZEnd = match.aEnd, match.zEnd
+			} else {
+				spec.AEnd, spec.ZEnd = match.zEnd, match.aEnd
+			}
+			alloc.use(match.aEnd)
+			alloc.use(match.zEnd)
+			plan.keepLinks = append(plan.keepLinks, match.link)
+		}
+	}
+
+	// delete all invalid links not created for this project
+	for _, live := range present {
+		if !live.claimed {
+			plan.linkChanges = append(plan.linkChanges, linkChange{action: planDelete, live: live.link,
+				desc: fmt.Sprintf("%s <-> %s", live.aEnd, live.zEnd)})
+		}
+	}
+
+	// Pick the ports of new links on existing nodes, those on nodes that
+	// are yet to be created are picked once they are
+	if err := alloc.resolve(unmatched); err != nil {
+		return nil, err
+	}
+	for _, spec := range unmatched {
+		plan.linkChanges = append(plan.linkChanges, linkChange{action: planCreate, spec: spec, desc: specLinkDesc(spec)})
+	}
+
+	return plan, nil
+}
+
+// empty returns true if the plan makes no changes.
+func (p *networkPlan) empty() bool {
+	return p.project != nil && len(p.templateChanges) == 0 &&
+		len(p.nodeChanges) == 0 && len(p.linkChanges) == 0
+}
+
+// counts returns the number of changes in the plan by action.
+func (p *networkPlan) counts() map[planAction]int {
+	counts := map[planAction]int{}
+	if p.project == nil {
+		counts[planCreate]++
+	}
+	for _, c := range p.templateChanges {
+		counts[c.action]++
+	}
+	for _, c := range p.nodeChanges {
+		counts[c.action]++
+	}
+	for _, c := range p.linkChanges {
+		counts[c.action]++
+	}
+	return counts
+}
+
+// print writes the plan in a human readable form.
+func (p *networkPlan) print(w io.Writer) {
+	if p.project == nil {
+		fmt.Fprintf(w, "PROJECT: %s will be created\n", p.network.Metadata.Name)
+	} else {
+		fmt.Fprintf(w, "PROJECT: %s (%s) exists\n", p.project.Name, p.project.ProjectId)
+	}
+
+	for _, c := range p.templateChanges {
+		fmt.Fprintf(w, "  %s template %s (%s)\n", planSymbols[c.action], c.name, c.reference)
+	}
+	for _, c := range p.nodeChanges {
+		switch c.action {
+		case planCreate:
+			kind := c.spec.Type
+			if c.spec.Template != "" {
+				kind = c.spec.Template
+			}
+			fmt.Fprintf(w, "  %s node %s (%s)\n", planSymbols[c.action], c.spec.Name, kind)
+		case planDelete:
+			fmt.Fprintf(w, "  %s node %s (%s)\n", planSymbols[c.action], c.live.Name, c.live.NodeId)
+		default:
+			fmt.Fprintf(w, "  %s node %s (%s): %s\n", planSymbols[c.action], c.live.Name, c.live.NodeId,
+				strings.Join(c.reasons, ", "))
+		}
+	}
+	for _, c := range p.linkChanges {
+		if c.live != nil {
+			fmt.Fprintf(w, "  %s link %s (%s)\n", planSymbols[c.action], c.desc, c.live.LinkId)
+		} else {
+			fmt.Fprintf(w, "  %s link %s\n", planSymbols[c.action], c.desc)
+		}
+	}
+
+	if p.empty() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	counts := p.counts()
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to replace, %d to delete.\n",
+		counts[planCreate], counts[planUpdate], counts[planReplace], counts[planDelete])
+}
+
+// createNode creates a node from its specification in a network document.
+func createNode(ctx context.Context, nctl *gns3.Nodes, templates map[string]*gns3.Template, node *gns3.NetworkNode) (*gns3.Node, error) {
+	x, y := node.Position()
+	if node.Template != "" {
+		t, ok := templates[node.Template]
+		if !ok {
+			return nil, fmt.Errorf("unknown template: %w", gns3.ErrNotFound)
+		}
+		return nctl.CreateUsingTemplateContext(ctx, &gns3.Node{
+			Name:      node.Name,
+			NodeType:  node.Type,
+			ComputeId: specComputeID(node),
+			X:         x,
+			Y:         y,
+			Z:         node.Z}, t)
+	}
+	symbol := ""
+	switch strings.ToLower(node.Type) {
+	case gns3.TypeVpcs:
+		symbol = gns3.SymbolVpcs
+	case gns3.TypeNat:
+		symbol = gns3.SymbolCloud
+	case gns3.TypeRouter:
+		symbol = gns3.SymbolRouter
+	case gns3.TypeEthernetSwitch:
+		symbol = gns3.SymbolEthernetSwitch
+	case gns3.TypeMultilayerSwitch:
+		symbol = gns3.SymbolMultilayerSwitch
+	case gns3.TypeFirewall:
+		symbol = gns3.SymbolFirewall
+	default:
+		symbol = fmt.Sprintf(":/symbols/classic/%s.svg", strings.ToLower(node.Type))
+	}
+	return nctl.CreateContext(ctx, &gns3.Node{
+		Name:      node.Name,
+		NodeType:  node.Type,
+		ComputeId: specComputeID(node),
+		Symbol:    symbol,
+		X:         x,
+		Y:         y,
+		Z:         node.Z})
+}
+
+// attributeChanges adds the console type, properties and label of a node in
+// a network document that differ from the live node to update, and returns
+// a description of each change.
+func attributeChanges(spec *gns3.NetworkNode, live *gns3.Node, update *gns3.NodeUpdate) []string {
+	var changes []string
+	if spec.ConsoleType != "" && live.ConsoleType != spec.ConsoleType {
+		consoleType := spec.ConsoleType
+		update.ConsoleType = &consoleType
+		changes = append(changes, fmt.Sprintf("console_type: %s => %s", live.ConsoleType, consoleType))
+	}
+	keys := make([]string, 0, len(spec.Properties))
+	for key := range spec.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		have, ok := live.Properties[key]
+		want := spec.Properties[key]
+		if ok && sameValue(have, want) {
+			continue
+		}
+		if update.Properties == nil {
+			update.Properties = map[string]interface{}{}
+		}
+		update.Properties[key] = want
+		changes = append(changes, fmt.Sprintf("properties.%s: %v => %v", key, have, want))
+	}
+	if text, _ := live.Label["text"].(string); spec.Label != "" && text != spec.Label {
+		// the label is replaced as a whole, so keep its style and position
+		label := make(map[string]interface{}, len(live.Label)+1)
+		for k, v := range live.Label {
+			label[k] = v
+		}
+		label["text"] = spec.Label
+		update.Label = label
+		changes = append(changes, fmt.Sprintf("label: %s => %s", text, spec.Label))
+	}
+	return changes
+}
+
+// sameValue compares values by their JSON encoding, so the numbers decoded
+// from a network document match those returned by the server.
+func sameValue(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// configureNode delivers the configuration of a node that was just created,
+// unless its method needs the node to be started, in which case it returns
+// true so that it is delivered once the node is ready.
+func configureNode(ctx context.Context, nctl *gns3.Nodes, node *gns3.Node, spec *gns3.NetworkNode) (bool, error) {
+	config := spec.ConfigFor(node.NodeType)
+	if config == nil {
+		return false, nil
+	}
+	name := config.MethodFor(node.NodeType)
+	if method, ok := gns3.LookupConfigMethod(name); ok && method.NeedsStarted() {
+		return true, nil
+	}
+	if err := nctl.ConfigureContext(ctx, node, spec); err != nil {
+		return false, err
+	}
+	fmt.Printf("CONFIG: %s (%s)\n", node.Name, name)
+	return false, nil
+}
+
+// apply makes the changes in the plan, then starts the nodes and resumes
+// the links of the network.
+func (p *networkPlan) apply(ctx context.Context, ctl *gns3.Gns3, opts *loadOptions) (*gns3.Project, error) {
+	project := p.project
+	if project == nil {
+		var err error
+		project, err = ctl.Projects().CreateContext(ctx, &gns3.Project{Name: p.network.Metadata.Name})
+		if err != nil {
+			return nil, fmt.Errorf("create: %w", err)
+		}
+		fmt.Printf("PROJECT: %s (%s) created\n", project.Name, project.ProjectId)
+	} else {
+		fmt.Printf("PROJECT: %s (%s) exists\n", project.Name, project.ProjectId)
+	}
+
+	creating := map[string]bool{}
+	for _, c := range p.templateChanges {
+		creating[c.name] = true
+	}
+	for _, app := range p.appliances {
+		a, t, err := ctl.Appliances().ImportContext(ctx, bytes.NewReader(app.data), path.Dir(app.reference))
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", app.reference, err)
+		}
+		fmt.Println(a.Name)
+		if !creating[t.Name] {
+			fmt.Println("Template", t.Name, "already present")
+			continue
+		}
+		created, err := ctl.Templates().CreateContext(ctx, t)
+		if err != nil {
+			return nil, fmt.Errorf("creating template '%s': %w", t.Name, err)
+		}
+		fmt.Println("Template", created.Name, "type", created.TemplateType, "created")
+		p.templates[created.Name] = created
+		p.templates[created.TemplateId] = created
+		delete(creating, t.Name)
+	}
+
+	nctl := ctl.Nodes(project.ProjectId)
+	lctl := ctl.Links(project.ProjectId)
+
+	for _, c := range p.linkChanges {
+		if c.action != planDelete {
+			continue
+		}
+		fmt.Printf("Deleting stale link: %s\n", c.live.LinkId)
+		if _, err := lctl.DeleteContext(ctx, c.live.LinkId); err != nil {
+			return nil, fmt.Errorf("link delete: %w", err)
+		}
+	}
+
+	nodes := map[string]*gns3.Node{}
+	for _, n := range p.keepNodes {
+		nodes[n.Name] = n
+		fmt.Printf("NODE: %s (%s) exists\n", n.Name, n.NodeId)
+	}
+	for _, c := range p.nodeChanges {
+		if c.action != planDelete && c.action != planReplace {
+			continue
+		}
+		if _, err := nctl.DeleteContext(ctx, c.live.NodeId); err != nil {
+			return nil, fmt.Errorf("node delete: %w", err)
+		}
+		fmt.Printf("NODE: %s (%s) deleted\n", c.live.Name, c.live.NodeId)
+	}
+	// configure holds the nodes whose configuration is delivered once they
+	// have started
+	configure := map[string]bool{}
+	// created are the nodes to configure once the ports of their links are
+	// known
+	var created []*gns3.NetworkNode
+	for _, c := range p.nodeChanges {
+		switch c.action {
+		case planCreate, planReplace:
+			resp, err := createNode(ctx, nctl, p.templates, c.spec)
+			if err != nil {
+				return nil, fmt.Errorf("node create: %w", err)
+			}
+			// Nodes are created with the defaults of their type or template,
+			// then given the attributes of the document
+			update := &gns3.NodeUpdate{}
+			if changes := attributeChanges(c.spec, resp, update); len(changes) > 0 {
+				resp, err = nctl.UpdateContext(ctx, resp.NodeId, update)
+				if err != nil {
+					return nil, fmt.Errorf("node update: %w", err)
+				}
+			}
+			nodes[resp.Name] = resp
+			fmt.Printf("NODE: %s (%s) created\n", resp.Name, resp.NodeId)
+			// the configuration is only delivered to new nodes, so that
+			// changes made to running nodes are not overwritten
+			if c.spec.CloudInit != nil {
+				if err := nctl.CloudInitContext(ctx, resp, c.spec.CloudInit); err != nil {
+					return nil, err
+				}
+				fmt.Printf("CLOUD-INIT: %s (%s)\n", resp.Name, gns3.CloudInitImage)
+			}
+			created = append(created, c.spec)
+		case planUpdate:
+			resp, err := nctl.UpdateContext(ctx, c.live.NodeId, c.update)
+			if err != nil {
+				return nil, fmt.Errorf("node update: %w", err)
+			}
+			nodes[resp.Name] = resp
+			fmt.Printf("NODE: %s (%s) updated\n", resp.Name, resp.NodeId)
+		}
+	}
+
+	// Pick the ports that depend on the nodes just created
+	alloc := newPortAllocator()
+	for name, n := range nodes {
+		alloc.ports[name] = n.Ports
+	}
+	names := make(map[string]string, len(nodes))
+	for name, n := range nodes {
+		names[n.NodeId] = name
+	}
+	// links are resumed once the nodes at both of their ends have started
+	type resumeLink struct {
+		id, aEnd, zEnd string
+	}
+	var resume []resumeLink
+	for _, link := range p.keepLinks {
+		fmt.Printf("Link: %s already exists. Suspended state: %v\n", link.LinkId, link.Suspend)
+		aEnd, zEnd, ok := liveLinkEnds(link, names)
+		if ok {
+			alloc.use(aEnd)
+			alloc.use(zEnd)
+		}
+		if link.Suspend {
+			resume = append(resume, resumeLink{id: link.LinkId, aEnd: aEnd.Name, zEnd: zEnd.Name})
+		}
+	}
+	var creates []*gns3.NetworkLink
+	for _, c := range p.linkChanges {
+		if c.action == planCreate {
+			creates = append(creates, c.spec)
+		}
+	}
+	if err := alloc.resolve(creates); err != nil {
+		return nil, err
+	}
+
+	// The addresses of links are recorded with the ports used for them
+	for i, link := range p.links {
+		for _, end := range []gns3.LinkEnd{link.AEnd, link.ZEnd} {
+			if node, ok := nodes[end.Name]; ok && end.Fixed() {
+				p.network.SetInterface(i, end.Name, portName(node, end))
+			}
+		}
+	}
+	for _, spec := range created {
+		later, err := configureNode(ctx, nctl, nodes[spec.Name], spec)
+		if err != nil {
+			return nil, err
+		}
+		if later {
+			configure[spec.Name] = true
+		}
+	}
+
+	for _, spec := range creates {
+		aEnd, ok := nodes[spec.AEnd.Name]
+		if !ok {
+			return nil, fmt.Errorf("unable to find a-end '%s': %w", spec.AEnd.Name, gns3.ErrNotFound)
+		}
+		zEnd, ok := nodes[spec.ZEnd.Name]
+		if !ok {
+			return nil, fmt.Errorf("unable to find z-end '%s': %w", spec.ZEnd.Name, gns3.ErrNotFound)
+		}
+		aAdapter, aPort := spec.AEnd.Numbers()
+		zAdapter, zPort := spec.ZEnd.Numbers()
+		resp, err := lctl.CreateContext(ctx, &gns3.Link{ProjectId: project.ProjectId, LinkType: "ethernet", Suspend: true, Nodes: []gns3.NodeRef{
+			{NodeId: aEnd.NodeId, AdapterNumber: aAdapter, PortNumber: aPort},
+			{NodeId: zEnd.NodeId, AdapterNumber: zAdapter, PortNumber: zPort},
+		}})
+		if err != nil {
+			return nil, fmt.Errorf("link create: %w", err)
+		}
+		fmt.Printf("LINK: %s (%s-%s) created\n", resp.LinkId, spec.AEnd.Name, spec.ZEnd.Name)
+		resume = append(resume, resumeLink{id: resp.LinkId, aEnd: spec.AEnd.Name, zEnd: spec.ZEnd.Name})
+	}
+
+	// A project that no longer has addresses is left with none recorded,
+	// as files cannot be deleted through the controller
+	if addresses := p.network.Addresses(); len(addresses) > 0 || p.recorded {
+		if addresses == nil {
+			addresses = []gns3.Address{}
+		}
+		data, err := json.MarshalIndent(addresses, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := ctl.Projects().WriteFileContext(ctx, project.ProjectId, gns3.AddressesFile, data); err != nil {
+			return nil, fmt.Errorf("record addresses: %w", err)
+		}
+		fmt.Printf("ADDRESSES: %d recorded in %s\n", len(addresses), gns3.AddressesFile)
+	}
+
+	resumeStarted := func(started map[string]bool) error {
+		var waiting []resumeLink
+		for _, link := range resume {
+			if started != nil && (!started[link.aEnd] || !started[link.zEnd]) {
+				waiting = append(waiting, link)
+				continue
+			}
+			if _, err := lctl.ResumeContext(ctx, link.id); err != nil {
+				return fmt.Errorf("resume failed: %w", err)
+			}
+		}
+		resume = waiting
+		return nil
+	}
+	startErr := startNetwork(ctx, nctl, p.network, nodes, configure, opts.parallel, resumeStarted)
+	// links to nodes that failed to start are resumed too, so that they
+	// carry traffic once the nodes are started by hand
+	if err := resumeStarted(nil); err != nil {
+		return nil, err
+	}
+	if startErr != nil {
+		return nil, startErr
+	}
+
+	return project, nil
+}
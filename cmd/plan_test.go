@@ -0,0 +1,147 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+// testAllocator returns an allocator for a switch with ports Ethernet0-2,
+// also known as e0-e2, and a router with the adapters Ethernet0 and
+// Ethernet1.
+func testAllocator() *portAllocator {
+	alloc := newPortAllocator()
+	for i := 0; i < 3; i++ {
+		alloc.ports["sw1"] = append(alloc.ports["sw1"], &gns3.Port{AdapterNumber: 0, PortNumber: i,
+			Name: fmt.Sprintf("Ethernet%d", i), ShortName: fmt.Sprintf("e%d", i)})
+	}
+	for i := 0; i < 2; i++ {
+		name := fmt.Sprintf("Ethernet%d", i)
+		alloc.ports["r1"] = append(alloc.ports["r1"], &gns3.Port{AdapterNumber: i, PortNumber: 0, Name: name, ShortName: name})
+	}
+	return alloc
+}
+
+func TestPortAllocatorNamed(t *testing.T) {
+	alloc := testAllocator()
+	for _, tc := range []struct {
+		end  gns3.LinkEnd
+		want string
+	}{
+		{gns3.LinkEnd{Name: "sw1", Interface: "e2"}, "sw1 0/2"},
+		{gns3.LinkEnd{Name: "sw1", Interface: "Ethernet1"}, "sw1 0/1"},
+		{gns3.LinkEnd{Name: "r1", Interface: "ethernet1"}, "r1 1/0"},
+		{gns3.LinkEnd{Name: "r1"}, "r1 (auto)"},
+		{gns3.FixedLinkEnd("r1", 1, 0), "r1 1/0"},
+		// the ports of nodes yet to be created are not known
+		{gns3.LinkEnd{Name: "r2", Interface: "e0"}, "r2 e0"},
+	} {
+		got, err := alloc.named(tc.end)
+		if err != nil || got.String() != tc.want {
+			t.Errorf("%s: got %s, %v, want %s", tc.end, got, err, tc.want)
+		}
+	}
+	if _, err := alloc.named(gns3.LinkEnd{Name: "sw1", Interface: "e9"}); err == nil ||
+		!strings.Contains(err.Error(), "node 'sw1' has no interface 'e9'") {
+		t.Errorf("unknown interface: got %v", err)
+	}
+}
+
+func TestPortAllocatorResolve(t *testing.T) {
+	alloc := testAllocator()
+	alloc.use(gns3.FixedLinkEnd("sw1", 0, 1))
+	links := []*gns3.NetworkLink{
+		{AEnd: gns3.LinkEnd{Name: "sw1"}, ZEnd: gns3.LinkEnd{Name: "r1"}},
+		{AEnd: gns3.LinkEnd{Name: "sw1", Interface: "e0"}, ZEnd: gns3.LinkEnd{Name: "r1", Interface: "Ethernet0"}},
+	}
+	if err := alloc.resolve(links); err != nil {
+		t.Fatal(err)
+	}
+	// the named ports, and the one in use, are not picked for the first link
+	var got []string
+	for _, link := range links {
+		got = append(got, link.AEnd.String()+" <-> "+link.ZEnd.String())
+	}
+	if want := "sw1 0/2 <-> r1 1/0,sw1 0/0 <-> r1 0/0"; strings.Join(got, ",") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, ","), want)
+	}
+
+	more := []*gns3.NetworkLink{{AEnd: gns3.LinkEnd{Name: "sw1"}, ZEnd: gns3.LinkEnd{Name: "r2"}}}
+	if err := alloc.resolve(more); err == nil || err.Error() != "node 'sw1' is out of ports (3 in use)" {
+		t.Errorf("out of ports: got %v", err)
+	}
+}
+
+func TestDryRunOutOfPorts(t *testing.T) {
+	s, ctl := testServer(t)
+	s.AddTemplate(gns3.Template{Name: "router", TemplateType: gns3.TemplateTypeQemu, ComputeId: "local",
+		Qemu: &gns3.TemplateQemu{Adapters: 2}})
+	const doc = `
+apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - {name: nat, type: nat}
+    - {name: r1, template: router}
+    - {name: pc1, type: vpcs}
+    - {name: pc2, type: vpcs}
+  links:
+    - {aEnd: {name: nat}, zEnd: {name: r1}}
+    - {aEnd: {name: r1}, zEnd: {name: pc1}}
+`
+	for _, tc := range []struct {
+		name string
+		edit []string
+		want string
+	}{
+		{name: "enough ports"},
+		{
+			name: "type",
+			edit: []string{"zEnd: {name: pc1}}", "zEnd: {name: pc1}}\n    - {aEnd: {name: pc2}, zEnd: {name: nat}}"},
+			want: "node 'nat' is out of ports (1 in use)",
+		},
+		{
+			name: "template",
+			edit: []string{"zEnd: {name: pc1}}", "zEnd: {name: pc1}}\n    - {aEnd: {name: pc2}, zEnd: {name: r1}}"},
+			want: "node 'r1' is out of ports (2 in use)",
+		},
+		{
+			name: "properties",
+			edit: []string{"template: router}", "template: router, properties: {adapters: 4}}",
+				"zEnd: {name: pc1}}", "zEnd: {name: pc1}}\n    - {aEnd: {name: pc2}, zEnd: {name: r1}}"},
+		},
+	} {
+		_, _, err := loadNetwork(context.Background(), ctl, parseNetwork(t, doc, tc.edit...), &loadOptions{dryRun: true})
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.want != "" && (err == nil || err.Error() != tc.want):
+			t.Errorf("%s: got %v, want %s", tc.name, err, tc.want)
+		}
+	}
+	for _, r := range s.Requests() {
+		if !strings.HasPrefix(r, "GET ") {
+			t.Errorf("dry run made request %s", r)
+		}
+	}
+}
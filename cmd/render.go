@@ -0,0 +1,88 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// renderCmd represents the render command
+//
+//nolint:exhaustruct
+var renderCmd = &cobra.Command{
+	Use:   "render [flags] FILE|DIR|- [FILE|DIR|-...]",
+	Short: "Displays network documents with their values resolved",
+	Long: `
+Network documents are Go templates that are rendered before they are parsed
+by load, diff and validate. This command prints the result of rendering, with
+the values from --values files and --set overrides, available as .Values.
+The lines of problems that load, diff and validate find in a document changed
+by rendering are those of this output.
+
+In addition to the built in template functions the following helpers, named
+after those of the sprig library, are available:
+
+  default, required, empty, ternary
+  lower, upper, trim, trimPrefix, trimSuffix, replace, contains, hasPrefix,
+  hasSuffix, repeat, split, join, quote, indent, nindent, toString, toYaml,
+  toJson
+  add, sub, mul, div, mod, atoi, until, seq, list, dict
+  ipAdd N IP            the address N after (or before) IP
+  cidrHost N CIDR       the Nth address of CIDR, negative counts from the end
+  cidrSubnet BITS N CIDR  the Nth subnet of CIDR that is BITS longer
+  cidrNetmask CIDR      the dotted netmask of an IPv4 CIDR
+  cidrPrefix CIDR       the prefix length of CIDR
+
+For example:
+
+  address: {{ cidrSubnet 8 .Values.pod "10.0.0.0/16" | cidrHost 10 }}
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values, err := renderValues(cmd)
+		if err != nil {
+			return err
+		}
+		files, err := expandInputs(args)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for i, filename := range files {
+			data, err := renderFile(filename, values)
+			if err != nil {
+				errs = append(errs, err)
+				printFileError(filename, err)
+				continue
+			}
+			if i > 0 && !bytes.HasPrefix(data, []byte("---")) {
+				fmt.Println("---")
+			}
+			os.Stdout.Write(data)
+		}
+		return collectErrors(errs, len(files))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	addRenderFlags(renderCmd)
+}
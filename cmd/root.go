@@ -16,16 +16,29 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	units "github.com/docker/go-units"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile string
+
+	// configErr records a failure to read the configuration file, which
+	// happens before any command runs, so that it is returned from the
+	// command instead.
+	configErr error
+)
 
 // rootCmd represents the base command when called without any subcommands
 //
@@ -37,14 +50,23 @@ var rootCmd = &cobra.Command{
 including the ability to create example networks and extract
 information about those networks.`,
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configErr
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The command context is canceled on an interrupt so that in-flight requests
+// are aborted. The exit code reflects the category of any error, see
+// exitCode.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	wrapArgs(rootCmd)
+	err := rootCmd.ExecuteContext(ctx)
+	cancel()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -52,6 +74,9 @@ func init() {
 	baseDirectory := path.Join(os.Getenv("HOME"), "GNS3")
 
 	cobra.OnInitialize(initConfig)
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.gns3ctl.yaml)")
 
@@ -61,12 +86,18 @@ func init() {
 	rootCmd.PersistentFlags().StringP("project", "p", "default", "Default project name to use when performing project specific operations")
 	_ = viper.BindPFlag("project", rootCmd.PersistentFlags().Lookup("project"))
 
-	rootCmd.PersistentFlags().StringP("address", "a", "localhost:3080", "Service and port on which to contact the server")
+	rootCmd.PersistentFlags().StringP("address", "a", "localhost:3080", "Service and port, or URL including the scheme, on which to contact the server")
 	_ = viper.BindPFlag("address", rootCmd.PersistentFlags().Lookup("address"))
 
 	rootCmd.PersistentFlags().BoolP("insecure-skip-verify", "k", true, "Skip verifing the TLS cert of the host")
 	_ = viper.BindPFlag("insecure-skip-verify", rootCmd.PersistentFlags().Lookup("insecure-skip-verify"))
 
+	rootCmd.PersistentFlags().String("ca-file", "", "PEM encoded CA bundle used to verify the TLS cert of the host")
+	_ = viper.BindPFlag("ca-file", rootCmd.PersistentFlags().Lookup("ca-file"))
+
+	rootCmd.PersistentFlags().String("user-agent", gns3.DefaultUserAgent, "User agent sent with http requests")
+	_ = viper.BindPFlag("user-agent", rootCmd.PersistentFlags().Lookup("user-agent"))
+
 	rootCmd.PersistentFlags().StringP("compute", "c", "local", "Default compute to use when creating projects")
 	_ = viper.BindPFlag("compute", rootCmd.PersistentFlags().Lookup("compute"))
 
@@ -79,6 +110,23 @@ func init() {
 	rootCmd.PersistentFlags().StringP("password", "w", "admin", "Password for basic authentication")
 	_ = viper.BindPFlag("password", rootCmd.PersistentFlags().Lookup("password"))
 
+	defaultRetry := gns3.DefaultRetryPolicy()
+
+	rootCmd.PersistentFlags().Int("retry-max-attempts", defaultRetry.MaxAttempts, "Maximum number of attempts for a http request, 1 disables retries")
+	_ = viper.BindPFlag("retry-max-attempts", rootCmd.PersistentFlags().Lookup("retry-max-attempts"))
+
+	rootCmd.PersistentFlags().Duration("retry-initial-backoff", defaultRetry.InitialBackoff, "Delay before the first retry of a http request, doubled on each further retry")
+	_ = viper.BindPFlag("retry-initial-backoff", rootCmd.PersistentFlags().Lookup("retry-initial-backoff"))
+
+	rootCmd.PersistentFlags().Duration("retry-max-backoff", defaultRetry.MaxBackoff, "Maximum delay between retries of a http request")
+	_ = viper.BindPFlag("retry-max-backoff", rootCmd.PersistentFlags().Lookup("retry-max-backoff"))
+
+	rootCmd.PersistentFlags().IntSlice("retry-status", defaultRetry.RetryableStatus, "HTTP status codes on which an idempotent request is retried")
+	_ = viper.BindPFlag("retry-status", rootCmd.PersistentFlags().Lookup("retry-status"))
+
+	rootCmd.PersistentFlags().StringSlice("retry-methods", defaultRetry.RetryableMethods, "HTTP methods that are considered idempotent and may be retried")
+	_ = viper.BindPFlag("retry-methods", rootCmd.PersistentFlags().Lookup("retry-methods"))
+
 	rootCmd.PersistentFlags().String("download-buffer-size", "10M", "size of in memory buffer to use for file downloads")
 	_ = viper.BindPFlag("download-buffer-size", rootCmd.PersistentFlags().Lookup("download-buffer-size"))
 }
@@ -89,12 +137,11 @@ func initConfig() {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
 	} else {
-		// Find home directory.
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-
-		// Search config in home directory with name ".gns3ctl" (without extension).
-		viper.AddConfigPath(home)
+		// Search config in home directory with name ".gns3ctl" (without
+		// extension), if there is one.
+		if home, err := os.UserHomeDir(); err == nil {
+			viper.AddConfigPath(home)
+		}
 		viper.SetConfigType("yaml")
 		viper.SetConfigName(".gns3ctl")
 	}
@@ -104,8 +151,62 @@ func initConfig() {
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err != nil {
 		if viper.ConfigFileUsed() != "" {
-			fmt.Fprintf(os.Stderr, "Error while reading configuration file: %s\n", viper.ConfigFileUsed())
-			os.Exit(1)
+			configErr = &usageError{
+				err: fmt.Errorf("error while reading configuration file: %s: %w", viper.ConfigFileUsed(), err),
+			}
+		}
+	}
+}
+
+// wrapArgs marks the errors from the positional argument validation of cmd
+// and its sub-commands as usage errors, as SetFlagErrorFunc does for flags.
+func wrapArgs(cmd *cobra.Command) {
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(cmd *cobra.Command, a []string) error {
+			if err := args(cmd, a); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
 		}
 	}
+	for _, c := range cmd.Commands() {
+		wrapArgs(c)
+	}
+}
+
+// connect returns a client for the GNS3 server configured from the global
+// flags and configuration file.
+func connect() (*gns3.Gns3, error) {
+	retry := gns3.DefaultRetryPolicy()
+	retry.MaxAttempts = viper.GetInt("retry-max-attempts")
+	retry.InitialBackoff = viper.GetDuration("retry-initial-backoff")
+	retry.MaxBackoff = viper.GetDuration("retry-max-backoff")
+	retry.RetryableStatus = viper.GetIntSlice("retry-status")
+	retry.RetryableMethods = nil
+	for _, m := range viper.GetStringSlice("retry-methods") {
+		retry.RetryableMethods = append(retry.RetryableMethods, strings.ToUpper(m))
+	}
+
+	bufferSize, err := units.FromHumanSize(viper.GetString("download-buffer-size"))
+	if err != nil {
+		return nil, &usageError{err: fmt.Errorf("invalid download buffer size '%s': %w",
+			viper.GetString("download-buffer-size"), err)}
+	}
+
+	ctl, err := gns3.Connect(gns3.Options{
+		BaseURL:            viper.GetString("address"),
+		Username:           viper.GetString("username"),
+		Password:           viper.GetString("password"),
+		Timeout:            viper.GetDuration("timeout"),
+		CAFile:             viper.GetString("ca-file"),
+		InsecureSkipVerify: viper.GetBool("insecure-skip-verify"),
+		UserAgent:          viper.GetString("user-agent"),
+		Retry:              retry,
+		BaseDirectory:      viper.GetString("base-directory"),
+		DownloadBufferSize: int(bufferSize),
+	})
+	if err != nil {
+		return nil, &connectError{err: err}
+	}
+	return ctl, nil
 }
@@ -0,0 +1,133 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ErrNothingToSet error = &usageError{err: errors.New("no attributes to set were given")}
+
+// setNodeCmd represents the set node command
+//
+//nolint:exhaustruct
+var setNodeCmd = &cobra.Command{
+	Use:     "node [flags] NODE",
+	Aliases: []string{"no", "nodes"},
+	Short:   "Change the attributes of a node",
+	Long: `
+Changes the name, position, console type, label or properties of a node.
+Only the attributes given are changed. Properties are given as key=value,
+where integer and boolean values are converted, and may be repeated:
+
+  gns3ctl set node -p lab r1 --x 100 --y -50 --property adapters=4
+
+Some properties can only be changed while the node is stopped.
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+
+		flags := cmd.Flags()
+		changed := false
+		for _, flag := range []string{"name", "x", "y", "z", "console-type", "label", "property"} {
+			changed = changed || flags.Changed(flag)
+		}
+		if !changed {
+			return ErrNothingToSet
+		}
+
+		update := &gns3.NodeUpdate{}
+		if flags.Changed("name") {
+			name, _ := flags.GetString("name")
+			update.Name = &name
+		}
+		if flags.Changed("console-type") {
+			consoleType, _ := flags.GetString("console-type")
+			update.ConsoleType = &consoleType
+		}
+		if flags.Changed("x") {
+			x, _ := flags.GetInt("x")
+			update.X = &x
+		}
+		if flags.Changed("y") {
+			y, _ := flags.GetInt("y")
+			update.Y = &y
+		}
+		if flags.Changed("z") {
+			z, _ := flags.GetInt("z")
+			update.Z = &z
+		}
+		props, _ := flags.GetStringArray("property")
+		if len(props) > 0 {
+			values := gns3.Values{}
+			for _, prop := range props {
+				if err := values.Set(prop); err != nil {
+					return &usageError{err: err}
+				}
+			}
+			update.Properties = values
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		nctl := ctl.Nodes(project.ProjectId)
+		node, err := nctl.GetContext(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("node '%s' not found: %w", args[0], err)
+		}
+		if flags.Changed("label") {
+			// the label is replaced as a whole, so keep its style and position
+			update.Label = make(map[string]interface{}, len(node.Label)+1)
+			for k, v := range node.Label {
+				update.Label[k] = v
+			}
+			update.Label["text"], _ = flags.GetString("label")
+		}
+
+		node, err = nctl.UpdateContext(cmd.Context(), node.NodeId, update)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("NODE: %s (%s) updated\n", node.Name, node.NodeId)
+		return nil
+	},
+}
+
+func init() {
+	setCmd.AddCommand(setNodeCmd)
+	setNodeCmd.Flags().String("name", "", "new name of the node")
+	setNodeCmd.Flags().Int("x", 0, "horizontal position of the node")
+	setNodeCmd.Flags().Int("y", 0, "vertical position of the node")
+	setNodeCmd.Flags().Int("z", 0, "stacking order of the node")
+	setNodeCmd.Flags().String("console-type", "", "console type, such as telnet, vnc or none")
+	setNodeCmd.Flags().String("label", "", "text of the node's label")
+	setNodeCmd.Flags().StringArray("property", nil, "set a property, as key=value, may be repeated")
+}
@@ -18,7 +18,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -30,27 +29,31 @@ var startLinksCmd = &cobra.Command{
 	Use:     "links",
 	Aliases: []string{"li", "link"},
 	Short:   "Start or resume a network link",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		pname := viper.GetString("project")
 		if pname == "" {
-			fmt.Printf("ERROR: a project context must be specified")
-			return
+			return ErrNoProjectSpecified
 		}
-		ctl := gns3.Connect()
-		project, err := ctl.Projects().Get(pname)
+		ctl, err := connect()
 		if err != nil {
-			fmt.Printf("ERROR: project `%s` not found\n", pname)
-			return
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
 		}
 
+		var errs []error
 		for _, li := range args {
-			uuid, err := ctl.Links(project.ProjectId).Resume(li)
+			uuid, err := ctl.Links(project.ProjectId).ResumeContext(cmd.Context(), li)
 			if err != nil {
+				errs = append(errs, err)
 				fmt.Printf("ERROR: %s: %v\n", li, err)
 			} else {
 				fmt.Println(uuid)
 			}
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
@@ -16,11 +16,11 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // startNodeCmd represents the startNode command
@@ -30,30 +30,85 @@ var startNodesCmd = &cobra.Command{
 	Use:     "nodes [flags] NODE [NODE...]",
 	Aliases: []string{"no", "node"},
 	Short:   "Start specfied nodes",
-	Run: func(cmd *cobra.Command, args []string) {
-		pname := viper.GetString("project")
-		if pname == "" {
-			fmt.Printf("ERROR: a project context must be specified")
-			return
-		}
-		ctl := gns3.Connect()
-		project, err := ctl.Projects().Get(pname)
-		if err != nil {
-			fmt.Printf("ERROR: project `%s` not found\n", pname)
-			return
-		}
+	Long: `
+Starts the specified nodes, or every node of the project with --all. With
+--reload nodes that are running are restarted, and with --unisolate the
+links of nodes isolated by 'suspend nodes --isolate' are resumed instead.
+
+Nodes are started one at a time unless --parallel is given, while --all
+starts them with a single request to the server, or --parallel at a time
+when it is given.
 
-		for _, no := range args {
-			err := ctl.Nodes(project.ProjectId).Start(no)
-			if err != nil {
-				fmt.Printf("%s => %v\n", no, err)
-			} else {
-				fmt.Printf("%s started\n", no)
-			}
+With --file the nodes of the projects described by a network document are
+started in the order given by their dependsOn lists, waiting for each node
+that others depend on, or that has readiness checks, to be ready before
+starting the next nodes.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if file, _ := cmd.Flags().GetString("file"); file != "" {
+			return startNetworkFile(cmd, args, file)
 		}
+		reload, _ := cmd.Flags().GetBool("reload")
+		unisolate, _ := cmd.Flags().GetBool("unisolate")
+		switch {
+		case reload && unisolate:
+			return &usageError{err: errors.New("--reload and --unisolate cannot be used together")}
+		case reload:
+			return runNodeOperation(cmd, args, nodeOperation{done: "reloaded",
+				one: (*gns3.Nodes).ReloadContext, all: (*gns3.Nodes).ReloadAllContext})
+		case unisolate:
+			return runNodeOperation(cmd, args, nodeOperation{done: "unisolated", one: (*gns3.Nodes).UnisolateContext})
+		}
+		return runNodeOperation(cmd, args, nodeOperation{done: "started",
+			one: (*gns3.Nodes).StartContext, all: (*gns3.Nodes).StartAllContext})
 	},
 }
 
 func init() {
 	startCmd.AddCommand(startNodesCmd)
+	addNodeOperationFlags(startNodesCmd)
+	addRenderFlags(startNodesCmd)
+	startNodesCmd.Flags().StringP("file", "f", "", "start the nodes of a network document, in dependency order")
+	startNodesCmd.Flags().Bool("reload", false, "restart the nodes if they are running")
+	startNodesCmd.Flags().Bool("unisolate", false, "resume the links of the nodes rather than starting them")
+}
+
+// startNetworkFile starts the nodes of each network in a network document.
+func startNetworkFile(cmd *cobra.Command, args []string, file string) error {
+	if all, _ := cmd.Flags().GetBool("all"); all || len(args) > 0 {
+		return &usageError{err: errors.New("nodes and --all cannot be given with --file")}
+	}
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	values, err := renderValues(cmd)
+	if err != nil {
+		return err
+	}
+	networks, err := readNetworks(file, values)
+	if err != nil {
+		return err
+	}
+
+	ctl, err := connect()
+	if err != nil {
+		return err
+	}
+	for _, network := range networks {
+		project, err := ctl.Projects().GetContext(cmd.Context(), network.Metadata.Name)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", network.Metadata.Name, err)
+		}
+		nctl := ctl.Nodes(project.ProjectId)
+		list, err := nctl.ListContext(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to retrieve nodes: %w", err)
+		}
+		nodes := make(map[string]*gns3.Node, len(list))
+		for _, node := range list {
+			nodes[node.Name] = node
+		}
+		if err := startNetwork(cmd.Context(), nctl, network, nodes, nil, parallel, nil); err != nil {
+			return err
+		}
+	}
+	return nil
 }
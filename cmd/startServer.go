@@ -30,28 +30,30 @@ var startServerCmd = &cobra.Command{
 	Short: "Start a GNS3 network simulation server, if one is not already running.",
 	Long: `If no GNS3 network simulation server process can be found,
 this command wil start a server as a daemon process.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		gnsConfig, _ := cmd.Flags().GetString("gns-config")
-		p, started, err := gns3.Connect().Server().Start(gnsConfig)
+		ctl, err := connect()
 		if err != nil {
-			fmt.Printf("ERROR: %v\n", err)
-			return
+			return err
+		}
+		p, started, err := ctl.Server().StartContext(cmd.Context(), gnsConfig)
+		if err != nil {
+			return err
 		}
 		if !started {
 			running, err := p.IsRunning()
 			if err != nil {
-				fmt.Printf("ERROR: %v\n", err)
-				return
+				return err
 			}
 			if running {
 				fmt.Printf("INFO: a server was already started as PID '%v'\n", p.Pid)
-				return
+				return nil
 			}
-			fmt.Printf("ERROR: a server was already started as PID '%v', but is not running", p.Pid)
-			return
+			return fmt.Errorf("a server was already started as PID '%v', but is not running: %w",
+				p.Pid, gns3.ErrServerUnavailable)
 		}
 		fmt.Printf("PID: %v\n", p.Pid)
-
+		return nil
 	},
 }
 
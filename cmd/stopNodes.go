@@ -16,11 +16,8 @@ limitations under the License.
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // stopNodeCmd represents the stopNode command
@@ -30,30 +27,18 @@ var stopNodesCmd = &cobra.Command{
 	Use:     "nodes [flags] NODE [NODE...]",
 	Aliases: []string{"no", "node"},
 	Short:   "Stop the specified nodes",
-	Run: func(cmd *cobra.Command, args []string) {
-		pname := viper.GetString("project")
-		if pname == "" {
-			fmt.Printf("ERROR: a project context must be specified")
-			return
-		}
-		ctl := gns3.Connect()
-		project, err := ctl.Projects().Get(pname)
-		if err != nil {
-			fmt.Printf("ERROR: project `%s` not found\n", pname)
-			return
-		}
-
-		for _, no := range args {
-			err := ctl.Nodes(project.ProjectId).Stop(no)
-			if err != nil {
-				fmt.Printf("%s => %v\n", no, err)
-			} else {
-				fmt.Printf("%s stoped\n", no)
-			}
-		}
+	Long: `
+Stops the specified nodes, or every node of the project with --all. Nodes are
+stopped one at a time unless --parallel is given, while --all stops them with
+a single request to the server, or --parallel at a time when it is given.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNodeOperation(cmd, args, nodeOperation{done: "stopped",
+			one: (*gns3.Nodes).StopContext, all: (*gns3.Nodes).StopAllContext})
 	},
 }
 
 func init() {
 	stopCmd.AddCommand(stopNodesCmd)
+	addNodeOperationFlags(stopNodesCmd)
 }
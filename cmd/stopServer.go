@@ -18,7 +18,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 )
 
@@ -28,13 +27,17 @@ import (
 var stopServerCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start a GNS3 network simulation server, if one is not already running",
-	Run: func(cmd *cobra.Command, args []string) {
-		err := gns3.Connect().Server().Shutdown()
-		if err == nil {
-			fmt.Printf("INFO: shutdown\n")
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctl, err := connect()
+		if err != nil {
+			return err
 		}
-		fmt.Printf("ERROR: %v\n", err)
+		err = ctl.Server().ShutdownContext(cmd.Context())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("INFO: shutdown\n")
+		return nil
 	},
 }
 
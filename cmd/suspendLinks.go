@@ -18,7 +18,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -31,27 +30,31 @@ var suspendLinkCmd = &cobra.Command{
 	Aliases: []string{"li", "link"},
 	Short:   "Suspend the execution/emulation of a link",
 	Args:    cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		pname := viper.GetString("project")
 		if pname == "" {
-			fmt.Printf("ERROR: a project context must be specified")
-			return
+			return ErrNoProjectSpecified
 		}
-		ctl := gns3.Connect()
-		project, err := ctl.Projects().Get(pname)
+		ctl, err := connect()
 		if err != nil {
-			fmt.Printf("ERROR: project `%s` not found\n", pname)
-			return
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
 		}
 
+		var errs []error
 		for _, li := range args {
-			uuid, err := ctl.Links(project.ProjectId).Suspend(li)
+			uuid, err := ctl.Links(project.ProjectId).SuspendContext(cmd.Context(), li)
 			if err != nil {
-				fmt.Printf("%s => %v\n", uuid, err)
+				errs = append(errs, err)
+				fmt.Printf("%s => %v\n", li, err)
 			} else {
-				fmt.Printf("%s suspendd\n", uuid)
+				fmt.Printf("%s suspended\n", uuid)
 			}
 		}
+		return collectErrors(errs, len(args))
 	},
 }
 
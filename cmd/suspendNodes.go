@@ -0,0 +1,49 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+)
+
+// suspendNodesCmd represents the suspend nodes command
+//
+//nolint:exhaustruct
+var suspendNodesCmd = &cobra.Command{
+	Use:     "nodes [flags] NODE [NODE...]",
+	Aliases: []string{"no", "node"},
+	Short:   "Suspend the specified nodes",
+	Long: `
+Suspends the execution of the specified nodes, or of every node of the
+project with --all. With --isolate the nodes keep running and all of their
+links are suspended instead, cutting them off from the rest of the network;
+'start nodes --unisolate' resumes the links.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if isolate, _ := cmd.Flags().GetBool("isolate"); isolate {
+			return runNodeOperation(cmd, args, nodeOperation{done: "isolated", one: (*gns3.Nodes).IsolateContext})
+		}
+		return runNodeOperation(cmd, args, nodeOperation{done: "suspended",
+			one: (*gns3.Nodes).SuspendContext, all: (*gns3.Nodes).SuspendAllContext})
+	},
+}
+
+func init() {
+	suspendCmd.AddCommand(suspendNodesCmd)
+	addNodeOperationFlags(suspendNodesCmd)
+	suspendNodesCmd.Flags().Bool("isolate", false, "suspend the links of the nodes rather than the nodes")
+}
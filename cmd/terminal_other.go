@@ -0,0 +1,34 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+)
+
+var ErrNoRawMode = errors.New("raw terminal mode is not supported on this platform")
+
+// isTerminal returns false as terminals are not detected on this platform.
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw fails as raw mode is not supported on this platform.
+func makeRaw(fd int) (func() error, error) {
+	return nil, ErrNoRawMode
+}
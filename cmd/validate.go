@@ -0,0 +1,80 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+//
+//nolint:exhaustruct
+var validateCmd = &cobra.Command{
+	Use:   "validate FILE|DIR|- [FILE|DIR|-...]",
+	Short: "Checks network documents for errors without loading them",
+	Long: `
+Parses each YAML network document and reports, with its line and column, any
+problem that would prevent it from being loaded: unknown keys, an unsupported
+apiVersion or kind, duplicate node names, links that reference undeclared
+nodes and ports that are used by more than one link.
+
+The GNS3 server is not contacted.
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values, err := renderValues(cmd)
+		if err != nil {
+			return err
+		}
+		return forEachNetwork(args, values, func(source string, network *gns3.Network) error {
+			fmt.Printf("%s: valid\n", source)
+			return nil
+		}, nil)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	addRenderFlags(validateCmd)
+}
+
+// printFileError reports an error processing a file, giving the location of
+// each problem found in a network document.
+func printFileError(filename string, err error) {
+	var netErrs gns3.NetworkErrors
+	if !errors.As(err, &netErrs) {
+		fmt.Printf("ERROR: %s: %v\n", filename, err)
+		return
+	}
+	for _, e := range netErrs {
+		switch {
+		case e.Line > 0 && e.Column > 0:
+			fmt.Printf("ERROR: %s:%d:%d: %s\n", filename, e.Line, e.Column, e.Message)
+		case e.Line > 0:
+			fmt.Printf("ERROR: %s:%d: %s\n", filename, e.Line, e.Message)
+		default:
+			fmt.Printf("ERROR: %s: %s\n", filename, e.Message)
+		}
+	}
+	var rendered *renderedError
+	if errors.As(err, &rendered) {
+		fmt.Printf("NOTE: %s: lines are those of the rendered document, as displayed by the render command\n", filename)
+	}
+}
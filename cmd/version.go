@@ -17,7 +17,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/ciena/gns3ctl/pkg/gns3"
 	"github.com/spf13/cobra"
@@ -46,18 +45,23 @@ var versionCmd = &cobra.Command{
 	Aliases: []string{"ver"},
 	Args:    cobra.NoArgs,
 	Short:   "Display the GNS3 server version",
-	Run: func(cmd *cobra.Command, args []string) {
-		v, err := gns3.Connect().Server().Version()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var v *gns3.ServerVersion
+		ctl, err := connect()
+		if err == nil {
+			v, err = ctl.Server().VersionContext(cmd.Context())
+		}
 		fmt.Printf(clientVersion, Version, Commit)
 		if err != nil {
 			fmt.Printf(serverVersion, "ERROR:", err.Error())
-			os.Exit(1)
+			return err
 		}
 		local := "local"
 		if !v.Local {
 			local = "not local"
 		}
 		fmt.Printf(serverVersion, v.Version, local)
+		return nil
 	},
 }
 
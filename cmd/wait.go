@@ -0,0 +1,89 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+)
+
+// waitCmd represents the wait command
+//
+//nolint:exhaustruct
+var waitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait for subresources to reach a state",
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+}
+
+// addWaitFlags adds the flags used by runWait, with the condition waited
+// for by default.
+func addWaitFlags(cmd *cobra.Command, condition string) {
+	cmd.Flags().String("for", condition, "condition to wait for, status=STATUS or all-STATUS")
+	// this shadows the global --timeout, so requests use its default
+	cmd.Flags().Duration("timeout", 10*time.Minute, "how long to wait for the condition")
+}
+
+// parseWaitCondition returns the node status waited for by a condition,
+// given as status=STATUS or all-STATUS, for example all-started.
+func parseWaitCondition(condition string) (string, error) {
+	var status string
+	switch {
+	case strings.HasPrefix(condition, "status="):
+		status = strings.TrimPrefix(condition, "status=")
+	case strings.HasPrefix(condition, "all-"):
+		status = strings.TrimPrefix(condition, "all-")
+	}
+	if status == "" {
+		return "", &usageError{err: fmt.Errorf("invalid condition '%s', expected status=STATUS or all-STATUS", condition)}
+	}
+	return status, nil
+}
+
+// runWait waits for the nodes given by ID or name, or every node if none
+// are given, to meet the --for condition, and prints the status of each
+// node. The nodes that did not are printed if --timeout expires.
+func runWait(cmd *cobra.Command, nctl *gns3.Nodes, ids []string) error {
+	condition, _ := cmd.Flags().GetString("for")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	status, err := parseWaitCondition(condition)
+	if err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		return &usageError{err: errors.New("--timeout must be greater than zero")}
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	defer cancel()
+	nodes, err := nctl.WaitStatusContext(ctx, status, ids...)
+	for _, node := range nodes {
+		fmt.Printf("%s %s\n", node.Name, node.Status)
+	}
+	if errors.Is(err, gns3.ErrNotReady) {
+		return fmt.Errorf("timed out after %s, %w", timeout, err)
+	}
+	return err
+}
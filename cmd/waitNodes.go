@@ -0,0 +1,73 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// waitNodesCmd represents the wait nodes command
+//
+//nolint:exhaustruct
+var waitNodesCmd = &cobra.Command{
+	Use:     "nodes [flags] NODE [NODE...]",
+	Aliases: []string{"no", "node"},
+	Short:   "Wait for nodes to reach a status",
+	Long: `
+Waits until each of the specified nodes, or every node of the project with
+--all, has the status given by --for, checking as the controller reports
+changes to nodes, or every few seconds:
+
+  gns3ctl wait nodes -p lab r1 r2 --for status=started --timeout 10m
+
+The command fails if the nodes do not all have the status before --timeout
+expires, printing the nodes that do not. --timeout is the time to wait
+rather than the timeout of each request.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+		all, _ := cmd.Flags().GetBool("all")
+		switch {
+		case all && len(args) > 0:
+			return &usageError{err: errors.New("nodes cannot be given with --all")}
+		case !all && len(args) == 0:
+			return &usageError{err: errors.New("at least one node, or --all, must be given")}
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		return runWait(cmd, ctl.Nodes(project.ProjectId), args)
+	},
+}
+
+func init() {
+	waitCmd.AddCommand(waitNodesCmd)
+	addWaitFlags(waitNodesCmd, "status=started")
+	waitNodesCmd.Flags().Bool("all", false, "wait for every node of the project")
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// waitProjectCmd represents the wait project command
+//
+//nolint:exhaustruct
+var waitProjectCmd = &cobra.Command{
+	Use:     "project [flags] [PROJECT]",
+	Aliases: []string{"proj", "projects"},
+	Short:   "Wait for every node of a project to reach a status",
+	Long: `
+Waits until every node of the project, given as an argument or by --project,
+has the status given by --for, checking as the controller reports changes
+to nodes, or every few seconds:
+
+  gns3ctl wait project lab --for all-started
+
+The command fails if the nodes do not all have the status before --timeout
+expires, printing the nodes that do not. --timeout is the time to wait
+rather than the timeout of each request.
+`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pname := viper.GetString("project")
+		if len(args) > 0 {
+			pname = args[0]
+		}
+		if pname == "" {
+			return ErrNoProjectSpecified
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+		if err != nil {
+			return fmt.Errorf("project '%s' not found: %w", pname, err)
+		}
+		return runWait(cmd, ctl.Nodes(project.ProjectId), nil)
+	},
+}
+
+func init() {
+	waitCmd.AddCommand(waitProjectCmd)
+	addWaitFlags(waitProjectCmd, "all-started")
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2022 Ciena Corporation <info@ciena.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// watchCmd represents the watch command
+//
+//nolint:exhaustruct
+var watchCmd = &cobra.Command{
+	Use:   "watch [flags]",
+	Short: "Print the events pushed by the controller",
+	Long: `
+Connects to the notification streams of the controller and of the project,
+and prints each event as it arrives until interrupted. The project must be
+open for its events to be sent, --controller watches only the controller.
+
+Events can be limited to some actions with --action, given either in full,
+such as node.updated, or as a kind, such as node. The periodic ping events
+are only printed with --ping.
+
+  gns3ctl watch -p lab --action node --action log
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		actions, _ := cmd.Flags().GetStringSlice("action")
+		ping, _ := cmd.Flags().GetBool("ping")
+		controller, _ := cmd.Flags().GetBool("controller")
+		if output != "columns" && output != "json" {
+			return &usageError{err: fmt.Errorf("invalid output format '%s', expected columns or json", output)}
+		}
+
+		ctl, err := connect()
+		if err != nil {
+			return err
+		}
+		var projectIDs []string
+		if !controller {
+			pname := viper.GetString("project")
+			if pname == "" {
+				return ErrNoProjectSpecified
+			}
+			project, err := ctl.Projects().GetContext(cmd.Context(), pname)
+			if err != nil {
+				return fmt.Errorf("project '%s' not found: %w", pname, err)
+			}
+			projectIDs = append(projectIDs, project.ProjectId)
+		}
+
+		stream, err := ctl.Notifications(cmd.Context(), projectIDs...)
+		if err != nil {
+			return err
+		}
+		if output == "columns" {
+			fmt.Printf("%-8s  %-16s  %-20s  %s\n", "TIME", "ACTION", "NAME", "DETAIL")
+		}
+		for n := range stream.C {
+			if (n.Action == gns3.ActionPing && !ping) || !matchAction(n, actions) {
+				continue
+			}
+			if output == "json" {
+				j, _ := json.Marshal(n)
+				fmt.Println(string(j))
+				continue
+			}
+			name, detail := describeNotification(n)
+			fmt.Printf("%-8s  %-16s  %-20s  %s\n", time.Now().Format("15:04:05"), n.Action, name, detail)
+		}
+		return stream.Err()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().StringP("output", "o", "columns", "Output format. One of columns, json")
+	watchCmd.Flags().StringSlice("action", nil, "only print events of these actions or kinds, may be repeated")
+	watchCmd.Flags().Bool("ping", false, "print ping events")
+	watchCmd.Flags().Bool("controller", false, "only watch the controller, not the project")
+}
+
+// matchAction returns true if the notification is of one of the actions,
+// or kinds of action, or if there are none.
+func matchAction(n *gns3.Notification, actions []string) bool {
+	for _, action := range actions {
+		if action == n.Action || action == n.Kind() {
+			return true
+		}
+	}
+	return len(actions) == 0
+}
+
+// describeNotification returns the name of what a notification is about
+// and a summary of its event.
+func describeNotification(n *gns3.Notification) (string, string) {
+	switch {
+	case n.Node != nil:
+		return n.Node.Name, n.Node.Status
+	case n.Link != nil:
+		ends := make([]string, len(n.Link.Nodes))
+		for i, end := range n.Link.Nodes {
+			ends[i] = fmt.Sprintf("%s %d/%d", end.NodeId, end.AdapterNumber, end.PortNumber)
+		}
+		detail := strings.Join(ends, " <-> ")
+		if n.Link.Suspend {
+			detail += " (suspended)"
+		}
+		return n.Link.LinkId, detail
+	case n.Compute != nil:
+		status := "disconnected"
+		if n.Compute.Connected {
+			status = "connected"
+		}
+		return n.Compute.Name, fmt.Sprintf("%s, cpu %.1f%%, memory %.1f%%", status,
+			n.Compute.CpuUsagePercent, n.Compute.MemoryUsagePercent)
+	case n.Project != nil:
+		return n.Project.Name, n.Project.Status
+	case n.Log != nil:
+		return "", n.Log.Message
+	case n.Ping != nil:
+		return n.Ping.ComputeId, fmt.Sprintf("cpu %.1f%%, memory %.1f%%", n.Ping.CpuUsagePercent, n.Ping.MemoryUsagePercent)
+	}
+	return "", string(n.Event)
+}
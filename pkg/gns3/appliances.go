@@ -17,6 +17,7 @@ limitations under the License.
 package gns3
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -28,8 +29,6 @@ import (
 	"time"
 
 	grab "github.com/cavaliergopher/grab/v3"
-	units "github.com/docker/go-units"
-	"github.com/spf13/viper"
 	"zgo.at/termfo"
 	"zgo.at/termfo/caps"
 )
@@ -129,6 +128,16 @@ type Appliances struct {
 	gns3 *Gns3
 }
 
+// TemplateName returns the name of the template created when the appliance
+// is imported.
+func (a *Appliance) TemplateName() string {
+	name := a.Name
+	if len(a.Versions) > 0 && a.Versions[0].Name != "" {
+		name += " " + a.Versions[0].Name
+	}
+	return name
+}
+
 var (
 	ImageTypes = map[string]string{TemplateTypeQemu: "QEMU", TemplateTypeDynamips: "IOS", TemplateTypeIou: "IOU", TemplateTypeDocker: "DOCKER"}
 )
@@ -138,8 +147,12 @@ func (g *Gns3) Appliances() *Appliances {
 }
 
 func (a *Appliances) List() ([]Appliance, error) {
+	return a.ListContext(context.Background())
+}
+
+func (a *Appliances) ListContext(ctx context.Context) ([]Appliance, error) {
 	list := []Appliance{}
-	err := a.gns3.Get(AppliancesPath, &list)
+	err := a.gns3.GetContext(ctx, AppliancesPath, &list)
 	if err != nil {
 		return nil, err
 	}
@@ -147,9 +160,13 @@ func (a *Appliances) List() ([]Appliance, error) {
 }
 
 func (a *Appliances) Get(id string) (*Appliance, error) {
+	return a.GetContext(context.Background(), id)
+}
+
+func (a *Appliances) GetContext(ctx context.Context, id string) (*Appliance, error) {
 	var appliance Appliance
 	// Think this may be a UUID, so try to delete directly
-	err := a.gns3.Get(fmt.Sprintf("%s/%s", AppliancesPath, id), &appliance)
+	err := a.gns3.GetContext(ctx, fmt.Sprintf("%s/%s", AppliancesPath, id), &appliance)
 	if err == nil {
 		return &appliance, nil
 	}
@@ -160,21 +177,19 @@ func (a *Appliances) Get(id string) (*Appliance, error) {
 const ImagePath = "%s/images/%s/%s"
 const ImageMd5Path = "%s/images/%s/%s.md5sum"
 
-func (a Appliances) downloadUrlToFile(url, outname, outmd5 string) error {
-	client := grab.NewClient()
+func (a Appliances) downloadUrlToFile(ctx context.Context, url, outname, outmd5 string) error {
+	// downloads share the connections and TLS settings of the controller
+	client := &grab.Client{
+		HTTPClient: a.gns3.client,
+		UserAgent:  a.gns3.userAgent,
+		BufferSize: a.gns3.downloadBufferSize,
+	}
 	req, err := grab.NewRequest(outname, url)
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
 	req.NoResume = true
-	if val, err := units.FromHumanSize(viper.GetString("download-buffer-size")); err == nil {
-		req.BufferSize = int(val)
-	} else {
-		fmt.Printf("ERROR: unable to parse download buffer size '%s', defaulting to '10M'\n",
-			viper.GetString("download-buffer-size"))
-		val, _ := units.FromHumanSize("10MB")
-		req.BufferSize = int(val)
-	}
 
 	ti, _ := termfo.New("")
 	cr := ti.Strings[caps.CarriageReturn]
@@ -212,8 +227,7 @@ Loop:
 
 	// check for errors
 	if err := resp.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Download failed: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("download failed: %w", err)
 	}
 
 	fmt.Printf("Download saved to ./%v \n", resp.Filename)
@@ -246,8 +260,8 @@ func (a *Appliances) generateMd5Sum(filename, md5name string) error {
 	return nil
 }
 
-func (a *Appliances) checkAndDownload(url, imgType, outname, outmd5 string) error {
-	basedir := viper.GetString("base-directory")
+func (a *Appliances) checkAndDownload(ctx context.Context, url, imgType, outname, outmd5 string) error {
+	basedir := a.gns3.baseDirectory
 	filename := fmt.Sprintf(ImagePath, basedir, imgType, outname)
 	md5name := fmt.Sprintf(ImageMd5Path, basedir, imgType, outname)
 
@@ -256,7 +270,7 @@ func (a *Appliances) checkAndDownload(url, imgType, outname, outmd5 string) erro
 	var err error
 	_, err = os.Stat(filename)
 	if os.IsNotExist(err) {
-		err := a.downloadUrlToFile(url, filename, md5name)
+		err := a.downloadUrlToFile(ctx, url, filename, md5name)
 		if err != nil {
 			return err
 		}
@@ -277,7 +291,7 @@ func (a *Appliances) checkAndDownload(url, imgType, outname, outmd5 string) erro
 
 	if string(data) != outmd5 {
 		fmt.Println("MD5 mismatch, initiating download")
-		err := a.downloadUrlToFile(url, filename, md5name)
+		err := a.downloadUrlToFile(ctx, url, filename, md5name)
 		if err != nil {
 			return err
 		}
@@ -294,6 +308,10 @@ func (a *Appliances) checkAndDownload(url, imgType, outname, outmd5 string) erro
 }
 
 func (a *Appliances) Import(file io.Reader, inputDirectory string) (*Appliance, *Template, error) {
+	return a.ImportContext(context.Background(), file, inputDirectory)
+}
+
+func (a *Appliances) ImportContext(ctx context.Context, file io.Reader, inputDirectory string) (*Appliance, *Template, error) {
 	var app Appliance
 
 	decoder := json.NewDecoder(file)
@@ -301,14 +319,9 @@ func (a *Appliances) Import(file io.Reader, inputDirectory string) (*Appliance,
 		return nil, nil, err
 	}
 
-	templateName := app.Name
-	if len(app.Versions) > 0 && app.Versions[0].Name != "" {
-		templateName += " " + app.Versions[0].Name
-	}
-
 	tmpl := &Template{
 		ComputeId:     "local",
-		Name:          templateName,
+		Name:          app.TemplateName(),
 		Usage:         app.Usage,
 		FirstPortName: app.FirstPortName,
 	}
@@ -323,11 +336,11 @@ func (a *Appliances) Import(file io.Reader, inputDirectory string) (*Appliance,
 		// directory. First we will check of it is a URL of local file
 		// reference.
 		if u, err := url.Parse(app.Symbol); err == nil {
-			dest := fmt.Sprintf("%s/symbols/%s", viper.GetString("base-directory"), path.Base(u.Path))
+			dest := fmt.Sprintf("%s/symbols/%s", a.gns3.baseDirectory, path.Base(u.Path))
 			switch strings.ToLower(u.Scheme) {
 			case "http", "https":
 				// Download and write file
-				if err := a.downloadUrlToFile(app.Symbol, dest, ""); err != nil {
+				if err := a.downloadUrlToFile(ctx, app.Symbol, dest, ""); err != nil {
 					return nil, nil, err
 				}
 			case "":
@@ -397,14 +410,14 @@ func (a *Appliances) Import(file io.Reader, inputDirectory string) (*Appliance,
 	// Download images
 	for _, img := range app.Images {
 		if img.DirectDownloadUrl != "" {
-			err := a.checkAndDownload(img.DirectDownloadUrl, ImageTypes[tmpl.TemplateType], img.Filename, img.Md5Sum)
+			err := a.checkAndDownload(ctx, img.DirectDownloadUrl, ImageTypes[tmpl.TemplateType], img.Filename, img.Md5Sum)
 			if err != nil {
 				fmt.Printf("ERROR: check '%s': %v\n", img.DirectDownloadUrl, err)
 				return nil, nil, err
 			}
 			fmt.Printf("INFO: '%s', downloaded and verified\n", img.Filename)
 		} else if img.DownloadUrl != "" {
-			err := a.checkAndDownload(img.DownloadUrl, ImageTypes[tmpl.TemplateType], img.Filename, img.Md5Sum)
+			err := a.checkAndDownload(ctx, img.DownloadUrl, ImageTypes[tmpl.TemplateType], img.Filename, img.Md5Sum)
 			if err != nil {
 				fmt.Printf("ERROR: check '%s': %v\n", img.DownloadUrl, err)
 				return nil, nil, err
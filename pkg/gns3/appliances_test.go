@@ -0,0 +1,102 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+// recordingTransport records the user agent of the requests it sends.
+type recordingTransport struct {
+	mu     sync.Mutex
+	agents []string
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.agents = append(rt.agents, r.URL.Path+" "+r.UserAgent())
+	rt.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestImportDownloads(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	image := []byte("disk image")
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/router.svg":
+			_, _ = w.Write([]byte("<svg/>"))
+		case "/router.qcow2":
+			_, _ = w.Write(image)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer files.Close()
+
+	base := t.TempDir()
+	for _, dir := range []string{"symbols", "images/QEMU"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transport := &recordingTransport{}
+	ctl, err := gns3.Connect(gns3.Options{
+		BaseDirectory: base,
+		HTTPClient:    &http.Client{Transport: transport},
+		UserAgent:     "test-agent",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appliance := fmt.Sprintf(`{
+  "name": "router",
+  "category": "router",
+  "symbol": "%[1]s/router.svg",
+  "qemu": {"arch": "x86_64", "adapters": 2},
+  "images": [{"filename": "router.qcow2", "direct_download_url": "%[1]s/router.qcow2", "md5sum": "%[2]x"}]
+}`, files.URL, md5.Sum(image))
+	if _, _, err := ctl.Appliances().Import(strings.NewReader(appliance), t.TempDir()); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"symbols/router.svg":       "<svg/>",
+		"images/QEMU/router.qcow2": string(image),
+	} {
+		data, err := os.ReadFile(filepath.Join(base, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s: got %q, %v", name, data, err)
+		}
+	}
+	// downloads go through the client given to Connect
+	got := strings.Join(transport.agents, ",")
+	if want := "/router.svg test-agent,/router.qcow2 test-agent"; got != want {
+		t.Errorf("requests: got %s, want %s", got, want)
+	}
+}
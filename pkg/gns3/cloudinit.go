@@ -0,0 +1,229 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// CloudInitVolume is the label cloud-init looks for on a NoCloud disk
+	CloudInitVolume = "cidata"
+	// CloudInitImage is the name of the image written to the directory of
+	// a node, which is removed along with the node
+	CloudInitImage = "cloud-init.iso"
+)
+
+// cloudConfig is the user-data made from the simplified fields.
+//
+//nolint:tagliatelle
+type cloudConfig struct {
+	Hostname          string        `yaml:"hostname,omitempty"`
+	Users             []interface{} `yaml:"users,omitempty"`
+	SSHAuthorizedKeys []string      `yaml:"ssh_authorized_keys,omitempty"`
+	SSHPasswordAuth   *bool         `yaml:"ssh_pwauth,omitempty"`
+	Packages          []string      `yaml:"packages,omitempty"`
+	RunCmd            []string      `yaml:"runcmd,omitempty"`
+}
+
+//nolint:tagliatelle
+type cloudConfigUser struct {
+	Name              string   `yaml:"name"`
+	Sudo              string   `yaml:"sudo,omitempty"`
+	Shell             string   `yaml:"shell,omitempty"`
+	LockPasswd        *bool    `yaml:"lock_passwd,omitempty"`
+	PlainTextPasswd   string   `yaml:"plain_text_passwd,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty"`
+}
+
+type networkConfig struct {
+	Version   int                              `yaml:"version"`
+	Ethernets map[string]networkConfigEthernet `yaml:"ethernets"`
+}
+
+type networkConfigEthernet struct {
+	DHCP4       bool                     `yaml:"dhcp4,omitempty"`
+	Addresses   []string                 `yaml:"addresses,omitempty"`
+	Gateway4    string                   `yaml:"gateway4,omitempty"`
+	Gateway6    string                   `yaml:"gateway6,omitempty"`
+	Nameservers *networkConfigNameserver `yaml:"nameservers,omitempty"`
+}
+
+type networkConfigNameserver struct {
+	Addresses []string `yaml:"addresses"`
+}
+
+// simplified returns true if any of the simplified user-data fields are
+// given.
+func (c *CloudInit) simplified() bool {
+	return c.Hostname != "" || len(c.Users) > 0 || len(c.SSHAuthorizedKeys) > 0 ||
+		len(c.Packages) > 0 || len(c.RunCmd) > 0
+}
+
+// check returns the key and a description of the first problem with the
+// cloud-init configuration, or empty strings if there is none.
+func (c *CloudInit) check() (string, string) {
+	if c.UserData != "" && c.simplified() {
+		return "userData", "cloudInit userData cannot be combined with hostname, users, sshAuthorizedKeys, packages or runcmd"
+	}
+	if c.NetworkConfig != "" && len(c.Interfaces) > 0 {
+		return "networkConfig", "cloudInit networkConfig cannot be combined with interfaces"
+	}
+	for _, user := range c.Users {
+		if user.Name == "" {
+			return "users", "cloudInit user name is required"
+		}
+	}
+	for _, intf := range c.Interfaces {
+		if intf.Name == "" {
+			return "interfaces", "cloudInit interface name is required"
+		}
+		for _, addr := range intf.Addresses {
+			if _, _, err := net.ParseCIDR(addr); err != nil {
+				return "interfaces", fmt.Sprintf("cloudInit interface '%s' address '%s' must be given in CIDR form", intf.Name, addr)
+			}
+		}
+		if intf.Gateway != "" && net.ParseIP(intf.Gateway) == nil {
+			return "interfaces", fmt.Sprintf("cloudInit interface '%s' gateway '%s' is not an IP address", intf.Name, intf.Gateway)
+		}
+	}
+	return "", ""
+}
+
+// Files returns the user-data, meta-data and, if there is any,
+// network-config for a node, given its name and an ID unique to the
+// instance of the node.
+func (c *CloudInit) Files(name, instanceID string) ([]ISOFile, error) {
+	hostname := c.Hostname
+	if hostname == "" {
+		hostname = name
+	}
+
+	userData := c.UserData
+	if userData == "" {
+		data, err := yaml.Marshal(c.cloudConfig(hostname))
+		if err != nil {
+			return nil, fmt.Errorf("user-data: %w", err)
+		}
+		userData = "#cloud-config\n" + string(data)
+	}
+
+	metaData := c.MetaData
+	if metaData == "" {
+		metaData = fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", instanceID, hostname)
+	}
+
+	files := []ISOFile{
+		{Name: "user-data", Data: []byte(userData)},
+		{Name: "meta-data", Data: []byte(metaData)},
+	}
+	networkData := c.NetworkConfig
+	if networkData == "" && len(c.Interfaces) > 0 {
+		data, err := yaml.Marshal(c.networkConfig())
+		if err != nil {
+			return nil, fmt.Errorf("network-config: %w", err)
+		}
+		networkData = string(data)
+	}
+	if networkData != "" {
+		files = append(files, ISOFile{Name: "network-config", Data: []byte(networkData)})
+	}
+	return files, nil
+}
+
+func (c *CloudInit) cloudConfig(hostname string) *cloudConfig {
+	config := &cloudConfig{
+		Hostname:          hostname,
+		SSHAuthorizedKeys: c.SSHAuthorizedKeys,
+		Packages:          c.Packages,
+		RunCmd:            c.RunCmd,
+	}
+	if len(c.Users) == 0 {
+		return config
+	}
+	// keep the default user of the image alongside those declared
+	config.Users = []interface{}{"default"}
+	for _, user := range c.Users {
+		u := cloudConfigUser{
+			Name:              user.Name,
+			Shell:             user.Shell,
+			SSHAuthorizedKeys: user.SSHAuthorizedKeys,
+		}
+		if u.Shell == "" {
+			u.Shell = "/bin/bash"
+		}
+		if user.Sudo {
+			u.Sudo = "ALL=(ALL) NOPASSWD:ALL"
+		}
+		if user.Password != "" {
+			unlocked, auth := false, true
+			u.LockPasswd = &unlocked
+			u.PlainTextPasswd = user.Password
+			config.SSHPasswordAuth = &auth
+		}
+		config.Users = append(config.Users, u)
+	}
+	return config
+}
+
+func (c *CloudInit) networkConfig() *networkConfig {
+	config := &networkConfig{Version: 2, Ethernets: map[string]networkConfigEthernet{}}
+	for _, intf := range c.Interfaces {
+		eth := networkConfigEthernet{DHCP4: intf.DHCP, Addresses: intf.Addresses}
+		if ip := net.ParseIP(intf.Gateway); ip != nil && ip.To4() == nil {
+			eth.Gateway6 = intf.Gateway
+		} else {
+			eth.Gateway4 = intf.Gateway
+		}
+		if len(intf.Nameservers) > 0 {
+			eth.Nameservers = &networkConfigNameserver{Addresses: intf.Nameservers}
+		}
+		config.Ethernets[intf.Name] = eth
+	}
+	return config
+}
+
+func (n *Nodes) CloudInit(node *Node, config *CloudInit) error {
+	return n.CloudInitContext(context.Background(), node, config)
+}
+
+// CloudInitContext builds a NoCloud disk for a QEMU node, writes it to the
+// directory of the node and attaches it to the node as its CD-ROM. The
+// node's ID is the instance ID, so a node that is replaced is set up again
+// at its first boot.
+func (n *Nodes) CloudInitContext(ctx context.Context, node *Node, config *CloudInit) error {
+	if !strings.EqualFold(node.NodeType, TemplateTypeQemu) {
+		return fmt.Errorf("node '%s': cloud-init requires a qemu node, not %s", node.Name, node.NodeType)
+	}
+	files, err := config.Files(node.Name, node.NodeId)
+	if err != nil {
+		return fmt.Errorf("node '%s': %w", node.Name, err)
+	}
+	image, err := BuildISO(CloudInitVolume, files)
+	if err != nil {
+		return fmt.Errorf("node '%s': %w", node.Name, err)
+	}
+	if err := n.AttachISOContext(ctx, node, CloudInitImage, image); err != nil {
+		return fmt.Errorf("node '%s': attach cloud-init image: %w", node.Name, err)
+	}
+	return nil
+}
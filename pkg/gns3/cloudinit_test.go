@@ -0,0 +1,75 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+func TestCloudInitConfigValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		node string
+		want string
+	}{
+		{
+			name: "qemu without config",
+			node: "type: qemu",
+		},
+		{
+			name: "qemu with iso config",
+			node: "type: qemu\n      config:\n        text: hostname r1",
+			want: "cloudInit and a config delivered by the iso method both need the CD-ROM",
+		},
+		{
+			name: "template with iso config",
+			node: "template: Ubuntu\n      config:\n        text: hostname r1",
+			want: "cloudInit and a config delivered by the iso method both need the CD-ROM",
+		},
+		{
+			name: "template with console config",
+			node: "template: Ubuntu\n      config:\n        method: console\n        text: hostname r1",
+		},
+		{
+			name: "vpcs",
+			node: "type: vpcs",
+			want: "cloudInit requires a qemu node, not vpcs",
+		},
+	} {
+		doc := `apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - name: vm1
+      ` + tc.node + `
+      cloudInit:
+        hostname: vm1
+`
+		_, err := gns3.ParseNetwork(strings.NewReader(doc))
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%s: got %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
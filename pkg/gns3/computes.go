@@ -17,12 +17,16 @@ limitations under the License.
 package gns3
 
 import (
+	"context"
 	"fmt"
 )
 
 const (
 	ComputesPath = "v2/computes"
 	ComputePath  = "v2/computes/%s"
+	// ComputeImagePath is the image of an emulator, such as qemu, on a
+	// compute
+	ComputeImagePath = "v2/computes/%s/%s/images/%s"
 )
 
 //nolint:tagliatelle
@@ -49,8 +53,12 @@ func (g *Gns3) Computes() *Computes {
 }
 
 func (c *Computes) List() ([]Compute, error) {
+	return c.ListContext(context.Background())
+}
+
+func (c *Computes) ListContext(ctx context.Context) ([]Compute, error) {
 	list := []Compute{}
-	err := c.gns3.Get(ComputesPath, &list)
+	err := c.gns3.GetContext(ctx, ComputesPath, &list)
 	if err != nil {
 		return nil, err
 	}
@@ -58,15 +66,19 @@ func (c *Computes) List() ([]Compute, error) {
 }
 
 func (c *Computes) Get(id string) (*Compute, error) {
+	return c.GetContext(context.Background(), id)
+}
+
+func (c *Computes) GetContext(ctx context.Context, id string) (*Compute, error) {
 	var compute Compute
 	// Think this may be a UUID, so try to delete directly
-	err := c.gns3.Get(fmt.Sprintf(ComputePath, id), &compute)
+	err := c.gns3.GetContext(ctx, fmt.Sprintf(ComputePath, id), &compute)
 	if err == nil {
 		return &compute, nil
 	}
 
 	// not a UUID, so get a list of computes and search based on name
-	list, err := c.List()
+	list, err := c.ListContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +91,12 @@ func (c *Computes) Get(id string) (*Compute, error) {
 }
 
 func (c *Computes) Create(compute *Compute) (*Compute, error) {
+	return c.CreateContext(context.Background(), compute)
+}
+
+func (c *Computes) CreateContext(ctx context.Context, compute *Compute) (*Compute, error) {
 	var out Compute
-	err := c.gns3.Post(ComputesPath, "application/json", compute, &out)
+	err := c.gns3.PostContext(ctx, ComputesPath, "application/json", compute, &out)
 	if err != nil {
 		return nil, err
 	}
@@ -89,9 +105,24 @@ func (c *Computes) Create(compute *Compute) (*Compute, error) {
 }
 
 func (c *Computes) Delete(id string) (string, error) {
-	compute, err := c.Get(id)
+	return c.DeleteContext(context.Background(), id)
+}
+
+func (c *Computes) DeleteContext(ctx context.Context, id string) (string, error) {
+	compute, err := c.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return compute.ComputeId, c.gns3.Delete(fmt.Sprintf("%s/%s", ComputesPath, compute.ComputeId))
+	return compute.ComputeId, c.gns3.DeleteContext(ctx, fmt.Sprintf("%s/%s", ComputesPath, compute.ComputeId))
+}
+
+// UploadImage writes an image for an emulator, such as qemu, to the images
+// directory of a compute, replacing any image of the same name.
+func (c *Computes) UploadImage(computeID, emulator, name string, data []byte) error {
+	return c.UploadImageContext(context.Background(), computeID, emulator, name, data)
+}
+
+func (c *Computes) UploadImageContext(ctx context.Context, computeID, emulator, name string, data []byte) error {
+	return c.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(ComputeImagePath, computeID, emulator, name),
+		"application/octet-stream", data, nil)
 }
@@ -0,0 +1,329 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Methods of delivering the configuration of a node
+const (
+	// ConfigStartup writes the startup.vpc file of a VPCS node
+	ConfigStartup = "startup"
+	// ConfigFile writes the configuration to Path in the node's directory
+	ConfigFile = "file"
+	// ConfigInterfaces writes /etc/network/interfaces of a Docker node
+	ConfigInterfaces = "interfaces"
+	// ConfigEnvironment sets the environment variables of a Docker node,
+	// given as lines of NAME=VALUE
+	ConfigEnvironment = "environment"
+	// ConfigISO attaches a disk to a QEMU node as its CD-ROM, holding the
+	// configuration as the file Path, by default config.txt
+	ConfigISO = "iso"
+	// ConfigConsole types each line of the configuration on the node's
+	// console once it has started
+	ConfigConsole = "console"
+)
+
+const (
+	DefaultConfigISOFile   = "config.txt"
+	DefaultConfigISOVolume = "config"
+	// ConfigISOImage is the name of the disk written by the iso method in
+	// the node's directory
+	ConfigISOImage = "config.iso"
+)
+
+// consoleConfigTimeout bounds how long the console method waits for the
+// node's prompt and commands.
+var consoleConfigTimeout = DefaultReadinessTimeout
+
+// ConfigMethod delivers the configuration of a node. Methods that need the
+// node to be running are called once it has started and is ready, the
+// others once it has been created.
+type ConfigMethod interface {
+	NeedsStarted() bool
+	Deliver(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error
+}
+
+// ConfigMethodFunc adapts a function to a ConfigMethod.
+type ConfigMethodFunc struct {
+	Started bool
+	Func    func(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error
+}
+
+func (f ConfigMethodFunc) NeedsStarted() bool {
+	return f.Started
+}
+
+func (f ConfigMethodFunc) Deliver(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error {
+	return f.Func(ctx, nodes, node, config, data)
+}
+
+var (
+	configMethodsMu sync.RWMutex
+	configMethods   = map[string]ConfigMethod{
+		ConfigStartup:     ConfigMethodFunc{Func: deliverStartup},
+		ConfigFile:        ConfigMethodFunc{Func: deliverFile},
+		ConfigInterfaces:  ConfigMethodFunc{Func: deliverInterfaces},
+		ConfigEnvironment: ConfigMethodFunc{Func: deliverEnvironment},
+		ConfigISO:         ConfigMethodFunc{Func: deliverISO},
+		ConfigConsole:     ConfigMethodFunc{Started: true, Func: deliverConsole},
+	}
+
+	// DefaultConfigMethods gives the method used for each type of node
+	// when a configuration does not name one
+	DefaultConfigMethods = map[string]string{
+		TypeVpcs:             ConfigStartup,
+		TemplateTypeDocker:   ConfigInterfaces,
+		TemplateTypeQemu:     ConfigISO,
+		TemplateTypeDynamips: ConfigConsole,
+		TemplateTypeIou:      ConfigConsole,
+	}
+)
+
+// RegisterConfigMethod makes a method of delivering configuration
+// available by name, replacing any method of the same name.
+func RegisterConfigMethod(name string, method ConfigMethod) {
+	configMethodsMu.Lock()
+	defer configMethodsMu.Unlock()
+	configMethods[name] = method
+}
+
+// LookupConfigMethod returns the method of delivering configuration with a
+// name.
+func LookupConfigMethod(name string) (ConfigMethod, bool) {
+	configMethodsMu.RLock()
+	defer configMethodsMu.RUnlock()
+	method, ok := configMethods[name]
+	return method, ok
+}
+
+// ConfigMethods returns the names of the registered methods.
+func ConfigMethods() []string {
+	configMethodsMu.RLock()
+	defer configMethodsMu.RUnlock()
+	names := make([]string, 0, len(configMethods))
+	for name := range configMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MethodFor returns the name of the method that delivers the configuration
+// to a type of node.
+func (c *NodeConfig) MethodFor(nodeType string) string {
+	if c.Method != "" {
+		return c.Method
+	}
+	if method, ok := DefaultConfigMethods[strings.ToLower(nodeType)]; ok {
+		return method
+	}
+	return ConfigConsole
+}
+
+// Shorthand returns true if the configuration is given by the VPCS fields
+// rather than as text.
+func (c *NodeConfig) Shorthand() bool {
+	return c.File == "" && c.Text == "" && c.Template == ""
+}
+
+// check returns the key and a description of the first problem with the
+// configuration, or empty strings if there is none.
+func (c *NodeConfig) check() (string, string) {
+	given := 0
+	for _, source := range []string{c.File, c.Text, c.Template} {
+		if source != "" {
+			given++
+		}
+	}
+	switch {
+	case given > 1:
+		return "", "config must give only one of file, text or template"
+	case given == 0 && c.Name == "" && c.Address == "":
+		return "", "config must give a file, text or template"
+	}
+	if c.Method != "" {
+		if _, ok := LookupConfigMethod(c.Method); !ok {
+			return "method", fmt.Sprintf("unknown config method '%s', expected one of %s",
+				c.Method, strings.Join(ConfigMethods(), ", "))
+		}
+	}
+	if c.Method == ConfigFile && c.Path == "" {
+		return "path", "config method file requires a path"
+	}
+	if c.Template != "" {
+		if _, err := os.Stat(c.Template); err != nil {
+			return "template", fmt.Sprintf("config template: %v", err)
+		}
+	}
+	if c.File != "" {
+		if _, err := os.Stat(c.File); err != nil {
+			return "file", fmt.Sprintf("config file: %v", err)
+		}
+	}
+	return "", ""
+}
+
+// ConfigData is available to configuration templates, as the node as it
+// was declared and as it was created on the server.
+type ConfigData struct {
+	Node *NetworkNode
+	Live *Node
+}
+
+// Render returns the configuration of a node, the contents of its file or
+// text, its template rendered with data, or a VPCS startup file built from
+// the shorthand fields.
+func (c *NodeConfig) Render(data *ConfigData) ([]byte, error) {
+	switch {
+	case c.File != "":
+		return os.ReadFile(c.File)
+	case c.Text != "":
+		return []byte(c.Text), nil
+	case c.Template != "":
+		text, err := os.ReadFile(c.Template)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(path.Base(c.Template)).Funcs(TemplateFuncs()).Parse(string(text))
+		if err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	return VpcsStartup(c), nil
+}
+
+// VpcsStartup returns a startup.vpc file that sets the name and address of a
+// VPCS node.
+func VpcsStartup(c *NodeConfig) []byte {
+	var b strings.Builder
+	b.WriteString("# Startup Configuration\n")
+	if c.Name != "" {
+		fmt.Fprintf(&b, "set pcname %s\n", c.Name)
+	}
+	if c.Address != "" {
+		fmt.Fprintf(&b, "ip %s %s %s\n", c.Address, c.Netmask, c.Gateway)
+	}
+	return []byte(b.String())
+}
+
+func (n *Nodes) Configure(node *Node, spec *NetworkNode) error {
+	return n.ConfigureContext(context.Background(), node, spec)
+}
+
+// ConfigureContext renders the configuration declared for a node and
+// delivers it to the node as created on the server.
+func (n *Nodes) ConfigureContext(ctx context.Context, node *Node, spec *NetworkNode) error {
+	config := spec.ConfigFor(node.NodeType)
+	if config == nil {
+		return nil
+	}
+	name := config.MethodFor(node.NodeType)
+	method, ok := LookupConfigMethod(name)
+	if !ok {
+		return fmt.Errorf("node '%s': unknown config method '%s'", node.Name, name)
+	}
+	data, err := config.Render(&ConfigData{Node: spec, Live: node})
+	if err != nil {
+		return fmt.Errorf("node '%s': render config: %w", node.Name, err)
+	}
+	if err := method.Deliver(ctx, n, node, config, data); err != nil {
+		return fmt.Errorf("node '%s': deliver config by %s: %w", node.Name, name, err)
+	}
+	return nil
+}
+
+func deliverStartup(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error {
+	return nodes.WriteFileContext(ctx, node.NodeId, "startup.vpc", data)
+}
+
+func deliverFile(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error {
+	return nodes.WriteFileContext(ctx, node.NodeId, config.Path, data)
+}
+
+func deliverInterfaces(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error {
+	// GNS3 keeps /etc/network of Docker nodes in the node's directory
+	return nodes.WriteFileContext(ctx, node.NodeId, "etc/network/interfaces", data)
+}
+
+func deliverEnvironment(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error {
+	_, err := nodes.UpdateContext(ctx, node.NodeId, &NodeUpdate{
+		Properties: map[string]interface{}{"environment": strings.TrimSpace(string(data))},
+	})
+	return err
+}
+
+func deliverISO(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error {
+	name, volume := config.Path, config.Volume
+	if name == "" {
+		name = DefaultConfigISOFile
+	}
+	if volume == "" {
+		volume = DefaultConfigISOVolume
+	}
+	image, err := BuildISO(volume, []ISOFile{{Name: name, Data: data}})
+	if err != nil {
+		return err
+	}
+	return nodes.AttachISOContext(ctx, node, ConfigISOImage, image)
+}
+
+// AttachISOContext writes an ISO image to the directory of a QEMU node and
+// attaches it to the node as its CD-ROM.
+func (n *Nodes) AttachISOContext(ctx context.Context, node *Node, name string, image []byte) error {
+	if err := n.WriteFileContext(ctx, node.NodeId, name, image); err != nil {
+		return err
+	}
+	_, err := n.UpdateContext(ctx, node.NodeId, &NodeUpdate{
+		Properties: map[string]interface{}{"cdrom_image": path.Join(node.NodeDirectory, name)},
+	})
+	return err
+}
+
+func deliverConsole(ctx context.Context, nodes *Nodes, node *Node, config *NodeConfig, data []byte) error {
+	// a node that never shows its prompt must not stall the load
+	ctx, cancel := context.WithTimeout(ctx, consoleConfigTimeout)
+	defer cancel()
+	session, err := nodes.SessionContext(ctx, node.NodeId, "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) == "" {
+			continue
+		}
+		if _, err := session.Run(ctx, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
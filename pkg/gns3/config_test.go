@@ -0,0 +1,213 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/ciena/gns3ctl/pkg/gns3/gns3test"
+)
+
+// configNode creates a node on the fake controller to deliver a
+// configuration to.
+func configNode(t *testing.T, s *gns3test.Server, nodeType string) (*gns3.Gns3, *gns3.Nodes, *gns3.Node) {
+	t.Helper()
+	ctl := connect(t, s)
+	project, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := ctl.Nodes(project.ProjectId)
+	node, err := nodes.Create(&gns3.Node{Name: "n1", NodeType: nodeType, ComputeId: "local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctl, nodes, node
+}
+
+func TestConfigureFiles(t *testing.T) {
+	for _, tc := range []struct {
+		method   string
+		nodeType string
+		file     string
+	}{
+		{gns3.ConfigStartup, "vpcs", "startup.vpc"},
+		{gns3.ConfigInterfaces, "docker", "etc/network/interfaces"},
+		{gns3.ConfigFile, "qemu", "boot/config.cfg"},
+	} {
+		s := gns3test.NewServer()
+		_, nodes, node := configNode(t, s, tc.nodeType)
+		spec := &gns3.NetworkNode{Name: "n1", Config: &gns3.NodeConfig{
+			Method: tc.method,
+			Path:   "boot/config.cfg",
+			Text:   "auto eth0\n",
+		}}
+		if err := nodes.ConfigureContext(context.Background(), node, spec); err != nil {
+			t.Errorf("%s: %v", tc.method, err)
+		} else if data, ok := s.File(node.NodeId, tc.file); !ok || string(data) != "auto eth0\n" {
+			t.Errorf("%s: %s got %q, %v", tc.method, tc.file, data, ok)
+		}
+		s.Close()
+	}
+}
+
+func TestConfigureEnvironment(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	_, nodes, node := configNode(t, s, "docker")
+	spec := &gns3.NetworkNode{Name: "n1", Config: &gns3.NodeConfig{
+		Method: gns3.ConfigEnvironment,
+		Text:   "\nMODE=router\nDEBUG=1\n\n",
+	}}
+	if err := nodes.ConfigureContext(context.Background(), node, spec); err != nil {
+		t.Fatal(err)
+	}
+	live := s.Nodes(node.ProjectId)[0]
+	if got := live.Properties["environment"]; got != "MODE=router\nDEBUG=1" {
+		t.Errorf("environment: got %q", got)
+	}
+	want := fmt.Sprintf("PUT v2/projects/%s/nodes/%s", node.ProjectId, node.NodeId)
+	found := false
+	for _, r := range s.Requests() {
+		found = found || r == want
+	}
+	if !found {
+		t.Errorf("no %s in %v", want, s.Requests())
+	}
+}
+
+func TestConfigureISO(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	_, nodes, node := configNode(t, s, "qemu")
+	spec := &gns3.NetworkNode{Name: "n1", Config: &gns3.NodeConfig{Text: "hostname r1\n"}}
+	if err := nodes.ConfigureContext(context.Background(), node, spec); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := s.File(node.NodeId, gns3.ConfigISOImage); !ok || len(data) == 0 {
+		t.Errorf("%s not written", gns3.ConfigISOImage)
+	}
+	live := s.Nodes(node.ProjectId)[0]
+	if got, want := live.Properties["cdrom_image"], path.Join(node.NodeDirectory, "config.iso"); got != want {
+		t.Errorf("cdrom_image: got %v, want %s", got, want)
+	}
+}
+
+// consoleNode serves a console that answers each line with its echo and
+// prompt, or with nothing when prompt is empty, and records the lines.
+type consoleNode struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *consoleNode) serve(t *testing.T, prompt string) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimRight(line, "\r\n")
+					if line != "" {
+						c.mu.Lock()
+						c.lines = append(c.lines, line)
+						c.mu.Unlock()
+					}
+					if prompt != "" {
+						fmt.Fprintf(conn, "%s\r\n%s", line, prompt)
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// consoleConfig points the console of a node at a fake and delivers the
+// configuration given by text through it.
+func consoleConfig(t *testing.T, prompt, text string) (*consoleNode, error) {
+	t.Helper()
+	s := gns3test.NewServer()
+	t.Cleanup(s.Close)
+	ctl, nodes, node := configNode(t, s, "vpcs")
+	fake := &consoleNode{}
+	port := fake.serve(t, prompt)
+	nodePath := fmt.Sprintf("v2/projects/%s/nodes/%s", node.ProjectId, node.NodeId)
+	if err := ctl.Put(nodePath, "", map[string]interface{}{"console": port}, node); err != nil {
+		t.Fatal(err)
+	}
+	spec := &gns3.NetworkNode{Name: "n1", Config: &gns3.NodeConfig{Method: gns3.ConfigConsole, Text: text}}
+	return fake, nodes.ConfigureContext(context.Background(), node, spec)
+}
+
+func TestConfigureConsole(t *testing.T) {
+	fake, err := consoleConfig(t, "PC1> ", "ip 10.0.0.1/24\n\n   \r\nsave\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if got := strings.Join(fake.lines, ","); got != "ip 10.0.0.1/24,save" {
+		t.Errorf("commands: got %s", got)
+	}
+}
+
+func TestConfigureConsoleTimeout(t *testing.T) {
+	if *gns3.ConsoleConfigTimeout != gns3.DefaultReadinessTimeout {
+		t.Errorf("timeout: got %v, want %v", *gns3.ConsoleConfigTimeout, gns3.DefaultReadinessTimeout)
+	}
+	old := *gns3.ConsoleConfigTimeout
+	*gns3.ConsoleConfigTimeout = 200 * time.Millisecond
+	defer func() { *gns3.ConsoleConfigTimeout = old }()
+
+	start := time.Now()
+	fake, err := consoleConfig(t, "", "ip 10.0.0.1/24\n")
+	if err == nil {
+		t.Fatal("node without a prompt: no error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gave up after %v", elapsed)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.lines) != 0 {
+		t.Errorf("commands sent before the prompt: %v", fake.lines)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrUnsupportedConsole = errors.New("unsupported-console")
+
+// Telnet commands and options, as given by RFC 854 and RFC 857/858
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWill = 251
+	telnetWont = 252
+	telnetDo   = 253
+	telnetDont = 254
+	telnetIAC  = 255
+
+	telnetOptEcho = 1
+	telnetOptSGA  = 3
+)
+
+// states of the telnet parser
+const (
+	telnetData = iota
+	telnetCommand
+	telnetOption
+	telnetSub
+	telnetSubCommand
+)
+
+// Console is a connection to the telnet console of a node. It negotiates
+// for the node to echo and to suppress go ahead, as a terminal expects,
+// and refuses other options. Reads return the node's output with the
+// telnet commands removed and writes escape the data sent.
+type Console struct {
+	conn net.Conn
+
+	state   int
+	command byte
+	// the options enabled on the node's side and on ours
+	remote map[byte]bool
+	local  map[byte]bool
+
+	writeMu sync.Mutex
+}
+
+// NewConsole wraps a connection to a telnet console.
+func NewConsole(conn net.Conn) *Console {
+	return &Console{conn: conn, remote: map[byte]bool{}, local: map[byte]bool{}}
+}
+
+func (n *Nodes) Console(id string) (*Console, error) {
+	return n.ConsoleContext(context.Background(), id)
+}
+
+// ConsoleContext connects to the telnet console of a node, given by ID or
+// name. The node must be started for its console to accept connections.
+func (n *Nodes) ConsoleContext(ctx context.Context, id string) (*Console, error) {
+	node, err := n.GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if node.ConsoleType != "telnet" || node.Console == 0 {
+		return nil, fmt.Errorf("node '%s' has a %s console rather than telnet: %w", node.Name, node.ConsoleType, ErrUnsupportedConsole)
+	}
+	return n.gns3.DialConsole(ctx, node)
+}
+
+// DialConsole connects to the telnet console of a node.
+func (g *Gns3) DialConsole(ctx context.Context, node *Node) (*Console, error) {
+	var dialer net.Dialer
+	addr := g.ConsoleAddress(node)
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("console of node '%s' at %s: %w", node.Name, addr, err)
+	}
+	return NewConsole(conn), nil
+}
+
+// RemoteAddr returns the address of the console.
+func (c *Console) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadDeadline sets the time after which reads fail with a timeout.
+func (c *Console) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// Read reads the output of the node, answering the telnet negotiation
+// found in it. Reads that only return telnet commands are repeated, so
+// that, as io.Reader asks, no data is only returned with an error.
+func (c *Console) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	buf := make([]byte, len(p))
+	for {
+		count, err := c.conn.Read(buf)
+		data, replies := c.parse(buf[:count])
+		if len(replies) > 0 {
+			if _, werr := c.writeRaw(replies); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		if len(data) > 0 || err != nil {
+			return copy(p, data), err
+		}
+	}
+}
+
+// parse removes the telnet commands from data, returning the replies to
+// the options the node asked about. The state is kept across calls as a
+// command may be split between reads.
+func (c *Console) parse(data []byte) ([]byte, []byte) {
+	out := data[:0]
+	var replies []byte
+	for _, b := range data {
+		switch c.state {
+		case telnetData:
+			if b == telnetIAC {
+				c.state = telnetCommand
+			} else {
+				out = append(out, b)
+			}
+		case telnetCommand:
+			switch b {
+			case telnetIAC:
+				out = append(out, telnetIAC)
+				c.state = telnetData
+			case telnetWill, telnetWont, telnetDo, telnetDont:
+				c.command = b
+				c.state = telnetOption
+			case telnetSB:
+				c.state = telnetSub
+			default:
+				c.state = telnetData
+			}
+		case telnetOption:
+			replies = append(replies, c.negotiate(c.command, b)...)
+			c.state = telnetData
+		case telnetSub:
+			if b == telnetIAC {
+				c.state = telnetSubCommand
+			}
+		case telnetSubCommand:
+			if b == telnetSE {
+				c.state = telnetData
+			} else {
+				c.state = telnetSub
+			}
+		}
+	}
+	return out, replies
+}
+
+// negotiate returns the reply to a request for an option. The node may
+// echo and suppress go ahead, and we will suppress go ahead, everything
+// else is refused. Requests that do not change the state of an option are
+// not answered, so that the two sides do not loop acknowledging each other.
+func (c *Console) negotiate(command, option byte) []byte {
+	reply := func(command byte) []byte {
+		return []byte{telnetIAC, command, option}
+	}
+	switch command {
+	case telnetWill:
+		switch {
+		case option != telnetOptEcho && option != telnetOptSGA:
+			return reply(telnetDont)
+		case !c.remote[option]:
+			c.remote[option] = true
+			return reply(telnetDo)
+		}
+	case telnetWont:
+		if c.remote[option] {
+			c.remote[option] = false
+			return reply(telnetDont)
+		}
+	case telnetDo:
+		switch {
+		case option != telnetOptSGA:
+			return reply(telnetWont)
+		case !c.local[option]:
+			c.local[option] = true
+			return reply(telnetWill)
+		}
+	case telnetDont:
+		if c.local[option] {
+			c.local[option] = false
+			return reply(telnetWont)
+		}
+	}
+	return nil
+}
+
+// Write sends data to the node, escaping any IAC bytes in it.
+func (c *Console) Write(p []byte) (int, error) {
+	data := p
+	if bytes.IndexByte(p, telnetIAC) >= 0 {
+		data = bytes.ReplaceAll(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+	}
+	if _, err := c.writeRaw(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Console) writeRaw(data []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.Write(data)
+}
+
+// Close closes the connection to the console.
+func (c *Console) Close() error {
+	return c.conn.Close()
+}
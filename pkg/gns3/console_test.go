@@ -0,0 +1,193 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// consolePair returns a console connected to the returned end of a
+// loopback connection, which plays the part of the node.
+func consolePair(t *testing.T) (*Console, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		node.Close()
+	})
+	return NewConsole(client), node
+}
+
+// readAtLeast reads from conn until it has n bytes, failing the test if that
+// takes too long.
+func readAtLeast(t *testing.T, conn net.Conn, n int) []byte {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	return buf
+}
+
+func TestConsoleNegotiation(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		request []byte
+		reply   []byte
+	}{
+		{"will echo", []byte{telnetIAC, telnetWill, telnetOptEcho}, []byte{telnetIAC, telnetDo, telnetOptEcho}},
+		{"will sga", []byte{telnetIAC, telnetWill, telnetOptSGA}, []byte{telnetIAC, telnetDo, telnetOptSGA}},
+		{"will other", []byte{telnetIAC, telnetWill, 24}, []byte{telnetIAC, telnetDont, 24}},
+		{"do sga", []byte{telnetIAC, telnetDo, telnetOptSGA}, []byte{telnetIAC, telnetWill, telnetOptSGA}},
+		{"do echo", []byte{telnetIAC, telnetDo, telnetOptEcho}, []byte{telnetIAC, telnetWont, telnetOptEcho}},
+		{"do other", []byte{telnetIAC, telnetDo, 31}, []byte{telnetIAC, telnetWont, 31}},
+		{"wont unset", []byte{telnetIAC, telnetWont, telnetOptEcho}, nil},
+		{"dont unset", []byte{telnetIAC, telnetDont, telnetOptSGA}, nil},
+		{
+			"repeated will",
+			[]byte{telnetIAC, telnetWill, telnetOptEcho, telnetIAC, telnetWill, telnetOptEcho},
+			[]byte{telnetIAC, telnetDo, telnetOptEcho},
+		},
+		{
+			"will then wont",
+			[]byte{telnetIAC, telnetWill, telnetOptEcho, telnetIAC, telnetWont, telnetOptEcho},
+			[]byte{telnetIAC, telnetDo, telnetOptEcho, telnetIAC, telnetDont, telnetOptEcho},
+		},
+		{
+			"do then dont",
+			[]byte{telnetIAC, telnetDo, telnetOptSGA, telnetIAC, telnetDont, telnetOptSGA},
+			[]byte{telnetIAC, telnetWill, telnetOptSGA, telnetIAC, telnetWont, telnetOptSGA},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewConsole(nil)
+			data, replies := c.parse(append(append([]byte("a"), tc.request...), 'b'))
+			if string(data) != "ab" {
+				t.Errorf("data: got %q", data)
+			}
+			if !bytes.Equal(replies, tc.reply) {
+				t.Errorf("replies: got %v, want %v", replies, tc.reply)
+			}
+		})
+	}
+}
+
+func TestConsoleParse(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		chunks [][]byte
+		want   string
+	}{
+		{"escaped iac", [][]byte{{'a', telnetIAC, telnetIAC, 'b'}}, "a\xffb"},
+		{"other command", [][]byte{{'a', telnetIAC, 241, 'b'}}, "ab"},
+		{"subnegotiation", [][]byte{{'a', telnetIAC, telnetSB, 24, 1, telnetIAC, telnetSE, 'b'}}, "ab"},
+		{"iac in subnegotiation", [][]byte{{telnetIAC, telnetSB, 24, telnetIAC, telnetIAC, 'x', telnetIAC, telnetSE, 'b'}}, "b"},
+		{"split command", [][]byte{{'a', telnetIAC}, {telnetWill}, {telnetOptEcho, 'b'}}, "ab"},
+		{"split subnegotiation", [][]byte{{telnetIAC, telnetSB, 24}, {1, telnetIAC}, {telnetSE, 'b'}}, "b"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewConsole(nil)
+			var got []byte
+			for _, chunk := range tc.chunks {
+				data, _ := c.parse(append([]byte(nil), chunk...))
+				got = append(got, data...)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConsoleRead(t *testing.T) {
+	c, node := consolePair(t)
+	negotiation := []byte{
+		telnetIAC, telnetWill, telnetOptEcho,
+		telnetIAC, telnetWill, telnetOptSGA,
+		telnetIAC, telnetDo, 24,
+	}
+	go func() {
+		_, _ = node.Write(negotiation)
+		// so that the console's first read holds only telnet commands
+		time.Sleep(50 * time.Millisecond)
+		_, _ = node.Write([]byte("login: "))
+	}()
+
+	buf := make([]byte, 64)
+	count, err := c.Read(buf)
+	if err != nil || string(buf[:count]) != "login: " {
+		t.Fatalf("got %q, %v", buf[:count], err)
+	}
+	want := []byte{
+		telnetIAC, telnetDo, telnetOptEcho,
+		telnetIAC, telnetDo, telnetOptSGA,
+		telnetIAC, telnetWont, 24,
+	}
+	if got := readAtLeast(t, node, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("replies: got %v, want %v", got, want)
+	}
+}
+
+func TestConsoleReadOnlyCommands(t *testing.T) {
+	c, node := consolePair(t)
+	if _, err := node.Write([]byte{telnetIAC, telnetWill, telnetOptEcho}); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	count, err := c.Read(make([]byte, 64))
+	var ne net.Error
+	if count != 0 || !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("got %d, %v, want a timeout", count, err)
+	}
+
+	// the reply is read so that closing the node's end is not a reset
+	readAtLeast(t, node, 3)
+	node.Close()
+	_ = c.SetReadDeadline(time.Time{})
+	if count, err := c.Read(make([]byte, 64)); count != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("got %d, %v, want io.EOF", count, err)
+	}
+}
+
+func TestConsoleWrite(t *testing.T) {
+	c, node := consolePair(t)
+	count, err := c.Write([]byte{'a', telnetIAC, 'b'})
+	if err != nil || count != 3 {
+		t.Fatalf("got %d, %v", count, err)
+	}
+	if got, want := readAtLeast(t, node, 4), []byte{'a', telnetIAC, telnetIAC, 'b'}; !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
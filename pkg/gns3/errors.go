@@ -18,8 +18,18 @@ package gns3
 
 import "errors"
 
+// Error categories, errors returned by the API can be tested against these
+// with errors.Is.
+var (
+	ErrNotFound          = errors.New("not-found")
+	ErrConflict          = errors.New("conflict")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrServerUnavailable = errors.New("server-unavailable")
+	ErrValidation        = errors.New("validation")
+)
+
 var (
-	ErrNotFound           = errors.New("not-found")
 	ErrMd5Mismatch        = errors.New("md5-mismatch")
 	ErrNoProjectSpecified = errors.New("no-project-specified")
+	ErrNotReady           = errors.New("not-ready")
 )
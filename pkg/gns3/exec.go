@@ -0,0 +1,218 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Prompts recognised by default, matched against the last line of output.
+// They require the prompt to start with a name, such as a hostname,
+// user@host:dir or [user@host dir], so that output ending in one of the
+// prompt characters is not taken for a prompt.
+const (
+	PromptVpcs  = `^\S+> ?$`
+	PromptIos   = `^[\w.:()/-]+(\([\w.-]+\))?[>#] ?$`
+	PromptLinux = `^(\[[\w.@:~/ -]+\]|[A-Za-z_~/][\w.@:~/-]*) ?[$#] ?$`
+	// PromptDefault is used for node types without a prompt of their own
+	PromptDefault = `^(\[[\w.@:~/ -]+\]|[A-Za-z_~/][\w.@:~/()-]*) ?[>#$%] ?$`
+)
+
+// DefaultPrompts gives the prompt expected on the console of each type of
+// node.
+var DefaultPrompts = map[string]string{
+	"vpcs":     PromptVpcs,
+	"dynamips": PromptIos,
+	"iou":      PromptIos,
+	"docker":   PromptLinux,
+	"qemu":     PromptDefault,
+}
+
+const (
+	// promptSettle is how long the console must be quiet after a prompt
+	// for it to be taken as the last one when a session starts
+	promptSettle = 500 * time.Millisecond
+	// commandSettle is how long the console must be quiet after a prompt
+	// for a command to be taken as done, so that output that only looks
+	// like a prompt is read past
+	commandSettle = 200 * time.Millisecond
+	// promptPoll bounds each read, so that the context is checked
+	promptPoll = 250 * time.Millisecond
+)
+
+var ansiEscapeRE = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]|\x1b\][^\x07]*\x07`)
+
+// Session runs commands on the console of a node, taking each command to
+// be done when the prompt is seen at the end of its output.
+type Session struct {
+	console *Console
+	prompt  *regexp.Regexp
+	output  []byte
+}
+
+// NewSession starts a session on a console, where prompt matches a line
+// that is a prompt. It waits for the prompt to be shown before returning.
+func NewSession(ctx context.Context, console *Console, prompt string) (*Session, error) {
+	re, err := regexp.Compile(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt '%s': %w", prompt, err)
+	}
+	s := &Session{console: console, prompt: re}
+	if _, err := s.console.Write([]byte("\r\n")); err != nil {
+		return nil, err
+	}
+	if _, err := s.expectPrompt(ctx, promptSettle); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (n *Nodes) Session(id, prompt string) (*Session, error) {
+	return n.SessionContext(context.Background(), id, prompt)
+}
+
+// SessionContext connects to the console of a node, given by ID or name,
+// and starts a session on it. An empty prompt uses the default for the
+// type of node.
+func (n *Nodes) SessionContext(ctx context.Context, id, prompt string) (*Session, error) {
+	node, err := n.GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if prompt == "" {
+		prompt = DefaultPrompt(node.NodeType)
+	}
+	if node.ConsoleType != "telnet" || node.Console == 0 {
+		return nil, fmt.Errorf("node '%s' has a %s console rather than telnet: %w", node.Name, node.ConsoleType, ErrUnsupportedConsole)
+	}
+	console, err := n.gns3.DialConsole(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSession(ctx, console, prompt)
+	if err != nil {
+		console.Close()
+		return nil, fmt.Errorf("node '%s': %w", node.Name, err)
+	}
+	return s, nil
+}
+
+// DefaultPrompt returns the prompt expected on the console of a type of
+// node.
+func DefaultPrompt(nodeType string) string {
+	if prompt, ok := DefaultPrompts[nodeType]; ok {
+		return prompt
+	}
+	return PromptDefault
+}
+
+// Run sends a command to the console and returns its output, without the
+// echo of the command or the prompt that followed it, once the prompt is
+// seen and the console has been quiet for a moment.
+func (s *Session) Run(ctx context.Context, command string) (string, error) {
+	if _, err := s.console.Write([]byte(command + "\r\n")); err != nil {
+		return "", err
+	}
+	output, err := s.expectPrompt(ctx, commandSettle)
+	if err != nil {
+		return "", fmt.Errorf("command '%s': %w", command, err)
+	}
+	lines := strings.Split(output, "\n")
+	// the echo of the command follows the previous prompt
+	if len(lines) > 0 && strings.HasSuffix(strings.TrimSpace(lines[0]), strings.TrimSpace(command)) {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Close closes the console of the session.
+func (s *Session) Close() error {
+	return s.console.Close()
+}
+
+// expectPrompt reads the console until its last line is a prompt and no
+// more output has been read for settle, and returns the output before the
+// prompt with line endings as \n and terminal escape sequences removed.
+func (s *Session) expectPrompt(ctx context.Context, settle time.Duration) (string, error) {
+	buf := make([]byte, 4096)
+	var prompted time.Time
+	for {
+		// escape sequences may be split across reads, so are removed from
+		// the output as a whole
+		output := cleanOutput(s.output)
+		at := s.promptAt(output)
+		switch {
+		case at < 0:
+			prompted = time.Time{}
+		case prompted.IsZero():
+			prompted = time.Now()
+		}
+		if at >= 0 && time.Since(prompted) >= settle {
+			s.output = nil
+			return string(output[:at]), nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			last := bytes.TrimRight(output, "\n")
+			last = last[bytes.LastIndexByte(last, '\n')+1:]
+			return "", fmt.Errorf("prompt not seen, last output %q: %w", last, err)
+		}
+		deadline := time.Now().Add(promptPoll)
+		if !prompted.IsZero() && prompted.Add(settle).Before(deadline) {
+			deadline = prompted.Add(settle)
+		}
+		_ = s.console.SetReadDeadline(deadline)
+		count, err := s.console.Read(buf)
+		if count > 0 {
+			// the console is quiet once nothing follows the prompt
+			prompted = time.Time{}
+		}
+		s.output = append(s.output, buf[:count]...)
+		if len(s.output) > consoleHistory*16 {
+			return "", fmt.Errorf("output larger than %d bytes without a prompt", consoleHistory*16)
+		}
+		var ne net.Error
+		if err != nil && !(errors.As(err, &ne) && ne.Timeout()) {
+			return "", err
+		}
+	}
+}
+
+// promptAt returns the offset of the last line of output if it is a
+// prompt, or -1.
+func (s *Session) promptAt(output []byte) int {
+	start := bytes.LastIndexByte(output, '\n') + 1
+	if s.prompt.Match(output[start:]) || s.prompt.Match(bytes.TrimRight(output[start:], " \t")) {
+		return start
+	}
+	return -1
+}
+
+// cleanOutput removes carriage returns, NULs and terminal escape sequences
+// from console output.
+func cleanOutput(data []byte) []byte {
+	data = ansiEscapeRE.ReplaceAll(data, nil)
+	data = bytes.ReplaceAll(data, []byte{'\r'}, nil)
+	return bytes.ReplaceAll(data, []byte{0}, nil)
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultPrompts(t *testing.T) {
+	for _, tc := range []struct {
+		prompt string
+		match  []string
+		reject []string
+	}{
+		{
+			prompt: PromptVpcs,
+			match:  []string{"PC1> ", "pc-a>"},
+			reject: []string{"PC1 > ", "> "},
+		},
+		{
+			prompt: PromptIos,
+			match:  []string{"R1#", "R1>", "R1(config)#", "R1(config-if)# ", "switch.lab#"},
+			reject: []string{"Building configuration... #", "Total 5 >"},
+		},
+		{
+			prompt: PromptLinux,
+			match: []string{
+				"root@host:~# ", "user@host:/tmp$ ", "/ # ", "bash-5.1$ ", "sh-4.2# ",
+				"[root@host ~]# ", "~ $ ",
+			},
+			reject: []string{
+				"Welcome to the lab #", "Total: 5 $", "# ", "$", "50$", "#include <stdio.h>",
+			},
+		},
+		{
+			prompt: PromptDefault,
+			match: []string{
+				"R1#", "R1(config)#", "user@router> ", "user@router# ", "host% ", "root@host:~# ",
+				"[admin@mikrotik] > ",
+			},
+			reject: []string{"100%", "Progress: 50%", "a -> b >", "=> ", "Total: 5 $"},
+		},
+	} {
+		re := regexp.MustCompile(tc.prompt)
+		for _, line := range tc.match {
+			if !re.MatchString(line) {
+				t.Errorf("%s does not match %q", tc.prompt, line)
+			}
+		}
+		for _, line := range tc.reject {
+			if re.MatchString(line) {
+				t.Errorf("%s matches %q", tc.prompt, line)
+			}
+		}
+	}
+}
+
+// step is output written by a fake node, after a delay.
+type step struct {
+	delay time.Duration
+	data  string
+}
+
+// fakeNode answers the lines sent to its console from a transcript, which
+// maps each line, without its line ending, to the output the node sends,
+// echo included.
+func fakeNode(t *testing.T, transcript map[string][]step) *Console {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		node.Close()
+	})
+	go func() {
+		r := bufio.NewReader(node)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			for _, s := range transcript[strings.TrimRight(line, "\r\n")] {
+				time.Sleep(s.delay)
+				if _, err := node.Write([]byte(s.data)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return NewConsole(client)
+}
+
+func TestSessionTranscripts(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		prompt     string
+		transcript map[string][]step
+		command    string
+		want       string
+	}{
+		{
+			name:   "vpcs",
+			prompt: PromptVpcs,
+			transcript: map[string][]step{
+				"": {{data: "\r\n\r\nPC1> "}},
+				"show ip": {{data: "show ip\r\n\r\n" +
+					"NAME        : PC1[1]\r\n" +
+					"IP/MASK     : 10.0.0.1/24\r\n" +
+					"GATEWAY     : 10.0.0.254\r\n" +
+					"\r\nPC1> "}},
+			},
+			command: "show ip",
+			want: "\nNAME        : PC1[1]\nIP/MASK     : 10.0.0.1/24\n" +
+				"GATEWAY     : 10.0.0.254\n\n",
+		},
+		{
+			name:   "ios",
+			prompt: PromptIos,
+			transcript: map[string][]step{
+				"": {{data: "\r\nR1#"}},
+				"show ip interface brief": {
+					{data: "show ip interface brief\r\n"},
+					{delay: 20 * time.Millisecond, data: "Interface              IP-Address      OK? Method Status                Protocol\r\n"},
+					{delay: 20 * time.Millisecond, data: "FastEthernet0/0        10.0.0.1        YES manual up                    up      \r\n"},
+					{delay: 20 * time.Millisecond, data: "R1#"},
+				},
+			},
+			command: "show ip interface brief",
+			want: "Interface              IP-Address      OK? Method Status                Protocol\n" +
+				"FastEthernet0/0        10.0.0.1        YES manual up                    up      \n",
+		},
+		{
+			name:   "alpine with escapes and a banner ending in #",
+			prompt: PromptLinux,
+			transcript: map[string][]step{
+				"": {{data: "\r\n\x1b[?2004h/ # "}},
+				"cat /etc/motd": {{data: "cat /etc/motd\r\n\x1b[?2004l" +
+					"Welcome to the lab #\r\n" +
+					"\x1b[?2004h/ # "}},
+			},
+			command: "cat /etc/motd",
+			want:    "Welcome to the lab #\n",
+		},
+		{
+			name:   "output that looks like a prompt is read past",
+			prompt: PromptLinux,
+			transcript: map[string][]step{
+				"": {{data: "root@host:~# "}},
+				"./setup.sh": {
+					{data: "./setup.sh\r\nstep 1\r\nsetup$ "},
+					{delay: 50 * time.Millisecond, data: "\r\nstep 2\r\n"},
+					{delay: 50 * time.Millisecond, data: "root@host:~# "},
+				},
+			},
+			command: "./setup.sh",
+			want:    "step 1\nsetup$ \nstep 2\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			s, err := NewSession(ctx, fakeNode(t, tc.transcript), tc.prompt)
+			if err != nil {
+				t.Fatalf("session: %v", err)
+			}
+			got, err := s.Run(ctx, tc.command)
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSessionStartSettles(t *testing.T) {
+	// the output of a command left running before the session started
+	// arrives after the first prompt
+	transcript := map[string][]step{
+		"": {
+			{data: "PC1> "},
+			{delay: 100 * time.Millisecond, data: "\r\n84 bytes from 10.0.0.2 icmp_seq=5 ttl=64 time=0.5 ms\r\n\r\nPC1> "},
+		},
+		"show": {{data: "show\r\nNAME   IP/MASK\r\n\r\nPC1> "}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := NewSession(ctx, fakeNode(t, transcript), PromptVpcs)
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	got, err := s.Run(ctx, "show")
+	if err != nil || got != "NAME   IP/MASK\n\n" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestSessionPromptNotSeen(t *testing.T) {
+	transcript := map[string][]step{"": {{data: "Booting...\r\n"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_, err := NewSession(ctx, fakeNode(t, transcript), PromptVpcs)
+	if err == nil || !strings.Contains(err.Error(), `last output "Booting..."`) {
+		t.Errorf("got %v", err)
+	}
+}
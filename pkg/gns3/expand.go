@@ -0,0 +1,246 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	GeneratorFullMesh  = "full-mesh"
+	GeneratorSpineLeaf = "spine-leaf"
+	GeneratorRing      = "ring"
+	GeneratorStar      = "star"
+	GeneratorChain     = "chain"
+)
+
+// Expand replaces node groups with the nodes they stand for, adds the
+// links described by the generators and allocates the addresses of the
+// IPAM pools. It does nothing to a network that has already been expanded.
+func (n *Network) Expand() error {
+	if errs := n.expand(nil); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (n *Network) expand(root *yaml.Node) NetworkErrors {
+	r := &networkReporter{root: root}
+
+	// groups maps the name pattern of each group to its nodes
+	groups := map[string][]string{}
+	var nodes []NetworkNode
+	for i, node := range n.Spec.Nodes {
+		at := node.yamlPath(i)
+		if node.Count == 0 {
+			node.path = at
+			nodes = append(nodes, node)
+			continue
+		}
+		if node.Count < 0 {
+			r.report(fmt.Sprintf("node '%s' count must not be negative", node.Name), subPath(at, "count")...)
+			continue
+		}
+		if !strings.Contains(node.Name, "%") {
+			r.report(fmt.Sprintf("node group '%s' name must contain a format verb, such as %%d", node.Name), subPath(at, "name")...)
+			continue
+		}
+		start := 1
+		if node.Start != nil {
+			start = *node.Start
+		}
+		for j := 0; j < node.Count; j++ {
+			member := node
+			member.Name = fmt.Sprintf(node.Name, start+j)
+			if strings.Contains(member.Name, "%!") {
+				r.report(fmt.Sprintf("node group '%s' name is not a valid format", node.Name), subPath(at, "name")...)
+				break
+			}
+			if node.X != nil || node.DX != 0 {
+				x, _ := node.Position()
+				x += j * node.DX
+				member.X = &x
+			}
+			if node.Y != nil || node.DY != 0 {
+				_, y := node.Position()
+				y += j * node.DY
+				member.Y = &y
+			}
+			member.Count, member.Start, member.DX, member.DY = 0, nil, 0, 0
+			if strings.Contains(member.Label, "%") {
+				member.Label = fmt.Sprintf(node.Label, start+j)
+			}
+			if node.Config != nil {
+				config := *node.Config
+				if strings.Contains(config.Name, "%") {
+					config.Name = fmt.Sprintf(config.Name, start+j)
+				}
+				member.Config = &config
+			}
+			member.path = at
+			nodes = append(nodes, member)
+			groups[node.Name] = append(groups[node.Name], member.Name)
+		}
+	}
+
+	declared := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		declared[node.Name] = true
+	}
+
+	// resolve returns the names of the nodes referenced by refs, each once
+	// even when the references overlap
+	resolve := func(refs []string, at []interface{}) []string {
+		var names []string
+		seen := map[string]bool{}
+		add := func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		for i, ref := range refs {
+			if members, ok := groups[ref]; ok {
+				for _, member := range members {
+					add(member)
+				}
+				continue
+			}
+			if declared[ref] {
+				add(ref)
+				continue
+			}
+			found := false
+			for _, node := range nodes {
+				if ok, _ := path.Match(ref, node.Name); ok {
+					add(node.Name)
+					found = true
+				}
+			}
+			if !found {
+				r.report(fmt.Sprintf("no nodes match '%s'", ref), subPath(at, i)...)
+			}
+		}
+		return names
+	}
+
+	// dependencies may name groups and patterns too
+	for i := range nodes {
+		if len(nodes[i].DependsOn) == 0 {
+			continue
+		}
+		var deps []string
+		for _, dep := range resolve(nodes[i].DependsOn, subPath(nodes[i].path, "dependsOn")) {
+			if dep != nodes[i].Name {
+				deps = append(deps, dep)
+			}
+		}
+		nodes[i].DependsOn = deps
+	}
+
+	// linked holds the unordered pairs of nodes that have a link
+	type pair struct{ a, z string }
+	linked := map[pair]bool{}
+	key := func(a, z string) pair {
+		if z < a {
+			a, z = z, a
+		}
+		return pair{a, z}
+	}
+
+	links := make([]NetworkLink, 0, len(n.Spec.Links))
+	for i, link := range n.Spec.Links {
+		link.path = link.yamlPath(i)
+		links = append(links, link)
+		linked[key(link.AEnd.Name, link.ZEnd.Name)] = true
+	}
+	// generated links leave the ports to be picked when they are created,
+	// and are not added between a node and itself or between nodes that
+	// are already linked, so that overlapping generators link a pair once
+	connect := func(a, z string, at []interface{}) {
+		if a == z || linked[key(a, z)] {
+			return
+		}
+		linked[key(a, z)] = true
+		links = append(links, NetworkLink{AEnd: LinkEnd{Name: a}, ZEnd: LinkEnd{Name: z}, path: at})
+	}
+
+	for i, gen := range n.Spec.Generators {
+		at := []interface{}{"spec", "generators", i}
+		members := resolve(gen.Nodes, subPath(at, "nodes"))
+		switch gen.Type {
+		case GeneratorFullMesh:
+			for a := 0; a < len(members); a++ {
+				for z := a + 1; z < len(members); z++ {
+					connect(members[a], members[z], at)
+				}
+			}
+		case GeneratorChain, GeneratorRing:
+			for a := 0; a+1 < len(members); a++ {
+				connect(members[a], members[a+1], at)
+			}
+			if gen.Type == GeneratorRing && len(members) > 2 {
+				connect(members[len(members)-1], members[0], at)
+			}
+		case GeneratorStar:
+			if gen.Hub == "" {
+				r.report("star generator requires a hub node", at...)
+				continue
+			}
+			hub := resolve([]string{gen.Hub}, subPath(at, "hub"))
+			if len(hub) != 1 {
+				if len(hub) > 1 {
+					r.report(fmt.Sprintf("star generator hub '%s' matches more than one node", gen.Hub), subPath(at, "hub")...)
+				}
+				continue
+			}
+			for _, member := range members {
+				connect(hub[0], member, at)
+			}
+		case GeneratorSpineLeaf:
+			if len(gen.Spines) == 0 || len(gen.Leaves) == 0 {
+				r.report("spine-leaf generator requires spines and leaves", at...)
+				continue
+			}
+			spines := resolve(gen.Spines, subPath(at, "spines"))
+			leaves := resolve(gen.Leaves, subPath(at, "leaves"))
+			for _, spine := range spines {
+				for _, leaf := range leaves {
+					connect(spine, leaf, at)
+				}
+			}
+		default:
+			r.report(fmt.Sprintf("unsupported generator type '%s', expected one of %s", gen.Type,
+				strings.Join([]string{GeneratorFullMesh, GeneratorSpineLeaf, GeneratorRing, GeneratorStar, GeneratorChain}, ", ")),
+				subPath(at, "type")...)
+		}
+	}
+
+	n.allocate(r, nodes, links, resolve)
+
+	if len(r.errs) > 0 {
+		return r.errs
+	}
+	n.Spec.Nodes = nodes
+	n.Spec.Links = links
+	n.Spec.Generators = nil
+	return nil
+}
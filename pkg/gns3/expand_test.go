@@ -0,0 +1,220 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const expandHeader = `apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - name: spine-%d
+      count: 2
+      type: ethernet_switch
+      x: 0
+      dx: 100
+    - name: leaf-%02d
+      count: 3
+      type: ethernet_switch
+      y: 100
+      dx: 100
+`
+
+func parseExpanded(t *testing.T, spec string) (*Network, error) {
+	t.Helper()
+	return ParseNetwork(strings.NewReader(expandHeader + spec))
+}
+
+func linkPairs(network *Network) string {
+	var pairs []string
+	for _, link := range network.Spec.Links {
+		pairs = append(pairs, link.AEnd.Name+"-"+link.ZEnd.Name)
+	}
+	return strings.Join(pairs, " ")
+}
+
+func TestExpandGroups(t *testing.T) {
+	network, err := parseExpanded(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, node := range network.Spec.Nodes {
+		x, y := node.Position()
+		names = append(names, fmt.Sprintf("%s@%d,%d", node.Name, x, y))
+	}
+	want := "spine-1@0,0 spine-2@100,0 leaf-01@0,100 leaf-02@100,100 leaf-03@200,100"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestExpandGenerators(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec string
+		want string
+	}{
+		{
+			name: "spine-leaf",
+			spec: `  generators:
+    - type: spine-leaf
+      spines: [spine-%d]
+      leaves: ["leaf-*"]
+`,
+			want: "spine-1-leaf-01 spine-1-leaf-02 spine-1-leaf-03 spine-2-leaf-01 spine-2-leaf-02 spine-2-leaf-03",
+		},
+		{
+			name: "overlapping references",
+			spec: `  generators:
+    - type: full-mesh
+      nodes: [spine-%d, "spine-*", spine-1]
+`,
+			want: "spine-1-spine-2",
+		},
+		{
+			name: "two generators over the same pairs",
+			spec: `  generators:
+    - type: chain
+      nodes: [leaf-%02d]
+    - type: ring
+      nodes: [leaf-%02d]
+`,
+			want: "leaf-01-leaf-02 leaf-02-leaf-03 leaf-03-leaf-01",
+		},
+		{
+			name: "reversed pair",
+			spec: `  generators:
+    - type: chain
+      nodes: [leaf-01, leaf-02]
+    - type: chain
+      nodes: [leaf-02, leaf-01]
+`,
+			want: "leaf-01-leaf-02",
+		},
+		{
+			name: "explicit link",
+			spec: `  links:
+    - aEnd:
+        name: leaf-02
+      zEnd:
+        name: spine-1
+  generators:
+    - type: spine-leaf
+      spines: [spine-1]
+      leaves: ["leaf-0[12]"]
+`,
+			want: "leaf-02-spine-1 spine-1-leaf-01",
+		},
+		{
+			name: "spine in leaves",
+			spec: `  generators:
+    - type: spine-leaf
+      spines: [spine-1]
+      leaves: ["spine-*"]
+`,
+			want: "spine-1-spine-2",
+		},
+		{
+			name: "star",
+			spec: `  generators:
+    - type: star
+      hub: spine-1
+      nodes: ["*"]
+`,
+			want: "spine-1-spine-2 spine-1-leaf-01 spine-1-leaf-02 spine-1-leaf-03",
+		},
+		{
+			name: "ring of two",
+			spec: `  generators:
+    - type: ring
+      nodes: [spine-%d]
+`,
+			want: "spine-1-spine-2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			network, err := parseExpanded(t, tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := linkPairs(network); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+			if network.Spec.Generators != nil {
+				t.Errorf("generators were not removed")
+			}
+		})
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec string
+		want string
+	}{
+		{
+			name: "no match",
+			spec: `  generators:
+    - type: chain
+      nodes: ["core-*"]
+`,
+			want: "no nodes match 'core-*'",
+		},
+		{
+			name: "star without hub",
+			spec: `  generators:
+    - type: star
+      nodes: ["*"]
+`,
+			want: "star generator requires a hub node",
+		},
+		{
+			name: "star with several hubs",
+			spec: `  generators:
+    - type: star
+      hub: "spine-*"
+      nodes: ["*"]
+`,
+			want: "matches more than one node",
+		},
+		{
+			name: "unknown type",
+			spec: `  generators:
+    - type: tree
+      nodes: ["*"]
+`,
+			want: "unsupported generator type 'tree'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseExpanded(t, tc.spec)
+			var errs NetworkErrors
+			if !errors.As(err, &errs) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
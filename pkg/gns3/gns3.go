@@ -20,203 +20,290 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
 
-	"github.com/spf13/viper"
+const (
+	DefaultBaseURL   = "http://localhost:3080"
+	DefaultTimeout   = 20 * time.Second
+	DefaultUserAgent = "gns3ctl"
+	// DefaultDownloadBufferSize is the size in bytes of the buffer used to
+	// download images and symbols
+	DefaultDownloadBufferSize = 10 * 1000 * 1000
 )
 
-type Gns3 struct {
+var ErrNoCertificates = errors.New("no-certificates-found")
+
+// Options describes how to connect to a GNS3 controller. The zero value
+// connects to DefaultBaseURL without authentication.
+type Options struct {
+	// BaseURL is the scheme, host and port of the controller, for example
+	// https://gns3.example.com:3080. A value without a scheme is treated
+	// as http.
+	BaseURL string
+
+	// Username and Password are used for basic authentication when
+	// Username is not empty.
+	Username string
+	Password string
+
+	// Timeout bounds each individual request. Zero means DefaultTimeout,
+	// a negative value disables the timeout.
+	Timeout time.Duration
+
+	// TLSConfig is used as is when set, otherwise a configuration is built
+	// from CAFile and InsecureSkipVerify.
+	TLSConfig          *tls.Config
+	CAFile             string
+	InsecureSkipVerify bool
+
+	// HTTPClient, when set, is used for all requests and the TLS options
+	// above are ignored.
+	HTTPClient *http.Client
+
+	// UserAgent is sent with every request, defaults to DefaultUserAgent.
+	UserAgent string
+
+	// Retry decides if failed requests are attempted again, nil disables
+	// retries.
+	Retry RetryPolicy
+
+	// BaseDirectory is the local GNS3 directory into which the images and
+	// symbols of imported appliances are downloaded, defaults to GNS3 in
+	// the home directory.
+	BaseDirectory string
+
+	// DownloadBufferSize is the size in bytes of the buffer used for
+	// downloads, defaults to DefaultDownloadBufferSize.
+	DownloadBufferSize int
 }
 
-func Connect() *Gns3 {
-	return &Gns3{}
+type Gns3 struct {
+	baseURL   string
+	username  string
+	password  string
+	timeout   time.Duration
+	userAgent string
+	client    *http.Client
+	retry     RetryPolicy
+
+	baseDirectory      string
+	downloadBufferSize int
 }
 
-func (g *Gns3) Get(path string, data interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
-	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
-		fmt.Sprintf("http://%s/%s", viper.GetString("address"), path), nil)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: viper.GetBool("insecure-skip-verify")},
+// Connect returns a controller handle configured from opts. The returned
+// value holds a single connection pooled http.Client that is shared by all
+// of the resource accessors, e.g. Projects(), Nodes(), etc.
+func Connect(opts Options) (*Gns3, error) {
+	g := &Gns3{
+		baseURL:   strings.TrimSuffix(opts.BaseURL, "/"),
+		username:  opts.Username,
+		password:  opts.Password,
+		timeout:   opts.Timeout,
+		userAgent: opts.UserAgent,
+		client:    opts.HTTPClient,
+		retry:     opts.Retry,
+
+		baseDirectory:      opts.BaseDirectory,
+		downloadBufferSize: opts.DownloadBufferSize,
 	}
-	client := &http.Client{Transport: tr}
-	if err != nil {
-		return fmt.Errorf("req: %w", err)
+	if g.baseURL == "" {
+		g.baseURL = DefaultBaseURL
+	} else if !strings.Contains(g.baseURL, "://") {
+		g.baseURL = "http://" + g.baseURL
 	}
-	req.SetBasicAuth(viper.GetString("username"), viper.GetString("password"))
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("get: %w", err)
+	if g.timeout == 0 {
+		g.timeout = DefaultTimeout
 	}
-	defer resp.Body.Close()
-	if int(resp.StatusCode/100) != 2 {
-		var httpErr HttpError
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&httpErr)
-		if err != nil {
-			return fmt.Errorf("error decode: %w", err)
+	if g.userAgent == "" {
+		g.userAgent = DefaultUserAgent
+	}
+	if g.baseDirectory == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			g.baseDirectory = filepath.Join(home, "GNS3")
 		}
-		return &httpErr
 	}
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(data)
-	if err != nil {
-		return fmt.Errorf("decode: %w", err)
+	if g.downloadBufferSize <= 0 {
+		g.downloadBufferSize = DefaultDownloadBufferSize
 	}
-	return nil
-}
 
-func (g *Gns3) Delete(path string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
-	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete,
-		fmt.Sprintf("http://%s/%s", viper.GetString("address"), path), nil)
-	if err != nil {
-		return err
-	}
-	req.SetBasicAuth(viper.GetString("username"), viper.GetString("password"))
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: viper.GetBool("insecure-skip-verify")},
-	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("delete: %w", err)
-	}
-	defer resp.Body.Close()
-	if int(resp.StatusCode/100) != 2 {
-		var httpErr HttpError
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&httpErr)
-		if err != nil {
-			return fmt.Errorf("error decode: %w", err)
+	if g.client == nil {
+		tlsConfig := opts.TLSConfig
+		if tlsConfig == nil {
+			//nolint:gosec
+			tlsConfig = &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+			if opts.CAFile != "" {
+				pem, err := os.ReadFile(opts.CAFile)
+				if err != nil {
+					return nil, fmt.Errorf("read CA file: %w", err)
+				}
+				pool := x509.NewCertPool()
+				if !pool.AppendCertsFromPEM(pem) {
+					return nil, fmt.Errorf("%s: %w", opts.CAFile, ErrNoCertificates)
+				}
+				tlsConfig.RootCAs = pool
+			}
 		}
-		return &httpErr
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = tlsConfig
+		g.client = &http.Client{Transport: tr}
 	}
-	return nil
-}
 
-func (g *Gns3) Post(path string, contentType string, in interface{}, out interface{}) error {
-	var req *http.Request
-	var resp *http.Response
-	var err error
+	return g, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
-	defer cancel()
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: viper.GetBool("insecure-skip-verify")},
-	}
-	client := &http.Client{Transport: tr}
+// BaseURL returns the URL, including scheme, of the controller.
+func (g *Gns3) BaseURL() string {
+	return g.baseURL
+}
 
-	if in == nil {
-		req, err = http.NewRequestWithContext(ctx, http.MethodPost,
-			fmt.Sprintf("http://%s/%s", viper.GetString("address"), path), nil)
-		if err != nil {
-			return err
+// do sends a request to the controller. When in is not nil it is the
+// request body, sent as is with the content type if it is a []byte and
+// otherwise encoded as JSON, and when out is not nil the response body is
+// decoded into it, or read as is into a *[]byte.
+func (g *Gns3) do(ctx context.Context, method, path, contentType string, in interface{}, out interface{}) error {
+	var payload []byte
+	if data, ok := in.([]byte); ok {
+		payload = data
+		if payload == nil {
+			payload = []byte{}
 		}
-		req.SetBasicAuth(viper.GetString("username"), viper.GetString("password"))
-		resp, err = client.Do(req)
-	} else {
+	} else if in != nil {
+		contentType = "application/json"
 		buf := new(bytes.Buffer)
 		encoder := json.NewEncoder(buf)
-		err = encoder.Encode(in)
+		err := encoder.Encode(in)
 		if err != nil {
 			return fmt.Errorf("encoding: %w", err)
 		}
-		req, err = http.NewRequestWithContext(ctx, http.MethodPost,
-			fmt.Sprintf("http://%s/%s", viper.GetString("address"), path), buf)
-		if err != nil {
-			return err
-		}
-		req.Header = map[string][]string{
-			"content-type": {"application/json"},
-		}
-		req.SetBasicAuth(viper.GetString("username"), viper.GetString("password"))
-		resp, err = client.Do(req)
+		payload = buf.Bytes()
 	}
+
+	resp, cancel, err := g.send(ctx, method, path, contentType, payload)
 	if err != nil {
-		return fmt.Errorf("sent: %w", err)
+		return err
 	}
+	defer cancel()
 	defer resp.Body.Close()
 	if int(resp.StatusCode/100) != 2 {
-		var httpErr HttpError
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&httpErr)
+		body, _ := io.ReadAll(resp.Body)
+		return newHttpError(method, path, resp.StatusCode, body)
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("error decode: %w", err)
+			return fmt.Errorf("%s %s: read: %w", method, path, err)
 		}
-		return &httpErr
+		return nil
 	}
 	if out != nil {
 		decoder := json.NewDecoder(resp.Body)
 		err = decoder.Decode(out)
 		if err != nil {
-			return fmt.Errorf("decode: %w", err)
+			return fmt.Errorf("%s %s: decode: %w", method, path, err)
 		}
 	}
 	return nil
 }
 
-func (g *Gns3) Put(path string, contentType string, in interface{}, out interface{}) error {
-	var req *http.Request
-	var resp *http.Response
-	var err error
-
-	ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("timeout"))
-	defer cancel()
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: viper.GetBool("insecure-skip-verify")},
-	}
-	client := &http.Client{Transport: tr}
+// send performs the request, retrying according to the retry policy, and
+// returns the final response. The returned cancel function releases the
+// per attempt timeout and must be called once the body has been read.
+func (g *Gns3) send(ctx context.Context, method, path, contentType string, payload []byte) (*http.Response, context.CancelFunc, error) {
+	for attempt := 1; ; attempt++ {
+		actx, cancel := ctx, context.CancelFunc(func() {})
+		if g.timeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, g.timeout)
+		}
 
-	if in == nil {
-		req, err = http.NewRequestWithContext(ctx, http.MethodPut,
-			fmt.Sprintf("http://%s/%s", viper.GetString("address"), path), nil)
-		if err != nil {
-			return err
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
 		}
-		req.SetBasicAuth(viper.GetString("username"), viper.GetString("password"))
-		resp, err = client.Do(req)
-	} else {
-		buf := new(bytes.Buffer)
-		encoder := json.NewEncoder(buf)
-		err = encoder.Encode(in)
+		req, err := http.NewRequestWithContext(actx, method, fmt.Sprintf("%s/%s", g.baseURL, path), body)
 		if err != nil {
-			return fmt.Errorf("encoding: %w", err)
+			cancel()
+			return nil, nil, fmt.Errorf("req: %w", err)
 		}
-		req, err = http.NewRequestWithContext(ctx, http.MethodPut,
-			fmt.Sprintf("http://%s/%s", viper.GetString("address"), path), buf)
-		if err != nil {
-			return err
+		if payload != nil {
+			req.Header.Set("Content-Type", contentType)
 		}
-		req.Header = map[string][]string{
-			"Content-type": {"application/json"},
+		req.Header.Set("User-Agent", g.userAgent)
+		if g.username != "" {
+			req.SetBasicAuth(g.username, g.password)
 		}
-		req.SetBasicAuth(viper.GetString("username"), viper.GetString("password"))
-		resp, err = client.Do(req)
-	}
-	if err != nil {
-		return fmt.Errorf("sent: %w", err)
-	}
-	defer resp.Body.Close()
-	if int(resp.StatusCode/100) != 2 {
-		var httpErr HttpError
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&httpErr)
-		if err != nil {
-			return fmt.Errorf("error decode: %w", err)
+
+		resp, err := g.client.Do(req)
+		if err == nil && int(resp.StatusCode/100) == 2 {
+			return resp, cancel, nil
 		}
-		return &httpErr
-	}
-	if out != nil {
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(out)
-		if err != nil {
-			return fmt.Errorf("decode: %w", err)
+
+		var wait time.Duration
+		retry := false
+		if g.retry != nil {
+			wait, retry = g.retry.Retry(ctx, attempt, req, resp, err)
+		}
+		if !retry {
+			if err != nil {
+				cancel()
+				return nil, nil, &RequestError{Method: method, Path: path, Err: err}
+			}
+			return resp, cancel, nil
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, &RequestError{Method: method, Path: path, Err: ctx.Err()}
+		case <-timer.C:
 		}
 	}
-	return nil
+}
+
+func (g *Gns3) Get(path string, data interface{}) error {
+	return g.GetContext(context.Background(), path, data)
+}
+
+func (g *Gns3) GetContext(ctx context.Context, path string, data interface{}) error {
+	return g.do(ctx, http.MethodGet, path, "", nil, data)
+}
+
+func (g *Gns3) Delete(path string) error {
+	return g.DeleteContext(context.Background(), path)
+}
+
+func (g *Gns3) DeleteContext(ctx context.Context, path string) error {
+	return g.do(ctx, http.MethodDelete, path, "", nil, nil)
+}
+
+func (g *Gns3) Post(path string, contentType string, in interface{}, out interface{}) error {
+	return g.PostContext(context.Background(), path, contentType, in, out)
+}
+
+func (g *Gns3) PostContext(ctx context.Context, path string, contentType string, in interface{}, out interface{}) error {
+	return g.do(ctx, http.MethodPost, path, contentType, in, out)
+}
+
+func (g *Gns3) Put(path string, contentType string, in interface{}, out interface{}) error {
+	return g.PutContext(context.Background(), path, contentType, in, out)
+}
+
+func (g *Gns3) PutContext(ctx context.Context, path string, contentType string, in interface{}, out interface{}) error {
+	return g.do(ctx, http.MethodPut, path, contentType, in, out)
 }
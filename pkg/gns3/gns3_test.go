@@ -0,0 +1,368 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/ciena/gns3ctl/pkg/gns3/gns3test"
+)
+
+// connect returns a client for s that retries quickly.
+func connect(t *testing.T, s *gns3test.Server) *gns3.Gns3 {
+	t.Helper()
+	retry := gns3.DefaultRetryPolicy()
+	retry.InitialBackoff = time.Millisecond
+	retry.MaxBackoff = 5 * time.Millisecond
+	opts := s.Options()
+	opts.Retry = retry
+	ctl, err := gns3.Connect(opts)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return ctl
+}
+
+func TestConnectBaseURL(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"", gns3.DefaultBaseURL},
+		{"gns3:3080", "http://gns3:3080"},
+		{"https://gns3:3080/", "https://gns3:3080"},
+	} {
+		ctl, err := gns3.Connect(gns3.Options{BaseURL: tc.in})
+		if err != nil {
+			t.Fatalf("%q: %v", tc.in, err)
+		}
+		if got := ctl.BaseURL(); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestConnectMissingCAFile(t *testing.T) {
+	_, err := gns3.Connect(gns3.Options{CAFile: t.TempDir() + "/missing.pem"})
+	if err == nil {
+		t.Fatal("expected an error for a missing CA file")
+	}
+}
+
+func TestProjects(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	ctl := connect(t, s)
+
+	created, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for _, id := range []string{created.ProjectId, "lab"} {
+		got, err := ctl.Projects().Get(id)
+		if err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		if got.ProjectId != created.ProjectId {
+			t.Errorf("get %s: got ID %s, want %s", id, got.ProjectId, created.ProjectId)
+		}
+	}
+
+	if _, err := ctl.Projects().Get("missing"); !errors.Is(err, gns3.ErrNotFound) {
+		t.Errorf("get missing: got %v, want ErrNotFound", err)
+	}
+
+	if _, err := ctl.Projects().Create(&gns3.Project{Name: "lab"}); !errors.Is(err, gns3.ErrConflict) {
+		t.Errorf("create duplicate: got %v, want ErrConflict", err)
+	}
+
+	if _, err := ctl.Projects().Delete("lab"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if list, err := ctl.Projects().List(); err != nil || len(list) != 0 {
+		t.Errorf("list after delete: got %v, %v", list, err)
+	}
+}
+
+func TestNodesAndLinks(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	ctl := connect(t, s)
+
+	project, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+	if err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	nodes := ctl.Nodes(project.ProjectId)
+	var ids []string
+	for _, name := range []string{"pc1", "pc2"} {
+		node, err := nodes.Create(&gns3.Node{Name: name, NodeType: "vpcs", ComputeId: "local"})
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		ids = append(ids, node.NodeId)
+	}
+
+	link, err := ctl.Links(project.ProjectId).Create(&gns3.Link{
+		Nodes: []gns3.NodeRef{{NodeId: ids[0]}, {NodeId: ids[1]}},
+	})
+	if err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+	if got := s.Links(project.ProjectId); len(got) != 1 || got[0].LinkId != link.LinkId {
+		t.Errorf("links: got %+v", got)
+	}
+
+	if err := nodes.Start("pc1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	node, err := nodes.Get("pc1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if node.Status != "started" {
+		t.Errorf("status: got %q, want started", node.Status)
+	}
+
+	if err := nodes.WriteFile(node.NodeId, "startup.vpc", []byte("ip dhcp\n")); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	data, err := nodes.ReadFile(node.NodeId, "startup.vpc")
+	if err != nil || string(data) != "ip dhcp\n" {
+		t.Errorf("read file: got %q, %v", data, err)
+	}
+	if _, err := nodes.ReadFile(node.NodeId, "missing"); !errors.Is(err, gns3.ErrNotFound) {
+		t.Errorf("read missing file: got %v, want ErrNotFound", err)
+	}
+	// like the controller, the fake has no endpoint listing the files of a
+	// node
+	var list interface{}
+	err = ctl.Get("v2/projects/"+project.ProjectId+"/nodes/"+node.NodeId+"/files", &list)
+	if !errors.Is(err, gns3.ErrNotFound) {
+		t.Errorf("list files: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestNodeOperations(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	ctl := connect(t, s)
+
+	project, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+	if err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	nodes := ctl.Nodes(project.ProjectId)
+	pc1, err := nodes.Create(&gns3.Node{Name: "pc1", NodeType: "vpcs", ComputeId: "local"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	nodePath := fmt.Sprintf("v2/projects/%s/nodes/%s", project.ProjectId, pc1.NodeId)
+	lastRequest := func() string {
+		requests := s.Requests()
+		return requests[len(requests)-1]
+	}
+	status := func(id string) string {
+		for _, n := range s.Nodes(project.ProjectId) {
+			if n.NodeId == id {
+				return n.Status
+			}
+		}
+		return "deleted"
+	}
+
+	if err := nodes.Start("pc1"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := nodes.Suspend("pc1"); err != nil {
+		t.Errorf("suspend: %v", err)
+	}
+	if got := lastRequest(); got != "POST "+nodePath+"/suspend" || status(pc1.NodeId) != "suspended" {
+		t.Errorf("suspend: got %s, status %s", got, status(pc1.NodeId))
+	}
+	if err := nodes.Reload("pc1"); err != nil {
+		t.Errorf("reload: %v", err)
+	}
+	if got := lastRequest(); got != "POST "+nodePath+"/reload" || status(pc1.NodeId) != "started" {
+		t.Errorf("reload: got %s, status %s", got, status(pc1.NodeId))
+	}
+
+	dup, err := nodes.Duplicate("pc1", 100, 50, 1)
+	if err != nil {
+		t.Fatalf("duplicate: %v", err)
+	}
+	if got := lastRequest(); got != "POST "+nodePath+"/duplicate" {
+		t.Errorf("duplicate: got %s", got)
+	}
+	if dup.Name != "pc2" || dup.NodeId == pc1.NodeId || dup.X != 100 || dup.Y != 50 || dup.Z != 1 ||
+		dup.NodeType != "vpcs" || status(dup.NodeId) != "stopped" {
+		t.Errorf("duplicate: got %+v", dup)
+	}
+
+	id, err := nodes.Delete("pc2")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := lastRequest(); got != fmt.Sprintf("DELETE v2/projects/%s/nodes/%s", project.ProjectId, dup.NodeId) ||
+		id != dup.NodeId || status(dup.NodeId) != "deleted" {
+		t.Errorf("delete: got %s, id %s, status %s", got, id, status(dup.NodeId))
+	}
+	if _, err := nodes.Delete("pc2"); !errors.Is(err, gns3.ErrNotFound) {
+		t.Errorf("delete missing: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		method   string
+		status   int
+		failures int
+		do       func(ctl *gns3.Gns3) error
+		wantErr  error
+		attempts int
+	}{
+		{
+			name: "get retried on unavailable", method: http.MethodGet, status: http.StatusServiceUnavailable,
+			failures: 2, attempts: 3,
+			do: func(ctl *gns3.Gns3) error { _, err := ctl.Projects().List(); return err },
+		},
+		{
+			name: "get gives up after max attempts", method: http.MethodGet, status: http.StatusBadGateway,
+			failures: 5, attempts: 3, wantErr: gns3.ErrServerUnavailable,
+			do: func(ctl *gns3.Gns3) error { _, err := ctl.Projects().List(); return err },
+		},
+		{
+			name: "get not retried on not found", method: http.MethodGet, status: http.StatusNotFound,
+			failures: 1, attempts: 1, wantErr: gns3.ErrNotFound,
+			do: func(ctl *gns3.Gns3) error { _, err := ctl.Projects().List(); return err },
+		},
+		{
+			name: "create retried on conflict", method: http.MethodPost, status: http.StatusConflict,
+			failures: 1, attempts: 2,
+			do: func(ctl *gns3.Gns3) error {
+				_, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+				return err
+			},
+		},
+		{
+			name: "post not retried on server error", method: http.MethodPost, status: http.StatusInternalServerError,
+			failures: 1, attempts: 1,
+			do: func(ctl *gns3.Gns3) error {
+				_, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+				return err
+			},
+			wantErr: &gns3.HttpError{Status: http.StatusInternalServerError},
+		},
+		{
+			name: "post retried when not processed", method: http.MethodPost, status: http.StatusTooManyRequests,
+			failures: 1, attempts: 2,
+			do: func(ctl *gns3.Gns3) error {
+				_, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+				return err
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := gns3test.NewServer()
+			defer s.Close()
+			s.Fail(tc.method, gns3.ProjectsPath, tc.status, tc.failures)
+
+			err := tc.do(connect(t, s))
+			var httpErr *gns3.HttpError
+			switch want := tc.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			case *gns3.HttpError:
+				if !errors.As(err, &httpErr) || httpErr.Status != want.Status {
+					t.Fatalf("got %v, want status %d", err, want.Status)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("got %v, want %v", err, want)
+				}
+			}
+
+			attempts := 0
+			for _, r := range s.Requests() {
+				if r == tc.method+" "+gns3.ProjectsPath {
+					attempts++
+				}
+			}
+			if attempts != tc.attempts {
+				t.Errorf("got %d attempts, want %d: %v", attempts, tc.attempts, s.Requests())
+			}
+		})
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	s := gns3test.NewServer()
+	url := s.URL
+	s.Close()
+
+	opts := gns3.Options{BaseURL: url, Retry: &gns3.BackoffPolicy{MaxAttempts: 1}}
+	ctl, err := gns3.Connect(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ctl.Projects().List(); !errors.Is(err, gns3.ErrServerUnavailable) {
+		t.Errorf("closed server: got %v, want ErrServerUnavailable", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ctl.Projects().ListContext(ctx)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, gns3.ErrServerUnavailable) {
+		t.Errorf("canceled: got %v, want only context.Canceled", err)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	opts := s.Options()
+	opts.Password = "wrong"
+	ctl, err := gns3.Connect(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ctl.Projects().List(); !errors.Is(err, gns3.ErrUnauthorized) {
+		t.Errorf("got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	v, err := connect(t, s).Server().Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&gns3.ServerVersion{Local: true, Version: gns3test.Version}); !reflect.DeepEqual(v, want) {
+		t.Errorf("got %+v, want %+v", v, want)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3test
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/google/uuid"
+)
+
+// portInUse returns the ID of the link using the port, if any.
+func (s *Server) portInUse(projectID string, ref gns3.NodeRef) string {
+	for _, l := range s.links[projectID] {
+		for _, end := range l.Nodes {
+			if end.NodeId == ref.NodeId && end.AdapterNumber == ref.AdapterNumber && end.PortNumber == ref.PortNumber {
+				return l.LinkId
+			}
+		}
+	}
+	return ""
+}
+
+func hasPort(node *gns3.Node, adapter, port int) bool {
+	for _, p := range node.Ports {
+		if p.AdapterNumber == adapter && p.PortNumber == port {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request, p *gns3.Project, parts []string) {
+	links := s.links[p.ProjectId]
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			list := make([]*gns3.Link, 0, len(links))
+			for _, l := range links {
+				list = append(list, l)
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].LinkId < list[j].LinkId })
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			var l gns3.Link
+			if !decode(w, r, &l) {
+				return
+			}
+			if len(l.Nodes) != 2 {
+				writeError(w, http.StatusBadRequest, "JSON schema error with API request '/v2/projects/%s/links': a link requires two nodes", p.ProjectId)
+				return
+			}
+			for _, end := range l.Nodes {
+				node, ok := s.nodes[p.ProjectId][end.NodeId]
+				if !ok {
+					writeError(w, http.StatusNotFound, "Node ID %s doesn't exist", end.NodeId)
+					return
+				}
+				if !hasPort(node, end.AdapterNumber, end.PortNumber) {
+					writeError(w, http.StatusConflict, "Port %d/%d is not available on node %s", end.AdapterNumber, end.PortNumber, node.Name)
+					return
+				}
+				if id := s.portInUse(p.ProjectId, end); id != "" {
+					writeError(w, http.StatusConflict, "Port %d/%d is already used by link %s", end.AdapterNumber, end.PortNumber, id)
+					return
+				}
+			}
+			l.LinkId = uuid.NewString()
+			l.ProjectId = p.ProjectId
+			if l.LinkType == "" {
+				l.LinkType = "ethernet"
+			}
+			links[l.LinkId] = &l
+			s.Notify(p.ProjectId, gns3.ActionLinkCreated, &l)
+			writeJSON(w, http.StatusCreated, &l)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	l, ok := links[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Link ID %s doesn't exist", parts[0])
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, l)
+	case http.MethodPut:
+		var patch map[string]interface{}
+		if !decode(w, r, &patch) {
+			return
+		}
+		if v, ok := patch["suspend"].(bool); ok {
+			l.Suspend = v
+		}
+		if v, ok := patch["filters"].(map[string]interface{}); ok {
+			l.Filters = v
+		}
+		s.Notify(p.ProjectId, gns3.ActionLinkUpdated, l)
+		writeJSON(w, http.StatusCreated, l)
+	case http.MethodDelete:
+		delete(links, l.LinkId)
+		s.Notify(p.ProjectId, gns3.ActionLinkDeleted, l)
+		writeJSON(w, http.StatusNoContent, nil)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
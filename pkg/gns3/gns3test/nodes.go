@@ -0,0 +1,323 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/google/uuid"
+)
+
+const (
+	StatusStarted   = "started"
+	StatusStopped   = "stopped"
+	StatusSuspended = "suspended"
+)
+
+// switchPorts is the number of ports created on switch and hub nodes.
+const switchPorts = 8
+
+// makePorts returns the ports GNS3 would create for a node of the given type.
+func makePorts(node *gns3.Node) []*gns3.Port {
+	var ports []*gns3.Port
+	switch node.NodeType {
+	case "ethernet_switch", "ethernet_hub":
+		for i := 0; i < switchPorts; i++ {
+			name := fmt.Sprintf("Ethernet%d", i)
+			ports = append(ports, &gns3.Port{AdapterNumber: 0, PortNumber: i, Name: name, ShortName: fmt.Sprintf("e%d", i), LinkType: "ethernet"})
+		}
+	case gns3.TypeNat, "cloud":
+		ports = append(ports, &gns3.Port{AdapterNumber: 0, PortNumber: 0, Name: "nat0", ShortName: "nat0", LinkType: "ethernet"})
+	default:
+		adapters := 1
+		if v, ok := node.Properties["adapters"].(float64); ok && v > 0 {
+			adapters = int(v)
+		}
+		format := node.PortNameFormat
+		if format == "" {
+			if f, ok := node.Properties["port_name_format"].(string); ok && f != "" {
+				format = f
+			} else if node.NodeType == gns3.TemplateTypeDocker {
+				format = "eth{0}"
+			} else {
+				format = "Ethernet{0}"
+			}
+		}
+		for i := 0; i < adapters; i++ {
+			name := strings.NewReplacer("{0}", fmt.Sprint(i), "{port0}", fmt.Sprint(i)).Replace(format)
+			ports = append(ports, &gns3.Port{AdapterNumber: i, PortNumber: 0, Name: name, ShortName: name, LinkType: "ethernet"})
+		}
+	}
+	return ports
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request, p *gns3.Project, parts []string) {
+	nodes := s.nodes[p.ProjectId]
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			list := make([]*gns3.Node, 0, len(nodes))
+			for _, n := range nodes {
+				list = append(list, n)
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			var n gns3.Node
+			if !decode(w, r, &n) {
+				return
+			}
+			if n.Name == "" || n.NodeType == "" || n.ComputeId == "" {
+				writeError(w, http.StatusBadRequest, "JSON schema error with API request '/v2/projects/%s/nodes': 'name', 'node_type' and 'compute_id' are required properties", p.ProjectId)
+				return
+			}
+			if _, ok := s.computes[n.ComputeId]; !ok {
+				writeError(w, http.StatusNotFound, "Compute ID %s doesn't exist", n.ComputeId)
+				return
+			}
+			for _, existing := range nodes {
+				if existing.Name == n.Name {
+					writeError(w, http.StatusConflict, "Node '%s' already exists", n.Name)
+					return
+				}
+			}
+			n.NodeId = uuid.NewString()
+			n.ProjectId = p.ProjectId
+			n.Status = StatusStopped
+			n.Console = s.nextConsole
+			n.ConsoleHost = "127.0.0.1"
+			if n.ConsoleType == "" {
+				n.ConsoleType = "telnet"
+			}
+			n.NodeDirectory = fmt.Sprintf("/projects/%s/project-files/%s/%s", p.ProjectId, n.NodeType, n.NodeId)
+			s.nextConsole++
+			n.Ports = makePorts(&n)
+			nodes[n.NodeId] = &n
+			s.Notify(p.ProjectId, gns3.ActionNodeCreated, &n)
+			writeJSON(w, http.StatusCreated, &n)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	if len(parts) == 1 && r.Method == http.MethodPost {
+		// bulk actions on every node of the project
+		status := map[string]string{"start": StatusStarted, "reload": StatusStarted, "stop": StatusStopped, "suspend": StatusSuspended}
+		if to, ok := status[parts[0]]; ok {
+			for _, n := range nodes {
+				n.Status = to
+				s.Notify(p.ProjectId, gns3.ActionNodeUpdated, n)
+			}
+			writeJSON(w, http.StatusNoContent, nil)
+			return
+		}
+	}
+
+	n, ok := nodes[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Node ID %s doesn't exist", parts[0])
+		return
+	}
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, n)
+		case http.MethodPut:
+			var patch map[string]interface{}
+			if !decode(w, r, &patch) {
+				return
+			}
+			if name, ok := patch["name"].(string); ok && name != n.Name {
+				for _, existing := range nodes {
+					if existing.Name == name {
+						writeError(w, http.StatusConflict, "Node '%s' already exists", name)
+						return
+					}
+				}
+			}
+			if err := mergeNode(n, patch); err != nil {
+				writeError(w, http.StatusBadRequest, "JSON schema error with API request '/v2/projects/%s/nodes/%s': %v", p.ProjectId, n.NodeId, err)
+				return
+			}
+			s.Notify(p.ProjectId, gns3.ActionNodeUpdated, n)
+			writeJSON(w, http.StatusOK, n)
+		case http.MethodDelete:
+			for id, l := range s.links[p.ProjectId] {
+				for _, end := range l.Nodes {
+					if end.NodeId == n.NodeId {
+						delete(s.links[p.ProjectId], id)
+						s.Notify(p.ProjectId, gns3.ActionLinkDeleted, l)
+					}
+				}
+			}
+			delete(nodes, n.NodeId)
+			delete(s.files, n.NodeId)
+			s.Notify(p.ProjectId, gns3.ActionNodeDeleted, n)
+			writeJSON(w, http.StatusNoContent, nil)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	if parts[1] == "files" {
+		s.handleFiles(w, r, n.NodeId, strings.Join(parts[2:], "/"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	switch parts[1] {
+	case "start", "reload":
+		n.Status = StatusStarted
+	case "stop":
+		n.Status = StatusStopped
+	case "suspend":
+		n.Status = StatusSuspended
+	case "isolate", "unisolate":
+		for _, l := range s.links[p.ProjectId] {
+			for _, end := range l.Nodes {
+				if end.NodeId == n.NodeId {
+					l.Suspend = parts[1] == "isolate"
+					s.Notify(p.ProjectId, gns3.ActionLinkUpdated, l)
+				}
+			}
+		}
+	case "duplicate":
+		var position struct {
+			X, Y, Z int
+		}
+		if !decode(w, r, &position) {
+			return
+		}
+		dup := *n
+		dup.NodeId = uuid.NewString()
+		dup.Name = nextName(nodes, n.Name)
+		dup.Status = StatusStopped
+		dup.X, dup.Y, dup.Z = position.X, position.Y, position.Z
+		dup.Console = s.nextConsole
+		dup.NodeDirectory = strings.Replace(n.NodeDirectory, n.NodeId, dup.NodeId, 1)
+		s.nextConsole++
+		dup.Ports = makePorts(&dup)
+		nodes[dup.NodeId] = &dup
+		s.Notify(p.ProjectId, gns3.ActionNodeCreated, &dup)
+		writeJSON(w, http.StatusCreated, &dup)
+		return
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+		return
+	}
+	s.Notify(p.ProjectId, gns3.ActionNodeUpdated, n)
+	writeJSON(w, http.StatusOK, n)
+}
+
+// nextName returns the first free name made by numbering the name without
+// its trailing digits, as the controller names duplicated nodes.
+func nextName(nodes map[string]*gns3.Node, name string) string {
+	base := strings.TrimRight(name, "0123456789")
+	taken := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		taken[n.Name] = true
+	}
+	for i := 1; ; i++ {
+		if candidate := fmt.Sprintf("%s%d", base, i); !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// mergeNode applies the fields present in patch to the node, as the
+// controller does for a PUT. Read only fields are ignored.
+func mergeNode(n *gns3.Node, patch map[string]interface{}) error {
+	for _, key := range []string{"node_id", "project_id", "node_type", "compute_id", "ports", "status", "console_host", "node_directory"} {
+		delete(patch, key)
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	var current map[string]interface{}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return err
+	}
+	for k, v := range patch {
+		if k == "properties" {
+			props, _ := current[k].(map[string]interface{})
+			if props == nil {
+				props = map[string]interface{}{}
+			}
+			if update, ok := v.(map[string]interface{}); ok {
+				for pk, pv := range update {
+					props[pk] = pv
+				}
+			}
+			v = props
+		}
+		current[k] = v
+	}
+	data, err = json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	var merged gns3.Node
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return err
+	}
+	*n = merged
+	return nil
+}
+
+// handleFiles stores the files written to the directory of a node or
+// project, given by its ID, and reads them back. Like the controller, it
+// has no endpoint listing them.
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request, id, name string) {
+	if name == "" {
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := s.files[id][name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "File %s doesn't exist", name)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(data)
+	case http.MethodPost:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "read file: %v", err)
+			return
+		}
+		if s.files[id] == nil {
+			s.files[id] = map[string][]byte{}
+		}
+		s.files[id][name] = data
+		writeJSON(w, http.StatusCreated, nil)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+// subscriber is a client of a notification websocket. ProjectID is empty
+// for the controller's stream.
+type subscriber struct {
+	projectID string
+	ch        chan []byte
+	done      chan struct{}
+}
+
+// notifications holds the clients of the notification websockets. It has
+// its own lock as the streams outlive the requests that opened them.
+type notifications struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// notificationProject returns the project of a notification websocket path,
+// empty for the controller's, and false if path is not one.
+func notificationProject(path string) (string, bool) {
+	switch parts := strings.Split(path, "/"); {
+	case path == gns3.NotificationsPath:
+		return "", true
+	case len(parts) == 5 && parts[1] == "projects" && parts[3] == "notifications" && parts[4] == "ws":
+		return parts[2], true
+	}
+	return "", false
+}
+
+// Notify pushes a notification to the clients of the stream of a project,
+// or of the controller if projectID is empty. Clients that are not keeping
+// up miss it.
+func (s *Server) Notify(projectID, action string, event interface{}) {
+	message, _ := json.Marshal(map[string]interface{}{"action": action, "event": event})
+	s.notifications.mu.Lock()
+	defer s.notifications.mu.Unlock()
+	for sub := range s.notifications.subscribers {
+		if sub.projectID == projectID {
+			select {
+			case sub.ch <- message:
+			default:
+			}
+		}
+	}
+}
+
+// CloseNotifications ends every notification stream, as if the controller
+// had gone away.
+func (s *Server) CloseNotifications() {
+	s.notifications.mu.Lock()
+	defer s.notifications.mu.Unlock()
+	for sub := range s.notifications.subscribers {
+		close(sub.done)
+		delete(s.notifications.subscribers, sub)
+	}
+}
+
+// Subscribers returns the number of clients of the notification streams.
+func (s *Server) Subscribers() int {
+	s.notifications.mu.Lock()
+	defer s.notifications.mu.Unlock()
+	return len(s.notifications.subscribers)
+}
+
+func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request, projectID string) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+strings.Trim(r.URL.Path, "/"))
+	_, known := s.projects[projectID]
+	s.mu.Unlock()
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case r.Method != http.MethodGet:
+		methodNotAllowed(w, r)
+		return
+	case projectID != "" && !known:
+		writeError(w, http.StatusNotFound, "Project ID %s doesn't exist", projectID)
+		return
+	case !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "":
+		writeError(w, http.StatusBadRequest, "expected a websocket handshake")
+		return
+	}
+	if user, pass, ok := r.BasicAuth(); ok && (user != DefaultUser || pass != DefaultPassword) {
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + gns3.WebsocketAccept(key) + "\r\n\r\n")
+	if rw.Flush() != nil {
+		return
+	}
+
+	sub := &subscriber{projectID: projectID, ch: make(chan []byte, 64), done: make(chan struct{})}
+	s.notifications.mu.Lock()
+	s.notifications.subscribers[sub] = struct{}{}
+	s.notifications.mu.Unlock()
+	defer func() {
+		s.notifications.mu.Lock()
+		delete(s.notifications.subscribers, sub)
+		s.notifications.mu.Unlock()
+	}()
+
+	// the stream ends when the client sends anything, which is its close
+	// frame, or goes away
+	gone := make(chan struct{})
+	go func() {
+		_, _ = rw.ReadByte()
+		close(gone)
+	}()
+	for {
+		select {
+		case message := <-sub.ch:
+			// servers send unmasked text frames
+			frame := []byte{0x81}
+			switch length := len(message); {
+			case length < 126:
+				frame = append(frame, byte(length))
+			case length <= 0xffff:
+				frame = append(frame, 126, 0, 0)
+				binary.BigEndian.PutUint16(frame[2:], uint16(length))
+			default:
+				frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+				binary.BigEndian.PutUint64(frame[2:], uint64(length))
+			}
+			if _, err := conn.Write(append(frame, message...)); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-sub.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,527 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gns3test provides an in-process fake GNS3 controller that
+// implements the v2 endpoints used by the gns3 package, with in-memory
+// state, so that the library and the commands built on it can be exercised
+// without a gns3server.
+package gns3test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/google/uuid"
+)
+
+const (
+	Version         = "2.2.35"
+	FirstConsole    = 5000
+	DefaultUser     = "admin"
+	DefaultPassword = "admin"
+)
+
+// Server is a fake GNS3 controller. The embedded httptest.Server is started
+// by NewServer and must be closed by the caller.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	projects    map[string]*gns3.Project
+	nodes       map[string]map[string]*gns3.Node
+	links       map[string]map[string]*gns3.Link
+	templates   map[string]*gns3.Template
+	computes    map[string]*gns3.Compute
+	appliances  map[string]*gns3.Appliance
+	files       map[string]map[string][]byte
+	images      map[string][]byte
+	nextConsole int
+	requests    []string
+	failures    []*failure
+	shutdown    bool
+
+	notifications notifications
+}
+
+type failure struct {
+	method string
+	path   string
+	status int
+	count  int
+}
+
+// NewServer starts a fake controller with a single "local" compute and no
+// projects, templates or appliances.
+func NewServer() *Server {
+	s := &Server{
+		projects:    map[string]*gns3.Project{},
+		nodes:       map[string]map[string]*gns3.Node{},
+		links:       map[string]map[string]*gns3.Link{},
+		templates:   map[string]*gns3.Template{},
+		computes:    map[string]*gns3.Compute{},
+		appliances:  map[string]*gns3.Appliance{},
+		files:       map[string]map[string][]byte{},
+		images:      map[string][]byte{},
+		nextConsole: FirstConsole,
+	}
+	s.notifications.subscribers = map[*subscriber]struct{}{}
+	s.computes["local"] = &gns3.Compute{
+		ComputeId: "local",
+		Connected: true,
+		Host:      "127.0.0.1",
+		Name:      "local",
+		Port:      3080,
+		Protocol:  "http",
+		User:      DefaultUser,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Options returns the options to connect to the fake controller.
+func (s *Server) Options() gns3.Options {
+	return gns3.Options{
+		BaseURL:  s.URL,
+		Username: DefaultUser,
+		Password: DefaultPassword,
+	}
+}
+
+// Connect returns a client connected to the fake controller.
+func (s *Server) Connect() *gns3.Gns3 {
+	ctl, err := gns3.Connect(s.Options())
+	if err != nil {
+		panic(err)
+	}
+	return ctl
+}
+
+// AddAppliance makes an appliance available from the appliances endpoints.
+func (s *Server) AddAppliance(id string, appliance gns3.Appliance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appliances[id] = &appliance
+}
+
+// AddTemplate creates a template as if it was posted to the controller and
+// returns it with its assigned ID.
+func (s *Server) AddTemplate(template gns3.Template) *gns3.Template {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if template.TemplateId == "" {
+		template.TemplateId = uuid.NewString()
+	}
+	s.templates[template.TemplateId] = &template
+	return &template
+}
+
+// Fail makes the next count requests matching method and path, relative to
+// the base URL, e.g. "v2/projects", fail with status.
+func (s *Server) Fail(method, path string, status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{method: method, path: path, status: status, count: count})
+}
+
+// Requests returns the "METHOD path" of every request received, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// IsShutdown reports if the shutdown endpoint was called.
+func (s *Server) IsShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+// Projects returns a copy of the projects, ordered by name.
+func (s *Server) Projects() []gns3.Project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]gns3.Project, 0, len(s.projects))
+	for _, p := range s.projects {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Nodes returns a copy of the nodes of a project, ordered by name.
+func (s *Server) Nodes(projectID string) []gns3.Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]gns3.Node, 0, len(s.nodes[projectID]))
+	for _, n := range s.nodes[projectID] {
+		list = append(list, *n)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// File returns the contents of a file written to the directory of a node
+// or project, given by its ID, and false if it was not written.
+func (s *Server) File(id, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[id][name]
+	return data, ok
+}
+
+// Image returns an image uploaded for an emulator of a compute, and false
+// if it was not uploaded.
+func (s *Server) Image(computeID, emulator, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.images[path.Join(computeID, emulator, name)]
+	return data, ok
+}
+
+// Links returns a copy of the links of a project, ordered by ID.
+func (s *Server) Links(projectID string) []gns3.Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]gns3.Link, 0, len(s.links[projectID]))
+	for _, l := range s.links[projectID] {
+		list = append(list, *l)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LinkId < list[j].LinkId })
+	return list
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	// notification streams are long lived so must not hold the lock
+	if projectID, ok := notificationProject(path); ok {
+		s.handleNotifications(w, r, projectID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+path)
+
+	for i, f := range s.failures {
+		if f.method == r.Method && f.path == path {
+			f.count--
+			if f.count <= 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+			writeError(w, f.status, "injected failure")
+			return
+		}
+	}
+
+	if user, pass, ok := r.BasicAuth(); ok && (user != DefaultUser || pass != DefaultPassword) {
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || parts[0] != "v2" {
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+		return
+	}
+
+	switch parts[1] {
+	case "version":
+		s.handleVersion(w, r)
+	case "shutdown":
+		s.handleShutdown(w, r)
+	case "projects":
+		s.handleProjects(w, r, parts[2:])
+	case "templates":
+		s.handleTemplates(w, r, parts[2:])
+	case "computes":
+		s.handleComputes(w, r, parts[2:])
+	case "appliances":
+		s.handleAppliances(w, r, parts[2:])
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if data != nil {
+		_ = json.NewEncoder(w).Encode(data)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, &gns3.HttpError{Message: fmt.Sprintf(format, args...), Status: status})
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "JSON schema error with API request '/%s': %v",
+			strings.Trim(r.URL.Path, "/"), err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, &gns3.ServerVersion{Local: true, Version: Version})
+}
+
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	s.shutdown = true
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) handleComputes(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			list := make([]*gns3.Compute, 0, len(s.computes))
+			for _, c := range s.computes {
+				list = append(list, c)
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].ComputeId < list[j].ComputeId })
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			var c gns3.Compute
+			if !decode(w, r, &c) {
+				return
+			}
+			if c.ComputeId == "" {
+				c.ComputeId = uuid.NewString()
+			}
+			if _, ok := s.computes[c.ComputeId]; ok {
+				writeError(w, http.StatusConflict, "compute '%s' already exists", c.ComputeId)
+				return
+			}
+			s.computes[c.ComputeId] = &c
+			writeJSON(w, http.StatusCreated, &c)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	c, ok := s.computes[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "compute ID %s doesn't exist", parts[0])
+		return
+	}
+	if len(parts) >= 4 && parts[2] == "images" {
+		s.handleImages(w, r, c, parts[1], strings.Join(parts[3:], "/"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, c)
+	case http.MethodDelete:
+		delete(s.computes, c.ComputeId)
+		writeJSON(w, http.StatusNoContent, nil)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+// handleImages stores the images uploaded for an emulator of a compute.
+func (s *Server) handleImages(w http.ResponseWriter, r *http.Request, c *gns3.Compute, emulator, name string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "read image: %v", err)
+		return
+	}
+	s.images[path.Join(c.ComputeId, emulator, name)] = data
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) handleAppliances(w http.ResponseWriter, r *http.Request, parts []string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	if len(parts) == 0 {
+		ids := make([]string, 0, len(s.appliances))
+		for id := range s.appliances {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		list := make([]*gns3.Appliance, 0, len(ids))
+		for _, id := range ids {
+			list = append(list, s.appliances[id])
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+	a, ok := s.appliances[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "appliance ID %s doesn't exist", parts[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			list := make([]*gns3.Template, 0, len(s.templates))
+			for _, t := range s.templates {
+				list = append(list, t)
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			var t gns3.Template
+			if !decode(w, r, &t) {
+				return
+			}
+			if t.Name == "" || t.TemplateType == "" {
+				writeError(w, http.StatusBadRequest, "JSON schema error with API request '/v2/templates': 'name' and 'template_type' are required properties")
+				return
+			}
+			t.TemplateId = uuid.NewString()
+			s.templates[t.TemplateId] = &t
+			writeJSON(w, http.StatusCreated, &t)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	t, ok := s.templates[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "template ID %s doesn't exist", parts[0])
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, t)
+	case http.MethodDelete:
+		delete(s.templates, t.TemplateId)
+		writeJSON(w, http.StatusNoContent, nil)
+	default:
+		methodNotAllowed(w, r)
+	}
+}
+
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			list := make([]*gns3.Project, 0, len(s.projects))
+			for _, p := range s.projects {
+				list = append(list, p)
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			var p gns3.Project
+			if !decode(w, r, &p) {
+				return
+			}
+			if p.Name == "" {
+				writeError(w, http.StatusBadRequest, "JSON schema error with API request '/v2/projects': 'name' is a required property")
+				return
+			}
+			for _, existing := range s.projects {
+				if existing.Name == p.Name {
+					writeError(w, http.StatusConflict, "Project '%s' already exists", p.Name)
+					return
+				}
+			}
+			if p.ProjectId == "" {
+				p.ProjectId = uuid.NewString()
+			}
+			p.Status = "opened"
+			p.Filename = p.Name + ".gns3"
+			p.Path = "/projects/" + p.ProjectId
+			s.projects[p.ProjectId] = &p
+			s.nodes[p.ProjectId] = map[string]*gns3.Node{}
+			s.links[p.ProjectId] = map[string]*gns3.Link{}
+			writeJSON(w, http.StatusCreated, &p)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	p, ok := s.projects[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Project ID %s doesn't exist", parts[0])
+		return
+	}
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, p)
+		case http.MethodDelete:
+			delete(s.projects, p.ProjectId)
+			delete(s.nodes, p.ProjectId)
+			delete(s.links, p.ProjectId)
+			writeJSON(w, http.StatusNoContent, nil)
+		default:
+			methodNotAllowed(w, r)
+		}
+		return
+	}
+
+	switch parts[1] {
+	case "open", "close":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r)
+			return
+		}
+		if parts[1] == "open" {
+			p.Status = "opened"
+		} else {
+			p.Status = "closed"
+		}
+		writeJSON(w, http.StatusCreated, p)
+	case "nodes":
+		s.handleNodes(w, r, p, parts[2:])
+	case "links":
+		s.handleLinks(w, r, p, parts[2:])
+	case "files":
+		s.handleFiles(w, r, p.ProjectId, strings.Join(parts[2:], "/"))
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+	}
+}
@@ -16,13 +16,122 @@ limitations under the License.
 
 package gns3
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
+const maxErrorBody = 512
+
+// HttpError is returned when the server responds with a non 2xx status. It
+// unwraps to one of the error categories, e.g. ErrNotFound, based on the
+// status.
 type HttpError struct {
+	Method  string `json:"-"`
+	Path    string `json:"-"`
 	Message string `json:"message"`
 	Status  int    `json:"status"`
+
+	// Detail holds the reason the server rejected the request body, such
+	// as the JSON schema violation, for ErrValidation errors.
+	Detail string `json:"-"`
 }
 
 func (h *HttpError) Error() string {
-	return fmt.Sprintf("%s (%d)", h.Message, h.Status)
+	if h.Method == "" {
+		return fmt.Sprintf("%s (%d)", h.Message, h.Status)
+	}
+	return fmt.Sprintf("%s %s: %s (%d)", h.Method, h.Path, h.Message, h.Status)
+}
+
+func (h *HttpError) Unwrap() error {
+	switch h.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrValidation
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrServerUnavailable
+	}
+	return nil
+}
+
+// newHttpError builds the error for a non 2xx response from its body, which
+// is usually, but not always, a JSON document.
+func newHttpError(method, path string, status int, body []byte) *HttpError {
+	httpErr := &HttpError{Method: method, Path: path, Status: status}
+
+	var doc struct {
+		Message string          `json:"message"`
+		Detail  json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &doc); err == nil {
+		httpErr.Message = doc.Message
+		if len(doc.Detail) > 0 {
+			var s string
+			if json.Unmarshal(doc.Detail, &s) == nil {
+				httpErr.Detail = s
+			} else {
+				httpErr.Detail = string(doc.Detail)
+			}
+		}
+	} else {
+		text := strings.TrimSpace(string(body))
+		if len(text) > maxErrorBody {
+			text = text[:maxErrorBody] + "..."
+		}
+		httpErr.Message = text
+	}
+
+	// The v2 API reports schema violations in the message, following the
+	// request data, e.g. "JSON schema error with API request '...' and
+	// JSON data '...': 'x' is not of type 'integer'".
+	if httpErr.Detail == "" && errors.Is(httpErr, ErrValidation) &&
+		strings.HasPrefix(httpErr.Message, "JSON schema error") {
+		first := httpErr.Message
+		if idx := strings.Index(first, "\n"); idx >= 0 {
+			first = first[:idx]
+		}
+		if idx := strings.LastIndex(first, "': "); idx >= 0 {
+			httpErr.Detail = strings.TrimSpace(httpErr.Message[idx+3:])
+		}
+	}
+
+	if httpErr.Message == "" {
+		httpErr.Message = http.StatusText(status)
+	}
+	if httpErr.Detail == "" && errors.Is(httpErr, ErrValidation) {
+		httpErr.Detail = httpErr.Message
+	}
+	return httpErr
+}
+
+// RequestError is returned when a request could not be completed, for
+// example because the server could not be reached. Unless the request was
+// canceled or ran out of time it matches ErrServerUnavailable.
+type RequestError struct {
+	Method string
+	Path   string
+	Err    error
+}
+
+func (r *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %v", r.Method, r.Path, r.Err)
+}
+
+func (r *RequestError) Unwrap() error {
+	return r.Err
+}
+
+func (r *RequestError) Is(target error) bool {
+	return target == ErrServerUnavailable && !errors.Is(r.Err, context.Canceled) &&
+		!errors.Is(r.Err, context.DeadlineExceeded)
 }
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewHttpError(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		status      int
+		body        string
+		wantIs      error
+		wantMessage string
+		wantDetail  string
+	}{
+		{
+			name: "json message", status: http.StatusNotFound,
+			body:   `{"message": "Project ID x doesn't exist", "status": 404}`,
+			wantIs: ErrNotFound, wantMessage: "Project ID x doesn't exist",
+		},
+		{
+			name: "schema error", status: http.StatusBadRequest,
+			body:        `{"message": "JSON schema error with API request '/v2/projects' and JSON data '{}': 'name' is a required property", "status": 400}`,
+			wantIs:      ErrValidation,
+			wantMessage: "JSON schema error with API request '/v2/projects' and JSON data '{}': 'name' is a required property",
+			wantDetail:  "'name' is a required property",
+		},
+		{
+			name: "detail string", status: http.StatusUnprocessableEntity,
+			body:   `{"message": "bad", "detail": "x is not an integer"}`,
+			wantIs: ErrValidation, wantMessage: "bad", wantDetail: "x is not an integer",
+		},
+		{
+			name: "detail object", status: http.StatusUnprocessableEntity,
+			body:   `{"message": "bad", "detail": [{"loc": ["x"]}]}`,
+			wantIs: ErrValidation, wantMessage: "bad", wantDetail: `[{"loc": ["x"]}]`,
+		},
+		{
+			name: "plain text", status: http.StatusBadGateway, body: "  upstream down\n",
+			wantIs: ErrServerUnavailable, wantMessage: "upstream down",
+		},
+		{
+			name: "empty body", status: http.StatusConflict,
+			wantIs: ErrConflict, wantMessage: "Conflict",
+		},
+		{
+			name: "forbidden", status: http.StatusForbidden, body: `{"message": "no"}`,
+			wantIs: ErrUnauthorized, wantMessage: "no",
+		},
+		{
+			name: "uncategorized", status: http.StatusInternalServerError, body: `{"message": "boom"}`,
+			wantMessage: "boom",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newHttpError(http.MethodGet, "v2/projects", tc.status, []byte(tc.body))
+			if err.Message != tc.wantMessage {
+				t.Errorf("message: got %q, want %q", err.Message, tc.wantMessage)
+			}
+			if err.Detail != tc.wantDetail {
+				t.Errorf("detail: got %q, want %q", err.Detail, tc.wantDetail)
+			}
+			if tc.wantIs == nil {
+				if err.Unwrap() != nil {
+					t.Errorf("got category %v, want none", err.Unwrap())
+				}
+			} else if !errors.Is(err, tc.wantIs) {
+				t.Errorf("got category %v, want %v", err.Unwrap(), tc.wantIs)
+			}
+		})
+	}
+}
+
+func TestNewHttpErrorTruncates(t *testing.T) {
+	err := newHttpError(http.MethodGet, "v2/projects", http.StatusInternalServerError,
+		[]byte(strings.Repeat("x", 2*maxErrorBody)))
+	if len(err.Message) != maxErrorBody+3 || !strings.HasSuffix(err.Message, "...") {
+		t.Errorf("got message of length %d", len(err.Message))
+	}
+}
+
+func TestRequestErrorIs(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection refused"), true},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+	} {
+		err := &RequestError{Method: http.MethodGet, Path: "v2/version", Err: tc.err}
+		if got := errors.Is(err, ErrServerUnavailable); got != tc.want {
+			t.Errorf("%v: got %v, want %v", tc.err, got, tc.want)
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%v: does not unwrap", tc.err)
+		}
+	}
+}
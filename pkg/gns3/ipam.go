@@ -0,0 +1,548 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// AddressesFile is the file of a project in which load records the
+// addresses allocated to its nodes
+const AddressesFile = "gns3ctl-addresses.json"
+
+// NetworkIPAM declares the pools from which addresses are allocated to the
+// nodes of a network.
+type NetworkIPAM struct {
+	Pools []IPPool `json:"pools" yaml:"pools"`
+}
+
+// IPPool is a subnet from which addresses are allocated. A pool with Links
+// gives each link between the nodes it names a subnet of Prefix bits, with
+// the first address for the a-end and the second for the z-end. A pool with
+// Nodes is a single segment, giving each node it names the next host
+// address, skipping the Gateway. Nodes are named by name, glob pattern or
+// group name, and addresses are allocated in the order the links and nodes
+// are declared, so the same document always gives the same addresses.
+// Reserved addresses, given as an address, a range such as
+// 10.0.0.1-10.0.0.9 or a prefix, are never allocated, nor are the subnets
+// of links that contain them.
+type IPPool struct {
+	Name     string   `json:"name" yaml:"name"`
+	Subnet   string   `json:"subnet" yaml:"subnet"`
+	Prefix   int      `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Links    []string `json:"links,omitempty" yaml:"links,omitempty"`
+	Nodes    []string `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	Gateway  string   `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Reserved []string `json:"reserved,omitempty" yaml:"reserved,omitempty"`
+}
+
+// Address is an address allocated to a node. Addresses from link pools
+// name the node at the other end of the link as the Peer, and the port of
+// the link as the Interface, as given or, once the link has been created,
+// as named by the node.
+type Address struct {
+	Node      string `json:"node" yaml:"node"`
+	Pool      string `json:"pool" yaml:"pool"`
+	Address   string `json:"address" yaml:"address"`
+	Gateway   string `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Peer      string `json:"peer,omitempty" yaml:"peer,omitempty"`
+	Interface string `json:"interface,omitempty" yaml:"interface,omitempty"`
+
+	// link is one more than the index of the link the address is for, and
+	// 0 for an address from a segment
+	link int
+}
+
+// IP returns the address without its prefix length.
+func (a Address) IP() string {
+	ip, _, _ := strings.Cut(a.Address, "/")
+	return ip
+}
+
+// Netmask returns the dotted netmask of an IPv4 address, or the prefix
+// length of an IPv6 address.
+func (a Address) Netmask() string {
+	if mask, err := cidrNetmask(a.Address); err == nil {
+		return mask
+	}
+	_, bits, _ := strings.Cut(a.Address, "/")
+	return bits
+}
+
+// Addresses returns the addresses allocated to the nodes of an expanded
+// network, by node in the order they are declared.
+func (n *Network) Addresses() []Address {
+	var list []Address
+	for _, node := range n.Spec.Nodes {
+		list = append(list, node.addresses...)
+	}
+	return list
+}
+
+// KeepAddresses allocates the addresses of an expanded network again,
+// giving each node the address it had before, such as those recorded by an
+// earlier load, if it is still in the pool and not reserved or taken by
+// another node. Other nodes are given the first addresses that are free, so
+// that adding or removing nodes does not renumber the rest.
+func (n *Network) KeepAddresses(previous []Address) error {
+	r := &networkReporter{}
+	n.assign(r, n.Spec.Nodes, previous)
+	if len(r.errs) > 0 {
+		return r.errs
+	}
+	return nil
+}
+
+// SetInterface records the port used by a node for the link at the given
+// index of an expanded network as the Interface of the addresses allocated
+// to the node for the link.
+func (n *Network) SetInterface(link int, node, iface string) {
+	for i := range n.Spec.Nodes {
+		if n.Spec.Nodes[i].Name != node {
+			continue
+		}
+		for j := range n.Spec.Nodes[i].addresses {
+			if addr := &n.Spec.Nodes[i].addresses[j]; addr.link == link+1 {
+				addr.Interface = iface
+			}
+		}
+	}
+}
+
+// ipRange is an inclusive range of addresses.
+type ipRange struct {
+	from, to netip.Addr
+}
+
+// ipamPool is a pool whose declaration is valid, with the nodes or links
+// it allocates addresses to.
+type ipamPool struct {
+	IPPool
+	at       []interface{}
+	subnet   netip.Prefix
+	gateway  netip.Addr
+	reserved []ipRange
+	// links are the indexes of the links given subnets, and nodes the
+	// names of the nodes given addresses, in the order they are allocated
+	links []int
+	ends  [][2]LinkEnd
+	nodes []string
+}
+
+// overlaps returns true if any reserved address is in prefix.
+func (p *ipamPool) overlaps(prefix netip.Prefix) bool {
+	last := lastAddr(prefix)
+	for _, r := range p.reserved {
+		if r.from.Compare(last) <= 0 && r.to.Compare(prefix.Masked().Addr()) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allocate checks the pools and gives the nodes their addresses from them.
+// It is part of expanding the network, as pools name nodes the same way as
+// generators.
+func (n *Network) allocate(r *networkReporter, nodes []NetworkNode, links []NetworkLink,
+	resolve func([]string, []interface{}) []string) {
+	n.pools = nil
+	if n.Spec.IPAM != nil {
+		names := map[string]bool{}
+		linked := map[int]bool{}
+		for i, pool := range n.Spec.IPAM.Pools {
+			if p := checkPool(r, pool, []interface{}{"spec", "ipam", "pools", i}, names); p != nil {
+				p.members(r, links, linked, resolve)
+				n.pools = append(n.pools, p)
+			}
+		}
+	}
+	n.assign(r, nodes, nil)
+}
+
+// checkPool returns a pool whose declaration is valid, or nil after
+// reporting the problem.
+func checkPool(r *networkReporter, pool IPPool, at []interface{}, names map[string]bool) *ipamPool {
+	if pool.Name == "" {
+		r.report("ipam pool name is required", at...)
+		return nil
+	}
+	if names[pool.Name] {
+		r.report(fmt.Sprintf("duplicate ipam pool name '%s'", pool.Name), subPath(at, "name")...)
+		return nil
+	}
+	names[pool.Name] = true
+	subnet, err := netip.ParsePrefix(pool.Subnet)
+	if err != nil {
+		r.report(fmt.Sprintf("ipam pool '%s' subnet '%s' is not a prefix such as 10.0.0.0/24", pool.Name, pool.Subnet),
+			subPath(at, "subnet")...)
+		return nil
+	}
+	p := &ipamPool{IPPool: pool, at: at, subnet: subnet.Masked()}
+
+	switch {
+	case len(pool.Links) > 0 && len(pool.Nodes) > 0:
+		r.report(fmt.Sprintf("ipam pool '%s' must give either links or nodes", pool.Name), at...)
+		return nil
+	case len(pool.Links) > 0:
+		if pool.Prefix <= p.subnet.Bits() || pool.Prefix > p.subnet.Addr().BitLen() {
+			r.report(fmt.Sprintf("ipam pool '%s' prefix must be longer than that of its subnet and at most %d",
+				pool.Name, p.subnet.Addr().BitLen()), subPath(at, "prefix")...)
+			return nil
+		}
+	case len(pool.Nodes) > 0:
+		if pool.Prefix != 0 {
+			r.report(fmt.Sprintf("ipam pool '%s' prefix is only used with links", pool.Name), subPath(at, "prefix")...)
+			return nil
+		}
+		if pool.Gateway != "" {
+			p.gateway, err = netip.ParseAddr(pool.Gateway)
+			if err != nil || !p.subnet.Contains(p.gateway) {
+				r.report(fmt.Sprintf("ipam pool '%s' gateway '%s' is not an address in %s", pool.Name, pool.Gateway, p.subnet),
+					subPath(at, "gateway")...)
+				return nil
+			}
+		}
+	default:
+		r.report(fmt.Sprintf("ipam pool '%s' must give links or nodes", pool.Name), at...)
+		return nil
+	}
+
+	for i, reserved := range pool.Reserved {
+		rng, ok := parseRange(reserved)
+		if !ok || !p.subnet.Contains(rng.from) || !p.subnet.Contains(rng.to) {
+			r.report(fmt.Sprintf("ipam pool '%s' reserved '%s' is not an address, range or prefix in %s",
+				pool.Name, reserved, p.subnet), subPath(at, "reserved", i)...)
+			return nil
+		}
+		p.reserved = append(p.reserved, rng)
+	}
+	return p
+}
+
+// parseRange parses an address, a range of addresses such as
+// 10.0.0.1-10.0.0.9, or a prefix.
+func parseRange(s string) (ipRange, bool) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return ipRange{from: prefix.Masked().Addr(), to: lastAddr(prefix)}, true
+	}
+	from, to, isRange := strings.Cut(s, "-")
+	if !isRange {
+		to = from
+	}
+	first, err := netip.ParseAddr(strings.TrimSpace(from))
+	if err != nil {
+		return ipRange{}, false
+	}
+	last, err := netip.ParseAddr(strings.TrimSpace(to))
+	if err != nil || first.BitLen() != last.BitLen() || first.Compare(last) > 0 {
+		return ipRange{}, false
+	}
+	return ipRange{from: first, to: last}, true
+}
+
+// members resolves the links or nodes the pool allocates addresses to. A
+// link is given a subnet by the first pool that names both of its ends.
+func (p *ipamPool) members(r *networkReporter, links []NetworkLink, linked map[int]bool,
+	resolve func([]string, []interface{}) []string) {
+	if len(p.Links) == 0 {
+		p.nodes = resolve(p.Nodes, subPath(p.at, "nodes"))
+		return
+	}
+	members := map[string]bool{}
+	for _, name := range resolve(p.Links, subPath(p.at, "links")) {
+		members[name] = true
+	}
+	for j, link := range links {
+		if linked[j] || !members[link.AEnd.Name] || !members[link.ZEnd.Name] {
+			continue
+		}
+		linked[j] = true
+		p.links = append(p.links, j)
+		p.ends = append(p.ends, [2]LinkEnd{link.AEnd, link.ZEnd})
+	}
+}
+
+// addressKey identifies an address allocated before, by the node, the pool
+// and, for links, the node at the other end.
+type addressKey struct {
+	node, pool, peer string
+}
+
+// assign gives the nodes their addresses from the pools, preferring the
+// previous addresses of the nodes.
+func (n *Network) assign(r *networkReporter, nodes []NetworkNode, previous []Address) {
+	index := make(map[string]*NetworkNode, len(nodes))
+	for i := range nodes {
+		nodes[i].addresses = nil
+		index[nodes[i].Name] = &nodes[i]
+	}
+	// parallel links between the same nodes take their previous addresses
+	// in turn
+	before := map[addressKey][]netip.Prefix{}
+	for _, addr := range previous {
+		prefix, err := netip.ParsePrefix(addr.Address)
+		if err != nil {
+			continue
+		}
+		key := addressKey{node: addr.Node, pool: addr.Pool, peer: addr.Peer}
+		before[key] = append(before[key], prefix)
+	}
+	take := func(key addressKey) (netip.Prefix, bool) {
+		list := before[key]
+		if len(list) == 0 {
+			return netip.Prefix{}, false
+		}
+		before[key] = list[1:]
+		return list[0], true
+	}
+
+	for _, p := range n.pools {
+		if len(p.links) > 0 {
+			p.assignLinks(r, index, take)
+		} else {
+			p.assignNodes(r, index, take)
+		}
+	}
+}
+
+// assignLinks gives each link of the pool a subnet, the one it had before
+// if it is still free, with each end keeping its address.
+func (p *ipamPool) assignLinks(r *networkReporter, index map[string]*NetworkNode,
+	take func(addressKey) (netip.Prefix, bool)) {
+	subnets := make([]netip.Prefix, len(p.links))
+	swap := make([]bool, len(p.links))
+	taken := map[netip.Prefix]bool{}
+	for i, ends := range p.ends {
+		a, z := ends[0].Name, ends[1].Name
+		aHad, aOk := take(addressKey{node: a, pool: p.Name, peer: z})
+		zHad, zOk := take(addressKey{node: z, pool: p.Name, peer: a})
+		prev := aHad
+		if !aOk {
+			prev = zHad
+		}
+		if !aOk && !zOk || prev.Bits() != p.Prefix {
+			continue
+		}
+		subnet := prev.Masked()
+		if !p.subnet.Contains(subnet.Addr()) || taken[subnet] || p.overlaps(subnet) {
+			continue
+		}
+		subnets[i] = subnet
+		taken[subnet] = true
+		if first, second, err := linkHosts(subnet); err == nil {
+			swap[i] = (aOk && aHad.Addr() == second) || (!aOk && zHad.Addr() == first)
+		}
+	}
+
+	next := p.subnet.Addr()
+	for i, ends := range p.ends {
+		for !subnets[i].IsValid() {
+			if !next.IsValid() || !p.subnet.Contains(next) {
+				r.report(fmt.Sprintf("ipam pool '%s' has no subnets left for link %s <-> %s",
+					p.Name, ends[0].Name, ends[1].Name), p.at...)
+				return
+			}
+			subnet := netip.PrefixFrom(next, p.Prefix)
+			next = lastAddr(subnet).Next()
+			if !taken[subnet] && !p.overlaps(subnet) {
+				subnets[i] = subnet
+				taken[subnet] = true
+			}
+		}
+	}
+
+	for i, subnet := range subnets {
+		first, second, err := linkHosts(subnet)
+		if err != nil {
+			r.report(fmt.Sprintf("ipam pool '%s': %v", p.Name, err), p.at...)
+			return
+		}
+		if swap[i] {
+			first, second = second, first
+		}
+		for _, end := range []struct {
+			end, peer LinkEnd
+			addr      netip.Addr
+		}{{p.ends[i][0], p.ends[i][1], first}, {p.ends[i][1], p.ends[i][0], second}} {
+			node := index[end.end.Name]
+			node.addresses = append(node.addresses, Address{
+				Node:      node.Name,
+				Pool:      p.Name,
+				Address:   netip.PrefixFrom(end.addr, p.Prefix).String(),
+				Peer:      end.peer.Name,
+				Interface: endPort(end.end),
+				link:      p.links[i] + 1,
+			})
+		}
+	}
+}
+
+// assignNodes gives each node of the pool a host address, the one it had
+// before if it is still free.
+func (p *ipamPool) assignNodes(r *networkReporter, index map[string]*NetworkNode,
+	take func(addressKey) (netip.Prefix, bool)) {
+	hostBits := p.subnet.Addr().BitLen() - p.subnet.Bits()
+	free := func(addr netip.Addr, taken map[netip.Addr]bool) bool {
+		if !p.subnet.Contains(addr) || taken[addr] || addr == p.gateway ||
+			p.overlaps(netip.PrefixFrom(addr, addr.BitLen())) {
+			return false
+		}
+		// the first address of a subnet with hosts, and the last of an
+		// IPv4 one, are not hosts
+		if hostBits > 1 && (addr == p.subnet.Addr() || (addr.Is4() && addr == lastAddr(p.subnet))) {
+			return false
+		}
+		return true
+	}
+
+	addrs := make([]netip.Addr, len(p.nodes))
+	taken := map[netip.Addr]bool{}
+	for i, name := range p.nodes {
+		prev, ok := take(addressKey{node: name, pool: p.Name})
+		if ok && prev.Bits() == p.subnet.Bits() && free(prev.Addr(), taken) {
+			addrs[i] = prev.Addr()
+			taken[addrs[i]] = true
+		}
+	}
+
+	hosts := segmentHosts(p.subnet)
+	for i, name := range p.nodes {
+		for !addrs[i].IsValid() {
+			addr, ok := hosts()
+			if !ok {
+				r.report(fmt.Sprintf("ipam pool '%s' has no addresses left for node '%s'", p.Name, name), p.at...)
+				return
+			}
+			if free(addr, taken) {
+				addrs[i] = addr
+				taken[addr] = true
+			}
+		}
+	}
+
+	for i, name := range p.nodes {
+		node := index[name]
+		node.addresses = append(node.addresses, Address{
+			Node:    name,
+			Pool:    p.Name,
+			Address: netip.PrefixFrom(addrs[i], p.subnet.Bits()).String(),
+			Gateway: p.Gateway,
+		})
+	}
+}
+
+// ConfigFor returns the configuration delivered to the node once it is
+// created as nodeType, if any. A VPCS node whose config gives no address is
+// configured with the first IPv4 address allocated to it.
+func (n *NetworkNode) ConfigFor(nodeType string) *NodeConfig {
+	if !strings.EqualFold(nodeType, TypeVpcs) {
+		return n.Config
+	}
+	var addr *Address
+	for i := range n.addresses {
+		if strings.Contains(n.addresses[i].Address, ".") {
+			addr = &n.addresses[i]
+			break
+		}
+	}
+	if addr == nil {
+		return n.Config
+	}
+	config := NodeConfig{Name: n.Name}
+	if n.Config != nil {
+		if !n.Config.Shorthand() || n.Config.Address != "" {
+			return n.Config
+		}
+		config = *n.Config
+	}
+	config.Address, config.Netmask, config.Gateway = addr.IP(), addr.Netmask(), addr.Gateway
+	return &config
+}
+
+// linkHosts returns the addresses of the ends of a link in a subnet, the
+// two addresses of a /31 or /127 and otherwise the first two hosts.
+func linkHosts(subnet netip.Prefix) (netip.Addr, netip.Addr, error) {
+	offset := 1
+	if subnet.Addr().BitLen()-subnet.Bits() <= 1 {
+		offset = 0
+	}
+	first, err := addToAddr(subnet.Addr(), offset)
+	if err != nil {
+		return first, first, err
+	}
+	second, err := addToAddr(first, 1)
+	if err == nil && !subnet.Contains(second) {
+		err = fmt.Errorf("subnet %s is too small for a link", subnet)
+	}
+	return first, second, err
+}
+
+// lastAddr returns the last address of a prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Masked().Addr().As16()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	for i := len(bytes) - 1; i >= 0 && hostBits > 0; i-- {
+		n := hostBits
+		if n > 8 {
+			n = 8
+		}
+		bytes[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	last := netip.AddrFrom16(bytes)
+	if prefix.Addr().Is4() {
+		last = last.Unmap()
+	}
+	return last
+}
+
+// segmentHosts returns a function giving the host addresses of a subnet in
+// turn, without the network and broadcast addresses of subnets that have
+// them, and false once they are used up.
+func segmentHosts(subnet netip.Prefix) func() (netip.Addr, bool) {
+	hostBits := subnet.Addr().BitLen() - subnet.Bits()
+	next := subnet.Addr()
+	if hostBits > 1 {
+		next = next.Next()
+	}
+	return func() (netip.Addr, bool) {
+		addr := next
+		if !addr.IsValid() || !subnet.Contains(addr) {
+			return netip.Addr{}, false
+		}
+		next = addr.Next()
+		// the last address of an IPv4 subnet is its broadcast address
+		if hostBits > 1 && addr.Is4() && (!next.IsValid() || !subnet.Contains(next)) {
+			return netip.Addr{}, false
+		}
+		return addr, true
+	}
+}
+
+// endPort describes the port given for a link end, if any.
+func endPort(end LinkEnd) string {
+	switch {
+	case end.Fixed():
+		adapter, port := end.Numbers()
+		return fmt.Sprintf("%d/%d", adapter, port)
+	case end.Interface != "":
+		return end.Interface
+	}
+	return ""
+}
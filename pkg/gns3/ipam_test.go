@@ -0,0 +1,245 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+const ipamNetwork = `apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - name: r%d
+      type: qemu
+      count: 3
+    - name: pc%d
+      type: vpcs
+      count: 4
+  generators:
+    - type: chain
+      nodes: [r%d]
+  ipam:
+    pools:
+      - name: fabric
+        subnet: 10.255.0.0/29
+        prefix: 31
+        links: [r%d]
+      - name: lan
+        subnet: 10.0.0.0/24
+        gateway: 10.0.0.1
+        nodes: [pc%d]
+`
+
+// parseIPAM parses ipamNetwork, applying edit to the text first.
+func parseIPAM(t *testing.T, edit ...string) *gns3.Network {
+	t.Helper()
+	doc := strings.NewReplacer(edit...).Replace(ipamNetwork)
+	network, err := gns3.ParseNetwork(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return network
+}
+
+// addressList returns the addresses as "node pool address peer" lines.
+func addressList(addresses []gns3.Address) string {
+	var lines []string
+	for _, addr := range addresses {
+		lines = append(lines, strings.TrimSpace(strings.Join([]string{addr.Node, addr.Pool, addr.Address, addr.Peer}, " ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestIPAMAllocate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		edit []string
+		want string
+	}{
+		{
+			name: "in order",
+			want: `r1 fabric 10.255.0.0/31 r2
+r2 fabric 10.255.0.1/31 r1
+r2 fabric 10.255.0.2/31 r3
+r3 fabric 10.255.0.3/31 r2
+pc1 lan 10.0.0.2/24
+pc2 lan 10.0.0.3/24
+pc3 lan 10.0.0.4/24
+pc4 lan 10.0.0.5/24`,
+		},
+		{
+			name: "reserved ranges",
+			edit: []string{
+				"links: [r%d]", "links: [r%d]\n        reserved: [10.255.0.1]",
+				"nodes: [pc%d]", "nodes: [pc%d]\n        reserved: [10.0.0.2-10.0.0.3, 10.0.0.8/30, 10.0.0.5]",
+			},
+			want: `r1 fabric 10.255.0.2/31 r2
+r2 fabric 10.255.0.3/31 r1
+r2 fabric 10.255.0.4/31 r3
+r3 fabric 10.255.0.5/31 r2
+pc1 lan 10.0.0.4/24
+pc2 lan 10.0.0.6/24
+pc3 lan 10.0.0.7/24
+pc4 lan 10.0.0.12/24`,
+		},
+		{
+			name: "point to point segment",
+			edit: []string{"count: 4", "count: 2", "10.0.0.0/24", "10.0.0.0/31", "        gateway: 10.0.0.1\n", ""},
+			want: `r1 fabric 10.255.0.0/31 r2
+r2 fabric 10.255.0.1/31 r1
+r2 fabric 10.255.0.2/31 r3
+r3 fabric 10.255.0.3/31 r2
+pc1 lan 10.0.0.0/31
+pc2 lan 10.0.0.1/31`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			network := parseIPAM(t, tc.edit...)
+			if got := addressList(network.Addresses()); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIPAMErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		edit []string
+		want string
+	}{
+		{
+			name: "no subnets left",
+			edit: []string{"count: 3", "count: 6"},
+			want: "ipam pool 'fabric' has no subnets left for link r5 <-> r6",
+		},
+		{
+			name: "no subnets left once reserved",
+			edit: []string{"count: 3", "count: 5", "links: [r%d]", "links: [r%d]\n        reserved: [10.255.0.6/31]"},
+			want: "ipam pool 'fabric' has no subnets left for link r4 <-> r5",
+		},
+		{
+			name: "no addresses left",
+			edit: []string{"10.0.0.0/24", "10.0.0.0/29", "gateway: 10.0.0.1", "gateway: 10.0.0.6", "count: 4", "count: 6"},
+			want: "ipam pool 'lan' has no addresses left for node 'pc6'",
+		},
+		{
+			name: "no addresses left once reserved",
+			edit: []string{"nodes: [pc%d]", "nodes: [pc%d]\n        reserved: [10.0.0.2-10.0.0.252]"},
+			want: "ipam pool 'lan' has no addresses left for node 'pc3'",
+		},
+		{
+			name: "reserved outside the subnet",
+			edit: []string{"nodes: [pc%d]", "nodes: [pc%d]\n        reserved: [10.1.0.1]"},
+			want: "ipam pool 'lan' reserved '10.1.0.1' is not an address, range or prefix in 10.0.0.0/24",
+		},
+		{
+			name: "reserved range backwards",
+			edit: []string{"nodes: [pc%d]", "nodes: [pc%d]\n        reserved: [10.0.0.9-10.0.0.2]"},
+			want: "reserved '10.0.0.9-10.0.0.2' is not an address, range or prefix",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := strings.NewReplacer(tc.edit...).Replace(ipamNetwork)
+			_, err := gns3.ParseNetwork(strings.NewReader(doc))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeepAddresses(t *testing.T) {
+	previous := parseIPAM(t).Addresses()
+
+	// the same document gives the same addresses
+	network := parseIPAM(t)
+	if err := network.KeepAddresses(previous); err != nil {
+		t.Fatalf("keep: %v", err)
+	}
+	if got, want := addressList(network.Addresses()), addressList(previous); got != want {
+		t.Errorf("reload:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// removing a node or link leaves the others with their addresses,
+	// whichever end their links are declared from, and new ones take the
+	// first that are free
+	network = parseIPAM(t,
+		"    - name: pc%d\n      type: vpcs\n      count: 4\n",
+		"    - name: pc1\n      type: vpcs\n    - name: pc3\n      type: vpcs\n    - name: pc4\n      type: vpcs\n    - name: pc9\n      type: vpcs\n",
+		"nodes: [pc%d]", "nodes: [pc9, pc4, pc3, pc1]",
+		"    - type: chain\n      nodes: [r%d]\n", "    - type: full-mesh\n      nodes: [r3, r2, r1]\n",
+	)
+	if err := network.KeepAddresses(previous); err != nil {
+		t.Fatalf("keep: %v", err)
+	}
+	want := `r1 fabric 10.255.0.5/31 r3
+r1 fabric 10.255.0.0/31 r2
+r2 fabric 10.255.0.2/31 r3
+r2 fabric 10.255.0.1/31 r1
+r3 fabric 10.255.0.3/31 r2
+r3 fabric 10.255.0.4/31 r1
+pc1 lan 10.0.0.2/24
+pc3 lan 10.0.0.4/24
+pc4 lan 10.0.0.5/24
+pc9 lan 10.0.0.3/24`
+	if got := addressList(network.Addresses()); got != want {
+		t.Errorf("edited:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// previous addresses that are now reserved are given up
+	network = parseIPAM(t, "nodes: [pc%d]", "nodes: [pc%d]\n        reserved: [10.0.0.3]")
+	if err := network.KeepAddresses(previous); err != nil {
+		t.Fatalf("keep: %v", err)
+	}
+	want = `pc1 lan 10.0.0.2/24
+pc2 lan 10.0.0.6/24
+pc3 lan 10.0.0.4/24
+pc4 lan 10.0.0.5/24`
+	if got := addressList(network.Addresses()[4:]); got != want {
+		t.Errorf("reserved:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConfigFor(t *testing.T) {
+	network := parseIPAM(t,
+		"    - name: pc%d\n      type: vpcs\n      count: 4\n",
+		"    - name: pc%d\n      template: PC\n      count: 4\n",
+	)
+	pc1 := &network.Spec.Nodes[3]
+	config := pc1.ConfigFor(gns3.TypeVpcs)
+	if config == nil || config.Name != "pc1" || config.Address != "10.0.0.2" ||
+		config.Netmask != "255.255.255.0" || config.Gateway != "10.0.0.1" {
+		t.Errorf("template vpcs node: got %+v", config)
+	}
+	if config := pc1.ConfigFor(gns3.TemplateTypeDocker); config != nil {
+		t.Errorf("docker node: got %+v", config)
+	}
+	pc1.Config = &gns3.NodeConfig{Text: "ip dhcp"}
+	if config := pc1.ConfigFor(gns3.TypeVpcs); config != pc1.Config {
+		t.Errorf("configured node: got %+v", config)
+	}
+	if config := network.Spec.Nodes[0].ConfigFor(gns3.TemplateTypeQemu); config != nil {
+		t.Errorf("qemu node: got %+v", config)
+	}
+}
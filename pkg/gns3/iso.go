@@ -0,0 +1,233 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ISOFile is a file in the root directory of an ISO image.
+type ISOFile struct {
+	Name string
+	Data []byte
+}
+
+const (
+	isoSector = 2048
+	// isoMaxName is the longest name Joliet allows, in characters
+	isoMaxName = 64
+)
+
+// BuildISO returns an ISO 9660 image, with Joliet names, holding the files
+// in its root directory. Such images are used as configuration disks, for
+// example by cloud-init with the label cidata. Names are kept as they are
+// in the Joliet directory and upper cased in the ISO 9660 directory.
+func BuildISO(label string, files []ISOFile) ([]byte, error) {
+	files = append([]ISOFile(nil), files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	for i, f := range files {
+		switch {
+		case f.Name == "" || strings.ContainsAny(f.Name, "/\\;"):
+			return nil, fmt.Errorf("invalid ISO file name '%s'", f.Name)
+		case len(utf16.Encode([]rune(f.Name))) > isoMaxName:
+			return nil, fmt.Errorf("ISO file name '%s' is longer than %d characters", f.Name, isoMaxName)
+		case i > 0 && strings.EqualFold(files[i-1].Name, f.Name):
+			return nil, fmt.Errorf("duplicate ISO file name '%s'", f.Name)
+		}
+	}
+	now := time.Now().UTC()
+
+	// system area, volume descriptors, path tables, root directories, then
+	// the file data
+	const (
+		primaryAt    = 16
+		jolietAt     = 17
+		terminatorAt = 18
+		pathTablesAt = 19
+	)
+	primaryNames := make([][]byte, len(files))
+	jolietNames := make([][]byte, len(files))
+	for i, f := range files {
+		primaryNames[i] = []byte(strings.ToUpper(f.Name) + ";1")
+		jolietNames[i] = ucs2(f.Name)
+	}
+	primarySize := isoDirSize(primaryNames)
+	jolietSize := isoDirSize(jolietNames)
+	primaryRoot := uint32(pathTablesAt + 4)
+	jolietRoot := primaryRoot + primarySize/isoSector
+	extents := make([]uint32, len(files))
+	next := jolietRoot + jolietSize/isoSector
+	for i, f := range files {
+		if len(f.Data) == 0 {
+			continue
+		}
+		extents[i] = next
+		next += uint32((len(f.Data) + isoSector - 1) / isoSector)
+	}
+	image := make([]byte, int(next)*isoSector)
+	sector := func(lba uint32) []byte {
+		return image[lba*isoSector:]
+	}
+
+	copy(sector(primaryAt), isoVolumeDescriptor(1, []byte(strings.ToUpper(label)), next, primaryRoot, primarySize, pathTablesAt, now))
+	joliet := isoVolumeDescriptor(2, ucs2(label), next, jolietRoot, jolietSize, pathTablesAt+2, now)
+	// escape sequence for UCS-2 level 3
+	copy(joliet[88:], "%/E")
+	copy(sector(jolietAt), joliet)
+	copy(sector(terminatorAt), []byte{255, 'C', 'D', '0', '0', '1', 1})
+
+	copy(sector(pathTablesAt), isoPathTable(primaryRoot, binary.LittleEndian))
+	copy(sector(pathTablesAt+1), isoPathTable(primaryRoot, binary.BigEndian))
+	copy(sector(pathTablesAt+2), isoPathTable(jolietRoot, binary.LittleEndian))
+	copy(sector(pathTablesAt+3), isoPathTable(jolietRoot, binary.BigEndian))
+
+	for _, dir := range []struct {
+		at, size uint32
+		names    [][]byte
+	}{{primaryRoot, primarySize, primaryNames}, {jolietRoot, jolietSize, jolietNames}} {
+		var records bytes.Buffer
+		records.Write(isoDirRecord([]byte{0}, dir.at, dir.size, true, now))
+		records.Write(isoDirRecord([]byte{1}, dir.at, dir.size, true, now))
+		for i, f := range files {
+			record := isoDirRecord(dir.names[i], extents[i], uint32(len(f.Data)), false, now)
+			// records may not cross a sector boundary
+			if used := records.Len() % isoSector; used+len(record) > isoSector {
+				records.Write(make([]byte, isoSector-used))
+			}
+			records.Write(record)
+		}
+		copy(sector(dir.at), records.Bytes())
+	}
+	for i, f := range files {
+		copy(sector(extents[i]), f.Data)
+	}
+	return image, nil
+}
+
+// isoDirSize returns the size, in whole sectors, of a root directory with
+// files of the given names.
+func isoDirSize(names [][]byte) uint32 {
+	size := 2 * 34
+	for _, name := range names {
+		length := 33 + len(name) + (len(name)+1)%2
+		if used := size % isoSector; used+length > isoSector {
+			size += isoSector - used
+		}
+		size += length
+	}
+	return uint32((size + isoSector - 1) / isoSector * isoSector)
+}
+
+// isoDirRecord returns a directory record, as given by ECMA-119 9.1.
+func isoDirRecord(name []byte, extent, size uint32, dir bool, at time.Time) []byte {
+	length := 33 + len(name) + (len(name)+1)%2
+	r := make([]byte, length)
+	r[0] = byte(length)
+	bothEndian32(r[2:], extent)
+	bothEndian32(r[10:], size)
+	copy(r[18:], isoRecordTime(at))
+	if dir {
+		r[25] = 2
+	}
+	bothEndian16(r[28:], 1)
+	r[32] = byte(len(name))
+	copy(r[33:], name)
+	return r
+}
+
+// isoVolumeDescriptor returns a primary, or supplementary, volume
+// descriptor, as given by ECMA-119 8.4 and 8.5.
+func isoVolumeDescriptor(kind byte, label []byte, sectors, root, rootSize, pathTable uint32, at time.Time) []byte {
+	d := make([]byte, isoSector)
+	d[0] = kind
+	copy(d[1:], "CD001")
+	d[6] = 1
+	pad := func(field []byte, value []byte) {
+		for i := range field {
+			field[i] = ' '
+			if kind == 2 && i%2 == 0 {
+				// UCS-2 spaces
+				field[i] = 0
+			}
+		}
+		copy(field, value)
+	}
+	pad(d[8:40], nil)
+	if len(label) > 32 {
+		label = label[:32]
+	}
+	pad(d[40:72], label)
+	bothEndian32(d[80:], sectors)
+	bothEndian16(d[120:], 1)
+	bothEndian16(d[124:], 1)
+	bothEndian16(d[128:], isoSector)
+	bothEndian32(d[132:], 10)
+	binary.LittleEndian.PutUint32(d[140:], pathTable)
+	binary.BigEndian.PutUint32(d[148:], pathTable+1)
+	copy(d[156:], isoDirRecord([]byte{0}, root, rootSize, true, at))
+	pad(d[190:318], nil)
+	pad(d[318:446], nil)
+	pad(d[446:574], nil)
+	pad(d[574:702], nil)
+	pad(d[702:813], nil)
+	stamp := []byte(at.Format("20060102150405") + "00\x00")
+	copy(d[813:], stamp)
+	copy(d[830:], stamp)
+	copy(d[847:], "0000000000000000")
+	copy(d[864:], "0000000000000000")
+	d[881] = 1
+	return d
+}
+
+// isoPathTable returns a path table holding only the root directory.
+func isoPathTable(root uint32, order binary.ByteOrder) []byte {
+	t := make([]byte, 10)
+	t[0] = 1
+	order.PutUint32(t[2:], root)
+	order.PutUint16(t[6:], 1)
+	return t
+}
+
+func isoRecordTime(at time.Time) []byte {
+	return []byte{byte(at.Year() - 1900), byte(at.Month()), byte(at.Day()),
+		byte(at.Hour()), byte(at.Minute()), byte(at.Second()), 0}
+}
+
+func ucs2(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.BigEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+func bothEndian16(b []byte, v uint16) {
+	binary.LittleEndian.PutUint16(b, v)
+	binary.BigEndian.PutUint16(b[2:], v)
+}
+
+func bothEndian32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
@@ -0,0 +1,415 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	LayoutAuto    = "auto"
+	LayoutLayered = "layered"
+	LayoutForce   = "force"
+	LayoutGrid    = "grid"
+	LayoutNone    = "none"
+)
+
+// The distance between neighbouring nodes placed by a layout
+const (
+	layoutSpacingX = 150
+	layoutSpacingY = 120
+)
+
+var layoutAlgorithms = []string{LayoutAuto, LayoutLayered, LayoutForce, LayoutGrid, LayoutNone}
+
+// checkLayout returns an error if algorithm is not a supported layout.
+func checkLayout(algorithm string) error {
+	for _, a := range layoutAlgorithms {
+		if a == algorithm {
+			return nil
+		}
+	}
+	return &NetworkError{Message: fmt.Sprintf("unsupported layout '%s', expected one of %s",
+		algorithm, strings.Join(layoutAlgorithms, ", "))}
+}
+
+// Layout assigns coordinates to the nodes of the network that have none, or
+// to every node if all is true. The algorithm is one of the Layout
+// constants; when it is empty the layout given by the spec is used, which
+// defaults to auto. Auto picks layered for networks with links and grid for
+// those without.
+//
+// Nodes placed alongside nodes that already have coordinates are laid out
+// below them, so they do not overlap.
+func (n *Network) Layout(algorithm string, all bool) error {
+	if algorithm == "" {
+		algorithm = n.Spec.Layout
+	}
+	if algorithm == "" {
+		algorithm = LayoutAuto
+	}
+	if err := checkLayout(algorithm); err != nil {
+		return err
+	}
+	if algorithm == LayoutNone {
+		return nil
+	}
+
+	var place []*NetworkNode
+	placed := false
+	minX, maxY := 0, 0
+	for i := range n.Spec.Nodes {
+		node := &n.Spec.Nodes[i]
+		if all || node.X == nil || node.Y == nil {
+			place = append(place, node)
+			continue
+		}
+		if !placed || *node.X < minX {
+			minX = *node.X
+		}
+		if !placed || *node.Y > maxY {
+			maxY = *node.Y
+		}
+		placed = true
+	}
+	if len(place) == 0 {
+		return nil
+	}
+
+	g := newLayoutGraph(place, n.Spec.Links)
+	if algorithm == LayoutAuto {
+		algorithm = LayoutGrid
+		if g.edges > 0 {
+			algorithm = LayoutLayered
+		}
+	}
+	var points []layoutPoint
+	switch algorithm {
+	case LayoutLayered:
+		points = g.layered()
+	case LayoutForce:
+		points = g.force()
+	case LayoutGrid:
+		points = g.grid()
+	}
+
+	// centre the layout on the origin, or start it below the nodes that
+	// already have coordinates
+	left, top, right, bottom := bounds(points)
+	dx, dy := -(left+right)/2, -(top+bottom)/2
+	if placed {
+		dx, dy = float64(minX)-left, float64(maxY+layoutSpacingY)-top
+	}
+	for i, node := range place {
+		x := int(math.Round(points[i].x + dx))
+		y := int(math.Round(points[i].y + dy))
+		if all || node.X == nil {
+			node.X = &x
+		}
+		if all || node.Y == nil {
+			node.Y = &y
+		}
+	}
+	return nil
+}
+
+type layoutPoint struct {
+	x, y float64
+}
+
+// bounds returns the smallest box containing the points.
+func bounds(points []layoutPoint) (left, top, right, bottom float64) {
+	for i, p := range points {
+		if i == 0 || p.x < left {
+			left = p.x
+		}
+		if i == 0 || p.x > right {
+			right = p.x
+		}
+		if i == 0 || p.y < top {
+			top = p.y
+		}
+		if i == 0 || p.y > bottom {
+			bottom = p.y
+		}
+	}
+	return left, top, right, bottom
+}
+
+// layoutGraph is the undirected graph of the nodes being laid out, with
+// nodes referenced by their index.
+type layoutGraph struct {
+	nodes []*NetworkNode
+	adj   [][]int
+	edges int
+}
+
+func newLayoutGraph(nodes []*NetworkNode, links []NetworkLink) *layoutGraph {
+	g := &layoutGraph{nodes: nodes, adj: make([][]int, len(nodes))}
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.Name] = i
+	}
+	seen := map[[2]int]bool{}
+	for _, link := range links {
+		a, aok := index[link.AEnd.Name]
+		z, zok := index[link.ZEnd.Name]
+		if !aok || !zok || a == z {
+			continue
+		}
+		if a > z {
+			a, z = z, a
+		}
+		if seen[[2]int{a, z}] {
+			continue
+		}
+		seen[[2]int{a, z}] = true
+		g.adj[a] = append(g.adj[a], z)
+		g.adj[z] = append(g.adj[z], a)
+		g.edges++
+	}
+	return g
+}
+
+// components returns the connected components of the graph, in the order
+// of their first node.
+func (g *layoutGraph) components() [][]int {
+	var comps [][]int
+	seen := make([]bool, len(g.nodes))
+	for i := range g.nodes {
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		comp := []int{i}
+		for j := 0; j < len(comp); j++ {
+			for _, next := range g.adj[comp[j]] {
+				if !seen[next] {
+					seen[next] = true
+					comp = append(comp, next)
+				}
+			}
+		}
+		sort.Ints(comp)
+		comps = append(comps, comp)
+	}
+	return comps
+}
+
+// roots returns the nodes a component is layered from: the NAT and cloud
+// nodes that connect it to the outside world, or else its most connected
+// nodes, such as the spines of a fabric.
+func (g *layoutGraph) roots(comp []int) []int {
+	var roots []int
+	for _, i := range comp {
+		if t := g.nodes[i].Type; t == TypeNat || t == "cloud" {
+			roots = append(roots, i)
+		}
+	}
+	if len(roots) > 0 {
+		return roots
+	}
+	most := 0
+	for _, i := range comp {
+		if len(g.adj[i]) > most {
+			most = len(g.adj[i])
+		}
+	}
+	for _, i := range comp {
+		if len(g.adj[i]) == most {
+			roots = append(roots, i)
+		}
+	}
+	if len(roots) == len(comp) {
+		// no node stands out, as in a ring
+		return comp[:1]
+	}
+	return roots
+}
+
+// layered places each component in rows by distance from its roots, then
+// orders each row by the average position of the neighbours in the rows
+// either side of it to reduce the number of crossing links. Components are
+// placed side by side.
+func (g *layoutGraph) layered() []layoutPoint {
+	points := make([]layoutPoint, len(g.nodes))
+	offset := 0.0
+	for _, comp := range g.components() {
+		depth := map[int]int{}
+		queue := g.roots(comp)
+		for _, r := range queue {
+			depth[r] = 0
+		}
+		for j := 0; j < len(queue); j++ {
+			for _, next := range g.adj[queue[j]] {
+				if _, ok := depth[next]; !ok {
+					depth[next] = depth[queue[j]] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+
+		var layers [][]int
+		for _, i := range comp {
+			for depth[i] >= len(layers) {
+				layers = append(layers, nil)
+			}
+			layers[depth[i]] = append(layers[depth[i]], i)
+		}
+
+		order := make(map[int]float64, len(comp))
+		setOrder := func(layer []int) {
+			for pos, i := range layer {
+				order[i] = float64(pos)
+			}
+		}
+		for _, layer := range layers {
+			setOrder(layer)
+		}
+		barycenter := func(layer []int, from int) {
+			centre := make(map[int]float64, len(layer))
+			for _, i := range layer {
+				sum, count := 0.0, 0
+				for _, next := range g.adj[i] {
+					if depth[next] == from {
+						sum += order[next]
+						count++
+					}
+				}
+				centre[i] = order[i]
+				if count > 0 {
+					centre[i] = sum / float64(count)
+				}
+			}
+			sort.SliceStable(layer, func(a, b int) bool { return centre[layer[a]] < centre[layer[b]] })
+			setOrder(layer)
+		}
+		for sweep := 0; sweep < 4; sweep++ {
+			for d := 1; d < len(layers); d++ {
+				barycenter(layers[d], d-1)
+			}
+			for d := len(layers) - 2; d >= 0; d-- {
+				barycenter(layers[d], d+1)
+			}
+		}
+
+		width := 0
+		for _, layer := range layers {
+			if len(layer) > width {
+				width = len(layer)
+			}
+		}
+		for d, layer := range layers {
+			// centre each row within the width of the component
+			start := offset + float64(width-len(layer))*layoutSpacingX/2
+			for pos, i := range layer {
+				points[i] = layoutPoint{x: start + float64(pos)*layoutSpacingX, y: float64(d) * layoutSpacingY}
+			}
+		}
+		offset += float64(width+1) * layoutSpacingX
+	}
+	return points
+}
+
+// force places the nodes with the Fruchterman-Reingold algorithm, where
+// linked nodes attract each other and all nodes repel each other. The nodes
+// start evenly spaced on a circle so the result is the same every time.
+func (g *layoutGraph) force() []layoutPoint {
+	count := len(g.nodes)
+	points := make([]layoutPoint, count)
+	if count == 1 {
+		return points
+	}
+	const k = layoutSpacingX
+	radius := float64(count) * k / (2 * math.Pi)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / float64(count)
+		points[i] = layoutPoint{x: radius * math.Cos(angle), y: radius * math.Sin(angle)}
+	}
+
+	const iterations = 300
+	const gravity = 0.5
+	temperature := radius / 4
+	disp := make([]layoutPoint, count)
+	for iter := 0; iter < iterations; iter++ {
+		for i := range disp {
+			disp[i] = layoutPoint{}
+		}
+		for i := 0; i < count; i++ {
+			for j := i + 1; j < count; j++ {
+				dx, dy, dist := separation(points[i], points[j])
+				f := k * k / dist
+				disp[i].x += dx / dist * f
+				disp[i].y += dy / dist * f
+				disp[j].x -= dx / dist * f
+				disp[j].y -= dy / dist * f
+			}
+		}
+		for i, adj := range g.adj {
+			for _, j := range adj {
+				if j < i {
+					continue
+				}
+				dx, dy, dist := separation(points[i], points[j])
+				f := dist * dist / k
+				disp[i].x -= dx / dist * f
+				disp[i].y -= dy / dist * f
+				disp[j].x += dx / dist * f
+				disp[j].y += dy / dist * f
+			}
+		}
+		for i := range points {
+			// gravity keeps unlinked nodes from drifting away
+			disp[i].x -= points[i].x * gravity
+			disp[i].y -= points[i].y * gravity
+			length := math.Hypot(disp[i].x, disp[i].y)
+			if length == 0 {
+				continue
+			}
+			step := math.Min(length, temperature)
+			points[i].x += disp[i].x / length * step
+			points[i].y += disp[i].y / length * step
+		}
+		temperature *= 0.98
+	}
+	return points
+}
+
+// separation returns the offset from b to a and its length, which is never
+// 0 so it can be divided by.
+func separation(a, b layoutPoint) (dx, dy, dist float64) {
+	dx, dy = a.x-b.x, a.y-b.y
+	dist = math.Hypot(dx, dy)
+	if dist < 0.01 {
+		dx, dist = 0.01, 0.01
+	}
+	return dx, dy, dist
+}
+
+// grid places the nodes in rows, in the order they are declared, with as
+// many columns as rows.
+func (g *layoutGraph) grid() []layoutPoint {
+	points := make([]layoutPoint, len(g.nodes))
+	cols := int(math.Ceil(math.Sqrt(float64(len(g.nodes)))))
+	for i := range points {
+		points[i] = layoutPoint{x: float64(i%cols) * layoutSpacingX, y: float64(i/cols) * layoutSpacingY}
+	}
+	return points
+}
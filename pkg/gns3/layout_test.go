@@ -0,0 +1,133 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+const layoutNetwork = `apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - {name: nat1, type: nat}
+    - {name: r1, type: qemu}
+    - {name: sw1, type: ethernet_switch}
+    - {name: sw2, type: ethernet_switch}
+    - {name: pc1, type: vpcs}
+    - {name: pc2, type: vpcs}
+    - {name: pc3, type: vpcs}
+    - {name: pc4, type: vpcs}
+    - {name: pc5, type: vpcs}
+  links:
+    - {aEnd: {name: nat1}, zEnd: {name: r1}}
+    - {aEnd: {name: r1}, zEnd: {name: sw1}}
+    - {aEnd: {name: r1}, zEnd: {name: sw2}}
+    - {aEnd: {name: sw1}, zEnd: {name: pc1}}
+    - {aEnd: {name: sw1}, zEnd: {name: pc2}}
+    - {aEnd: {name: sw2}, zEnd: {name: pc3}}
+    - {aEnd: {name: sw2}, zEnd: {name: pc4}}
+`
+
+// layoutSize is the space a node needs to not overlap its neighbours.
+const layoutSize = 60
+
+// laidOut parses the layout network, applying edit to the text first, and
+// lays it out with algorithm.
+func laidOut(t *testing.T, algorithm string, edit ...string) *gns3.Network {
+	t.Helper()
+	network, err := gns3.ParseNetwork(strings.NewReader(strings.NewReplacer(edit...).Replace(layoutNetwork)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := network.Layout(algorithm, false); err != nil {
+		t.Fatalf("%s: %v", algorithm, err)
+	}
+	return network
+}
+
+func positions(network *gns3.Network) string {
+	var b strings.Builder
+	for _, node := range network.Spec.Nodes {
+		fmt.Fprintf(&b, "%s=%d,%d ", node.Name, *node.X, *node.Y)
+	}
+	return b.String()
+}
+
+func TestLayout(t *testing.T) {
+	for _, algorithm := range []string{gns3.LayoutLayered, gns3.LayoutForce, gns3.LayoutGrid} {
+		network := laidOut(t, algorithm)
+		if again := laidOut(t, algorithm); positions(again) != positions(network) {
+			t.Errorf("%s: not deterministic:\n%s\n%s", algorithm, positions(network), positions(again))
+		}
+
+		nodes := network.Spec.Nodes
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				dx, dy := *nodes[i].X-*nodes[j].X, *nodes[i].Y-*nodes[j].Y
+				if dx > -layoutSize && dx < layoutSize && dy > -layoutSize && dy < layoutSize {
+					t.Errorf("%s: %s and %s overlap: %s", algorithm, nodes[i].Name, nodes[j].Name, positions(network))
+				}
+			}
+		}
+	}
+}
+
+func TestLayoutKeepsPositions(t *testing.T) {
+	for _, algorithm := range []string{gns3.LayoutLayered, gns3.LayoutForce, gns3.LayoutGrid} {
+		network := laidOut(t, algorithm,
+			"{name: r1, type: qemu}", "{name: r1, type: qemu, x: 500, y: -200}",
+			"{name: sw1, type: ethernet_switch}", "{name: sw1, type: ethernet_switch, x: 400, y: 40}")
+		for _, node := range network.Spec.Nodes {
+			switch node.Name {
+			case "r1":
+				if *node.X != 500 || *node.Y != -200 {
+					t.Errorf("%s: r1 moved to %d,%d", algorithm, *node.X, *node.Y)
+				}
+			case "sw1":
+				if *node.X != 400 || *node.Y != 40 {
+					t.Errorf("%s: sw1 moved to %d,%d", algorithm, *node.X, *node.Y)
+				}
+			default:
+				// new nodes start a row below the lowest existing node and
+				// in line with the leftmost
+				if *node.Y < 40+120 || *node.X < 400 {
+					t.Errorf("%s: %s placed at %d,%d, not below the existing nodes", algorithm, node.Name, *node.X, *node.Y)
+				}
+			}
+		}
+	}
+}
+
+func TestLayoutUnsupported(t *testing.T) {
+	network, err := gns3.ParseNetwork(strings.NewReader(layoutNetwork))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := network.Layout("circle", false); err == nil {
+		t.Errorf("unsupported layout: no error")
+	}
+	if err := network.Layout(gns3.LayoutNone, false); err != nil || network.Spec.Nodes[0].X != nil {
+		t.Errorf("none: got %v, placed %v", err, network.Spec.Nodes[0].X)
+	}
+}
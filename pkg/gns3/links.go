@@ -17,6 +17,7 @@ limitations under the License.
 package gns3
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -64,8 +65,12 @@ func (g *Gns3) Links(id string) *Links {
 }
 
 func (l *Links) List() ([]*Link, error) {
+	return l.ListContext(context.Background())
+}
+
+func (l *Links) ListContext(ctx context.Context) ([]*Link, error) {
 	list := []*Link{}
-	err := l.gns3.Get(fmt.Sprintf(LinksPath, l.projectID), &list)
+	err := l.gns3.GetContext(ctx, fmt.Sprintf(LinksPath, l.projectID), &list)
 	if err != nil {
 		return nil, err
 	}
@@ -73,14 +78,22 @@ func (l *Links) List() ([]*Link, error) {
 }
 
 func (l *Links) Get(id string) (*Link, error) {
+	return l.GetContext(context.Background(), id)
+}
+
+func (l *Links) GetContext(ctx context.Context, id string) (*Link, error) {
 	var link Link
-	err := l.gns3.Get(fmt.Sprintf(LinkPath, l.projectID, id), &link)
+	err := l.gns3.GetContext(ctx, fmt.Sprintf(LinkPath, l.projectID, id), &link)
 	return &link, err
 }
 
 func (l *Links) Create(link *Link) (*Link, error) {
+	return l.CreateContext(context.Background(), link)
+}
+
+func (l *Links) CreateContext(ctx context.Context, link *Link) (*Link, error) {
 	var out Link
-	err := l.gns3.Post(fmt.Sprintf(LinksPath, l.projectID), "application/json", link, &out)
+	err := l.gns3.PostContext(ConflictRetryable(ctx), fmt.Sprintf(LinksPath, l.projectID), "application/json", link, &out)
 	if err != nil {
 		return nil, err
 	}
@@ -89,11 +102,15 @@ func (l *Links) Create(link *Link) (*Link, error) {
 }
 
 func (l *Links) Delete(id string) (string, error) {
-	li, err := l.Get(id)
+	return l.DeleteContext(context.Background(), id)
+}
+
+func (l *Links) DeleteContext(ctx context.Context, id string) (string, error) {
+	li, err := l.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return li.LinkId, l.gns3.Delete(fmt.Sprintf(LinkPath, l.projectID, li.LinkId))
+	return li.LinkId, l.gns3.DeleteContext(ctx, fmt.Sprintf(LinkPath, l.projectID, li.LinkId))
 }
 
 var resumePatch = map[string]interface{}{
@@ -104,17 +121,25 @@ var suspendPatch = map[string]interface{}{
 }
 
 func (l *Links) Resume(id string) (string, error) {
-	li, err := l.Get(id)
+	return l.ResumeContext(context.Background(), id)
+}
+
+func (l *Links) ResumeContext(ctx context.Context, id string) (string, error) {
+	li, err := l.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return li.LinkId, l.gns3.Put(fmt.Sprintf(LinkPath, l.projectID, li.LinkId), "application/json", &resumePatch, nil)
+	return li.LinkId, l.gns3.PutContext(ctx, fmt.Sprintf(LinkPath, l.projectID, li.LinkId), "application/json", &resumePatch, nil)
 }
 
 func (l *Links) Suspend(id string) (string, error) {
-	li, err := l.Get(id)
+	return l.SuspendContext(context.Background(), id)
+}
+
+func (l *Links) SuspendContext(ctx context.Context, id string) (string, error) {
+	li, err := l.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return li.LinkId, l.gns3.Put(fmt.Sprintf(LinkPath, l.projectID, li.LinkId), "application/json", &suspendPatch, nil)
+	return li.LinkId, l.gns3.PutContext(ctx, fmt.Sprintf(LinkPath, l.projectID, li.LinkId), "application/json", &suspendPatch, nil)
 }
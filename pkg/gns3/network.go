@@ -16,41 +16,246 @@ limitations under the License.
 
 package gns3
 
+import "fmt"
+
 //nolint:tagliatelle
 type Network struct {
-	ApiVersion string `json:"apiVersion" yaml:"apiVersion"`
-	Kind       string `json:"kind" yaml:"kind"`
-	Metadata   struct {
-		Name string `json:"name" yaml:"name"`
-	} `json:"metadata" yaml:"metadata"`
-	Spec struct {
-		Appliances []string `json:"appliances,omitempty" yaml:"appliances,omitempty"`
-		Nodes      []struct {
-			Name      string `json:"name,omitempty" yaml:"name"`
-			Type      string `json:"type,omitempty" yaml:"type,omitempty"`
-			Template  string `json:"template,omitempty" yaml:"template,omitempty"`
-			ComputeId string `json:"compute_id,omitempty" yaml:"compute_id"`
-			X         int    `json:"x,omitempty" yaml:"x"`
-			Y         int    `json:"y,omitempty" yaml:"y"`
-			Z         int    `json:"z,omitempty" yaml:"z"`
-			Config    *struct {
-				Name    string `json:"name,omitempty" yaml:"name"`
-				Address string `json:"address,omitempty" yaml:"address"`
-				Netmask string `json:"netmask,omitempty" yaml:"netmask"`
-				Gateway string `json:"gateway,omitempty" yaml:"gateway"`
-			} `json:"config,omitempty" yaml:"config"`
-		} `json:"nodes" yaml:"nodes"`
-		Links []struct {
-			AEnd struct {
-				Name    string `json:"name,omitempty" yaml:"name"`
-				Adapter int    `json:"adapter,omitempty" yaml:"adapter"`
-				Port    int    `json:"port,omitempty" yaml:"port"`
-			} `json:"aEnd,omitempty" yaml:"aEnd"`
-			ZEnd struct {
-				Name    string `json:"name,omitempty" yaml:"name"`
-				Adapter int    `json:"adapter,omitempty" yaml:"adapter"`
-				Port    int    `json:"port,omitempty" yaml:"port"`
-			} `json:"zEnd,omitempty" yaml:"zEnd"`
-		} `json:"links" yaml:"links"`
-	} `json:"spec" yaml:"spec"`
+	ApiVersion string          `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string          `json:"kind" yaml:"kind"`
+	Metadata   NetworkMetadata `json:"metadata" yaml:"metadata"`
+	Spec       NetworkSpec     `json:"spec" yaml:"spec"`
+
+	// pools are the valid IPAM pools, resolved when the network is expanded
+	pools []*ipamPool
+}
+
+type NetworkMetadata struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+type NetworkSpec struct {
+	Appliances []string           `json:"appliances,omitempty" yaml:"appliances,omitempty"`
+	Nodes      []NetworkNode      `json:"nodes" yaml:"nodes"`
+	Links      []NetworkLink      `json:"links" yaml:"links"`
+	Generators []NetworkGenerator `json:"generators,omitempty" yaml:"generators,omitempty"`
+
+	// Layout is the algorithm used to place nodes without coordinates
+	Layout string `json:"layout,omitempty" yaml:"layout,omitempty"`
+
+	// IPAM holds the pools from which the addresses of the nodes are
+	// allocated
+	IPAM *NetworkIPAM `json:"ipam,omitempty" yaml:"ipam,omitempty"`
+}
+
+//nolint:tagliatelle
+type NetworkNode struct {
+	Name      string      `json:"name,omitempty" yaml:"name"`
+	Type      string      `json:"type,omitempty" yaml:"type,omitempty"`
+	Template  string      `json:"template,omitempty" yaml:"template,omitempty"`
+	ComputeId string      `json:"compute_id,omitempty" yaml:"compute_id,omitempty"`
+	X         *int        `json:"x,omitempty" yaml:"x,omitempty"`
+	Y         *int        `json:"y,omitempty" yaml:"y,omitempty"`
+	Z         int         `json:"z,omitempty" yaml:"z,omitempty"`
+	Config    *NodeConfig `json:"config,omitempty" yaml:"config,omitempty"`
+	CloudInit *CloudInit  `json:"cloudInit,omitempty" yaml:"cloudInit,omitempty"`
+
+	// ConsoleType, Properties and Label are applied to the node when they
+	// are given, the properties not listed are left as they are
+	ConsoleType string                 `json:"console_type,omitempty" yaml:"console_type,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Label       string                 `json:"label,omitempty" yaml:"label,omitempty"`
+
+	// DependsOn names the nodes that must be ready before this node is
+	// started, and Readiness how to tell that this node is ready
+	DependsOn []string   `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	Readiness *Readiness `json:"readiness,omitempty" yaml:"readiness,omitempty"`
+
+	// Count makes the node a group of nodes, named by formatting Name, as
+	// with fmt.Sprintf, with the numbers from Start, which defaults to 1.
+	// Each node is offset by DX and DY from the previous one, nodes without
+	// coordinates are laid out when the network is loaded.
+	Count int  `json:"count,omitempty" yaml:"count,omitempty"`
+	Start *int `json:"start,omitempty" yaml:"start,omitempty"`
+	DX    int  `json:"dx,omitempty" yaml:"dx,omitempty"`
+	DY    int  `json:"dy,omitempty" yaml:"dy,omitempty"`
+
+	// path locates the declaration of the node in the document
+	path []interface{}
+	// addresses are those allocated to the node from the IPAM pools
+	addresses []Address
+}
+
+// Position returns the coordinates of the node, with 0 for those not given.
+func (n *NetworkNode) Position() (int, int) {
+	x, y := 0, 0
+	if n.X != nil {
+		x = *n.X
+	}
+	if n.Y != nil {
+		y = *n.Y
+	}
+	return x, y
+}
+
+// Addresses returns the addresses allocated to the node from the IPAM
+// pools of its network, in the order of the pools.
+func (n *NetworkNode) Addresses() []Address {
+	return n.addresses
+}
+
+func (n *NetworkNode) yamlPath(index int) []interface{} {
+	if n.path != nil {
+		return n.path
+	}
+	return []interface{}{"spec", "nodes", index}
+}
+
+// Readiness describes the checks that a node has finished starting. All of
+// the checks given must pass before the timeout, which defaults to
+// DefaultReadinessTimeout.
+type Readiness struct {
+	// Status is the status the node must reach, by default started
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+	// Console is a regular expression matched against the output of the
+	// node's telnet console
+	Console string `json:"console,omitempty" yaml:"console,omitempty"`
+	// TCP is a host:port that must accept connections
+	TCP     string `json:"tcp,omitempty" yaml:"tcp,omitempty"`
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// NodeConfig is the day-0 configuration of a node, delivered when the node
+// is created, or once it has started, by a method suited to its type. The
+// configuration is the contents of File, Text, or Template rendered for the
+// node. A VPCS node may instead give its Name, Address, Netmask and Gateway.
+type NodeConfig struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+	Netmask string `json:"netmask,omitempty" yaml:"netmask,omitempty"`
+	Gateway string `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+
+	File     string `json:"file,omitempty" yaml:"file,omitempty"`
+	Text     string `json:"text,omitempty" yaml:"text,omitempty"`
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+
+	// Method is how the configuration is delivered, by default chosen by
+	// the type of node, and Path where it is written by the file and iso
+	// methods. Volume is the label of the disk built by the iso method.
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+	Volume string `json:"volume,omitempty" yaml:"volume,omitempty"`
+}
+
+// CloudInit is the first boot configuration of a QEMU node, given to the
+// node on a NoCloud disk attached as its CD-ROM. The user-data, meta-data
+// and network-config may be given as they are, or made from the simplified
+// fields, where Hostname defaults to the name of the node.
+//
+//nolint:tagliatelle
+type CloudInit struct {
+	UserData      string `json:"userData,omitempty" yaml:"userData,omitempty"`
+	MetaData      string `json:"metaData,omitempty" yaml:"metaData,omitempty"`
+	NetworkConfig string `json:"networkConfig,omitempty" yaml:"networkConfig,omitempty"`
+
+	Hostname          string               `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Users             []CloudInitUser      `json:"users,omitempty" yaml:"users,omitempty"`
+	SSHAuthorizedKeys []string             `json:"sshAuthorizedKeys,omitempty" yaml:"sshAuthorizedKeys,omitempty"`
+	Packages          []string             `json:"packages,omitempty" yaml:"packages,omitempty"`
+	RunCmd            []string             `json:"runcmd,omitempty" yaml:"runcmd,omitempty"`
+	Interfaces        []CloudInitInterface `json:"interfaces,omitempty" yaml:"interfaces,omitempty"`
+}
+
+// CloudInitUser is a user created at first boot. Sudo gives the user
+// passwordless sudo.
+//
+//nolint:tagliatelle
+type CloudInitUser struct {
+	Name              string   `json:"name" yaml:"name"`
+	Password          string   `json:"password,omitempty" yaml:"password,omitempty"`
+	SSHAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty" yaml:"sshAuthorizedKeys,omitempty"`
+	Sudo              bool     `json:"sudo,omitempty" yaml:"sudo,omitempty"`
+	Shell             string   `json:"shell,omitempty" yaml:"shell,omitempty"`
+}
+
+// CloudInitInterface configures an interface of the node, by DHCP or with
+// static addresses given in CIDR form.
+type CloudInitInterface struct {
+	Name        string   `json:"name" yaml:"name"`
+	DHCP        bool     `json:"dhcp,omitempty" yaml:"dhcp,omitempty"`
+	Addresses   []string `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+	Gateway     string   `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Nameservers []string `json:"nameservers,omitempty" yaml:"nameservers,omitempty"`
+}
+
+type NetworkLink struct {
+	AEnd LinkEnd `json:"aEnd,omitempty" yaml:"aEnd"`
+	ZEnd LinkEnd `json:"zEnd,omitempty" yaml:"zEnd"`
+
+	// path locates the declaration of the link, or of the generator that
+	// created it, in the document
+	path []interface{}
+}
+
+func (l *NetworkLink) yamlPath(index int) []interface{} {
+	if l.path != nil {
+		return l.path
+	}
+	return []interface{}{"spec", "links", index}
+}
+
+// LinkEnd is the end of a link on a node. The port is given by its adapter
+// and port numbers, either of which defaults to 0 if the other is given, or
+// by its interface name, as shown in the Name or ShortName of the node's
+// ports. If none of these are given a free port is picked when the link is
+// created.
+type LinkEnd struct {
+	Name      string `json:"name,omitempty" yaml:"name"`
+	Adapter   *int   `json:"adapter,omitempty" yaml:"adapter,omitempty"`
+	Port      *int   `json:"port,omitempty" yaml:"port,omitempty"`
+	Interface string `json:"interface,omitempty" yaml:"interface,omitempty"`
+}
+
+// FixedLinkEnd returns the link end for a port given by number.
+func FixedLinkEnd(name string, adapter, port int) LinkEnd {
+	return LinkEnd{Name: name, Adapter: &adapter, Port: &port}
+}
+
+// Fixed returns true if the port is given by number.
+func (e LinkEnd) Fixed() bool {
+	return e.Adapter != nil || e.Port != nil
+}
+
+// Numbers returns the adapter and port numbers of the port.
+func (e LinkEnd) Numbers() (int, int) {
+	adapter, port := 0, 0
+	if e.Adapter != nil {
+		adapter = *e.Adapter
+	}
+	if e.Port != nil {
+		port = *e.Port
+	}
+	return adapter, port
+}
+
+// String describes the end as "name adapter/port", "name interface" or
+// "name (auto)" when the port is yet to be picked.
+func (e LinkEnd) String() string {
+	switch {
+	case e.Fixed():
+		adapter, port := e.Numbers()
+		return fmt.Sprintf("%s %d/%d", e.Name, adapter, port)
+	case e.Interface != "":
+		return fmt.Sprintf("%s %s", e.Name, e.Interface)
+	}
+	return fmt.Sprintf("%s (auto)", e.Name)
+}
+
+// NetworkGenerator creates links between the nodes it names, which may be
+// node names, glob patterns or the name of a node group.
+type NetworkGenerator struct {
+	Type   string   `json:"type" yaml:"type"`
+	Nodes  []string `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	Hub    string   `json:"hub,omitempty" yaml:"hub,omitempty"`
+	Spines []string `json:"spines,omitempty" yaml:"spines,omitempty"`
+	Leaves []string `json:"leaves,omitempty" yaml:"leaves,omitempty"`
 }
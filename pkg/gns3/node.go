@@ -17,15 +17,18 @@ limitations under the License.
 package gns3
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
 const (
-	NodesPath = "v2/projects/%s/nodes"
-	NodePath  = "v2/projects/%s/nodes/%s"
+	NodesPath    = "v2/projects/%s/nodes"
+	NodePath     = "v2/projects/%s/nodes/%s"
+	NodeFilePath = "v2/projects/%s/nodes/%s/files/%s"
 )
 
 //nolint:tagliatelle
@@ -71,6 +74,21 @@ type Node struct {
 	Z                int                      `json:"z,omitempty" yaml:"z,omitempty"`
 }
 
+// NodeUpdate holds the attributes to change on an existing node, nil fields
+// are left unchanged. Only the properties given are changed, while the label
+// is replaced as a whole.
+//
+//nolint:tagliatelle
+type NodeUpdate struct {
+	Name        *string                `json:"name,omitempty"`
+	ConsoleType *string                `json:"console_type,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+	Label       map[string]interface{} `json:"label,omitempty"`
+	X           *int                   `json:"x,omitempty"`
+	Y           *int                   `json:"y,omitempty"`
+	Z           *int                   `json:"z,omitempty"`
+}
+
 type Nodes struct {
 	gns3      *Gns3
 	projectID string
@@ -81,8 +99,12 @@ func (g *Gns3) Nodes(id string) *Nodes {
 }
 
 func (n *Nodes) List() ([]*Node, error) {
+	return n.ListContext(context.Background())
+}
+
+func (n *Nodes) ListContext(ctx context.Context) ([]*Node, error) {
 	list := []*Node{}
-	err := n.gns3.Get(fmt.Sprintf(NodesPath, n.projectID), &list)
+	err := n.gns3.GetContext(ctx, fmt.Sprintf(NodesPath, n.projectID), &list)
 	if err != nil {
 		return nil, err
 	}
@@ -90,17 +112,21 @@ func (n *Nodes) List() ([]*Node, error) {
 }
 
 func (n *Nodes) Get(id string) (*Node, error) {
+	return n.GetContext(context.Background(), id)
+}
+
+func (n *Nodes) GetContext(ctx context.Context, id string) (*Node, error) {
 	_, err := uuid.Parse(id)
 	var node Node
 	if err == nil {
 		// Think this may be a UUID, so try to delete directly
-		err = n.gns3.Get(fmt.Sprintf(NodePath, n.projectID, id), &node)
+		err = n.gns3.GetContext(ctx, fmt.Sprintf(NodePath, n.projectID, id), &node)
 		if err == nil {
 			return &node, nil
 		}
 	}
 	// not a UUID, so get a list of projects and search based on name
-	list, err := n.List()
+	list, err := n.ListContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -113,8 +139,12 @@ func (n *Nodes) Get(id string) (*Node, error) {
 }
 
 func (n *Nodes) Create(node *Node) (*Node, error) {
+	return n.CreateContext(context.Background(), node)
+}
+
+func (n *Nodes) CreateContext(ctx context.Context, node *Node) (*Node, error) {
 	var out Node
-	err := n.gns3.Post(fmt.Sprintf(NodesPath, n.projectID), "application/json", node, &out)
+	err := n.gns3.PostContext(ConflictRetryable(ctx), fmt.Sprintf(NodesPath, n.projectID), "application/json", node, &out)
 	if err != nil {
 		return nil, err
 	}
@@ -123,9 +153,14 @@ func (n *Nodes) Create(node *Node) (*Node, error) {
 }
 
 func (n *Nodes) CreateUsingTemplate(node *Node, template *Template) (*Node, error) {
+	return n.CreateUsingTemplateContext(context.Background(), node, template)
+}
+
+func (n *Nodes) CreateUsingTemplateContext(ctx context.Context, node *Node, template *Template) (*Node, error) {
 	in := *node
 	in.NodeType = template.TemplateType
 	in.Symbol = template.Symbol
+	in.TemplateId = template.TemplateId
 	//in := Node{Name: name, ComputeId: template.ComputeId, NodeType: template.TemplateType}
 	if template.TemplateType == TemplateTypeQemu && template.Qemu != nil {
 		// fill the node properties
@@ -152,24 +187,199 @@ func (n *Nodes) CreateUsingTemplate(node *Node, template *Template) (*Node, erro
 		}
 	}
 
-	return n.Create(&in)
+	return n.CreateContext(ctx, &in)
 }
 
 func (n *Nodes) Start(id string) error {
-	no, err := n.Get(id)
+	return n.StartContext(context.Background(), id)
+}
+
+func (n *Nodes) StartContext(ctx context.Context, id string) error {
+	no, err := n.GetContext(ctx, id)
 	if err != nil {
 		return err
 	}
-	return n.gns3.Post(fmt.Sprintf(NodePath+"/start", n.projectID, no.NodeId), "application/json", nil, nil)
+	return n.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(NodePath+"/start", n.projectID, no.NodeId), "application/json", nil, nil)
 }
 
 func (n *Nodes) Stop(id string) error {
-	no, err := n.Get(id)
+	return n.StopContext(context.Background(), id)
+}
+
+func (n *Nodes) StopContext(ctx context.Context, id string) error {
+	no, err := n.GetContext(ctx, id)
 	if err != nil {
 		return err
 	}
 	var node Node
-	fmt.Printf(NodePath+"/stop\n", n.projectID, no.NodeId)
-	err = n.gns3.Post(fmt.Sprintf(NodePath+"/stop", n.projectID, no.NodeId), "application/json", nil, &node)
-	return err
+	return n.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(NodePath+"/stop", n.projectID, no.NodeId), "application/json", nil, &node)
+}
+
+func (n *Nodes) Suspend(id string) error {
+	return n.SuspendContext(context.Background(), id)
+}
+
+func (n *Nodes) SuspendContext(ctx context.Context, id string) error {
+	return n.action(ctx, id, "suspend")
+}
+
+// Reload restarts a node, stopping it if it is running.
+func (n *Nodes) Reload(id string) error {
+	return n.ReloadContext(context.Background(), id)
+}
+
+func (n *Nodes) ReloadContext(ctx context.Context, id string) error {
+	return n.action(ctx, id, "reload")
+}
+
+// Isolate suspends all of the links of a node, leaving it running.
+func (n *Nodes) Isolate(id string) error {
+	return n.IsolateContext(context.Background(), id)
+}
+
+func (n *Nodes) IsolateContext(ctx context.Context, id string) error {
+	return n.action(ctx, id, "isolate")
+}
+
+// Unisolate resumes all of the links of a node.
+func (n *Nodes) Unisolate(id string) error {
+	return n.UnisolateContext(context.Background(), id)
+}
+
+func (n *Nodes) UnisolateContext(ctx context.Context, id string) error {
+	return n.action(ctx, id, "unisolate")
+}
+
+// action posts to an endpoint of a node that changes its state. These are
+// safe to retry, as repeating them leaves the node in the same state.
+func (n *Nodes) action(ctx context.Context, id, action string) error {
+	no, err := n.GetContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	return n.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(NodePath+"/%s", n.projectID, no.NodeId, action), "application/json", nil, nil)
+}
+
+// StartAll starts every node of the project with a single request, which
+// the controller completes once all of the nodes have started.
+func (n *Nodes) StartAll() error {
+	return n.StartAllContext(context.Background())
+}
+
+func (n *Nodes) StartAllContext(ctx context.Context) error {
+	return n.allAction(ctx, "start")
+}
+
+func (n *Nodes) StopAll() error {
+	return n.StopAllContext(context.Background())
+}
+
+func (n *Nodes) StopAllContext(ctx context.Context) error {
+	return n.allAction(ctx, "stop")
+}
+
+func (n *Nodes) SuspendAll() error {
+	return n.SuspendAllContext(context.Background())
+}
+
+func (n *Nodes) SuspendAllContext(ctx context.Context) error {
+	return n.allAction(ctx, "suspend")
+}
+
+func (n *Nodes) ReloadAll() error {
+	return n.ReloadAllContext(context.Background())
+}
+
+func (n *Nodes) ReloadAllContext(ctx context.Context) error {
+	return n.allAction(ctx, "reload")
+}
+
+// allAction posts to an endpoint that changes the state of every node of
+// the project.
+func (n *Nodes) allAction(ctx context.Context, action string) error {
+	return n.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(NodesPath+"/%s", n.projectID, action), "application/json", nil, nil)
+}
+
+// Duplicate creates a copy of a node, including its disks and configuration,
+// at the given position. The server names the copy after the original.
+func (n *Nodes) Duplicate(id string, x, y, z int) (*Node, error) {
+	return n.DuplicateContext(context.Background(), id, x, y, z)
+}
+
+func (n *Nodes) DuplicateContext(ctx context.Context, id string, x, y, z int) (*Node, error) {
+	no, err := n.GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	position := map[string]int{"x": x, "y": y, "z": z}
+	var out Node
+	err = n.gns3.PostContext(ctx, fmt.Sprintf(NodePath+"/duplicate", n.projectID, no.NodeId), "application/json", position, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (n *Nodes) Update(id string, update *NodeUpdate) (*Node, error) {
+	return n.UpdateContext(context.Background(), id, update)
+}
+
+func (n *Nodes) UpdateContext(ctx context.Context, id string, update *NodeUpdate) (*Node, error) {
+	no, err := n.GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var out Node
+	err = n.gns3.PutContext(ctx, fmt.Sprintf(NodePath, n.projectID, no.NodeId), "application/json", update, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (n *Nodes) Delete(id string) (string, error) {
+	return n.DeleteContext(context.Background(), id)
+}
+
+func (n *Nodes) DeleteContext(ctx context.Context, id string) (string, error) {
+	no, err := n.GetContext(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return no.NodeId, n.gns3.DeleteContext(ctx, fmt.Sprintf(NodePath, n.projectID, no.NodeId))
+}
+
+// WriteFile writes a file in the directory of a node, given as a path
+// relative to it, through the controller. Writing the same file again
+// replaces it, so the request is safe to retry.
+func (n *Nodes) WriteFile(id, path string, data []byte) error {
+	return n.WriteFileContext(context.Background(), id, path, data)
+}
+
+func (n *Nodes) WriteFileContext(ctx context.Context, id, path string, data []byte) error {
+	no, err := n.GetContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	return n.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(NodeFilePath, n.projectID, no.NodeId, strings.TrimPrefix(path, "/")),
+		"application/octet-stream", data, nil)
+}
+
+// ReadFile reads a file in the directory of a node, given as a path
+// relative to it, through the controller.
+func (n *Nodes) ReadFile(id, path string) ([]byte, error) {
+	return n.ReadFileContext(context.Background(), id, path)
+}
+
+func (n *Nodes) ReadFileContext(ctx context.Context, id, path string) ([]byte, error) {
+	no, err := n.GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = n.gns3.GetContext(ctx, fmt.Sprintf(NodeFilePath, n.projectID, no.NodeId, strings.TrimPrefix(path, "/")), &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
 }
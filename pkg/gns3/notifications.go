@@ -0,0 +1,218 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	NotificationsPath        = "v2/notifications/ws"
+	ProjectNotificationsPath = "v2/projects/%s/notifications/ws"
+)
+
+// Actions of the notifications pushed by the controller
+const (
+	ActionPing           = "ping"
+	ActionNodeCreated    = "node.created"
+	ActionNodeUpdated    = "node.updated"
+	ActionNodeDeleted    = "node.deleted"
+	ActionLinkCreated    = "link.created"
+	ActionLinkUpdated    = "link.updated"
+	ActionLinkDeleted    = "link.deleted"
+	ActionComputeCreated = "compute.created"
+	ActionComputeUpdated = "compute.updated"
+	ActionComputeDeleted = "compute.deleted"
+	ActionProjectUpdated = "project.updated"
+	ActionProjectClosed  = "project.closed"
+	ActionLogError       = "log.error"
+	ActionLogWarning     = "log.warning"
+	ActionLogInfo        = "log.info"
+)
+
+// Notification is an event pushed by the controller. Event holds the event
+// as it was received and, depending on the kind of the action, one of the
+// typed fields holds it decoded.
+//
+//nolint:tagliatelle
+type Notification struct {
+	Action string          `json:"action" yaml:"action"`
+	Event  json.RawMessage `json:"event,omitempty" yaml:"-"`
+
+	// ProjectId is the project whose stream the notification came from, it
+	// is empty for the controller's stream
+	ProjectId string `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+
+	Node    *Node     `json:"-" yaml:"node,omitempty"`
+	Link    *Link     `json:"-" yaml:"link,omitempty"`
+	Compute *Compute  `json:"-" yaml:"compute,omitempty"`
+	Project *Project  `json:"-" yaml:"project,omitempty"`
+	Log     *LogEvent `json:"-" yaml:"log,omitempty"`
+	Ping    *Ping     `json:"-" yaml:"ping,omitempty"`
+}
+
+// LogEvent is the event of the log.error, log.warning and log.info actions.
+type LogEvent struct {
+	Message string `json:"message" yaml:"message"`
+}
+
+// Ping is the event sent periodically by the controller and computes.
+//
+//nolint:tagliatelle
+type Ping struct {
+	ComputeId          string  `json:"compute_id,omitempty" yaml:"compute_id,omitempty"`
+	CpuUsagePercent    float64 `json:"cpu_usage_percent" yaml:"cpu_usage_percent"`
+	MemoryUsagePercent float64 `json:"memory_usage_percent" yaml:"memory_usage_percent"`
+}
+
+// Kind returns the kind of object the notification is about, for example
+// node for node.updated.
+func (n *Notification) Kind() string {
+	kind, _, _ := strings.Cut(n.Action, ".")
+	return kind
+}
+
+// decodeEvent sets the typed field of the notification for its kind.
+// Events of other kinds are left in Event.
+func (n *Notification) decodeEvent() error {
+	var typed interface{}
+	switch n.Kind() {
+	case "node":
+		n.Node = &Node{}
+		typed = n.Node
+	case "link":
+		n.Link = &Link{}
+		typed = n.Link
+	case "compute":
+		n.Compute = &Compute{}
+		typed = n.Compute
+	case "project":
+		n.Project = &Project{}
+		typed = n.Project
+	case "log":
+		n.Log = &LogEvent{}
+		typed = n.Log
+	case ActionPing:
+		n.Ping = &Ping{}
+		typed = n.Ping
+	default:
+		return nil
+	}
+	if len(n.Event) == 0 {
+		return nil
+	}
+	return json.Unmarshal(n.Event, typed)
+}
+
+// NotificationStream delivers the notifications of the controller, and of
+// the projects it was opened for, on C. C is closed when the context the
+// stream was opened with is done or one of the websockets fails, after
+// which Err returns the failure.
+type NotificationStream struct {
+	C <-chan *Notification
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err returns the error that ended the stream, or nil if it ended because
+// its context is done.
+func (s *NotificationStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Notifications opens the controller's notification websocket, and that of
+// each of the given projects, and merges the notifications they push into a
+// single stream. Projects must be open for their notifications to be sent.
+func (g *Gns3) Notifications(ctx context.Context, projectIDs ...string) (*NotificationStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	conns := make([]*wsConn, 0, len(projectIDs)+1)
+	sources := append([]string{""}, projectIDs...)
+	for _, projectID := range sources {
+		path := NotificationsPath
+		if projectID != "" {
+			path = fmt.Sprintf(ProjectNotificationsPath, projectID)
+		}
+		conn, err := g.dialWebsocket(ctx, path)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+
+	ch := make(chan *Notification)
+	stream := &NotificationStream{C: ch}
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		go func(conn *wsConn, projectID string) {
+			defer wg.Done()
+			defer conn.Close()
+			err := readNotifications(ctx, conn, projectID, ch)
+			if err != nil && ctx.Err() == nil {
+				stream.mu.Lock()
+				if stream.err == nil {
+					stream.err = err
+				}
+				stream.mu.Unlock()
+			}
+			// the streams end together
+			cancel()
+		}(conn, sources[i])
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(ch)
+	}()
+	return stream, nil
+}
+
+// readNotifications decodes the messages of a websocket and sends them on
+// ch until ctx is done or the websocket fails.
+func readNotifications(ctx context.Context, conn *wsConn, projectID string, ch chan<- *Notification) error {
+	for {
+		message, err := conn.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("notification stream closed by the server: %w", ErrServerUnavailable)
+		} else if err != nil {
+			return fmt.Errorf("notification stream: %w", err)
+		}
+		var n Notification
+		if err := json.Unmarshal(message, &n); err != nil {
+			return fmt.Errorf("notification stream: decode: %w", err)
+		}
+		n.ProjectId = projectID
+		if err := n.decodeEvent(); err != nil {
+			return fmt.Errorf("notification stream: decode %s: %w", n.Action, err)
+		}
+		select {
+		case ch <- &n:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
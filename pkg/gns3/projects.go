@@ -17,7 +17,9 @@ limitations under the License.
 package gns3
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -27,6 +29,7 @@ const (
 	ProjectPath      = "v2/projects/%s"
 	ProjectOpenPath  = "v2/projects/%s/open"
 	ProjectClosePath = "v2/projects/%s/close"
+	ProjectFilePath  = "v2/projects/%s/files/%s"
 )
 
 //nolint:tagliatelle
@@ -59,8 +62,12 @@ func (g *Gns3) Projects() *Projects {
 }
 
 func (p *Projects) List() ([]Project, error) {
+	return p.ListContext(context.Background())
+}
+
+func (p *Projects) ListContext(ctx context.Context) ([]Project, error) {
 	list := []Project{}
-	err := p.gns3.Get(ProjectsPath, &list)
+	err := p.gns3.GetContext(ctx, ProjectsPath, &list)
 	if err != nil {
 		return nil, err
 	}
@@ -68,18 +75,22 @@ func (p *Projects) List() ([]Project, error) {
 }
 
 func (p *Projects) Get(id string) (*Project, error) {
+	return p.GetContext(context.Background(), id)
+}
+
+func (p *Projects) GetContext(ctx context.Context, id string) (*Project, error) {
 	_, err := uuid.Parse(id)
 	var project Project
 	if err == nil {
 		// Think this may be a UUID, so try to delete directly
-		err = p.gns3.Get(fmt.Sprintf("%s/%s", ProjectsPath, id), &project)
+		err = p.gns3.GetContext(ctx, fmt.Sprintf("%s/%s", ProjectsPath, id), &project)
 		if err == nil {
 			return &project, nil
 		}
 	}
 
 	// not a UUID, so get a list of projects and search based on name
-	list, err := p.List()
+	list, err := p.ListContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +103,12 @@ func (p *Projects) Get(id string) (*Project, error) {
 }
 
 func (p *Projects) Create(project *Project) (*Project, error) {
+	return p.CreateContext(context.Background(), project)
+}
+
+func (p *Projects) CreateContext(ctx context.Context, project *Project) (*Project, error) {
 	var out Project
-	err := p.gns3.Post(ProjectsPath, "application/json", project, &out)
+	err := p.gns3.PostContext(ConflictRetryable(ctx), ProjectsPath, "application/json", project, &out)
 	if err != nil {
 		return nil, err
 	}
@@ -102,25 +117,72 @@ func (p *Projects) Create(project *Project) (*Project, error) {
 }
 
 func (p *Projects) Delete(id string) (string, error) {
-	project, err := p.Get(id)
+	return p.DeleteContext(context.Background(), id)
+}
+
+func (p *Projects) DeleteContext(ctx context.Context, id string) (string, error) {
+	project, err := p.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return project.ProjectId, p.gns3.Delete(fmt.Sprintf(ProjectPath, project.ProjectId))
+	return project.ProjectId, p.gns3.DeleteContext(ctx, fmt.Sprintf(ProjectPath, project.ProjectId))
 }
 
 func (p *Projects) Close(id string) (string, error) {
-	project, err := p.Get(id)
+	return p.CloseContext(context.Background(), id)
+}
+
+func (p *Projects) CloseContext(ctx context.Context, id string) (string, error) {
+	project, err := p.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return project.ProjectId, p.gns3.Post(fmt.Sprintf(ProjectClosePath, project.ProjectId), "", nil, nil)
+	return project.ProjectId, p.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(ProjectClosePath, project.ProjectId), "", nil, nil)
 }
 
 func (p *Projects) Open(id string) error {
-	project, err := p.Get(id)
+	return p.OpenContext(context.Background(), id)
+}
+
+func (p *Projects) OpenContext(ctx context.Context, id string) error {
+	project, err := p.GetContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	return p.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(ProjectOpenPath, project.ProjectId), "", nil, nil)
+}
+
+// ReadFile reads a file in the directory of a project, given as a path
+// relative to it, through the controller.
+func (p *Projects) ReadFile(id, path string) ([]byte, error) {
+	return p.ReadFileContext(context.Background(), id, path)
+}
+
+func (p *Projects) ReadFileContext(ctx context.Context, id, path string) ([]byte, error) {
+	project, err := p.GetContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = p.gns3.GetContext(ctx, fmt.Sprintf(ProjectFilePath, project.ProjectId, strings.TrimPrefix(path, "/")), &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteFile writes a file in the directory of a project, given as a path
+// relative to it, through the controller, replacing any file of the same
+// name.
+func (p *Projects) WriteFile(id, path string, data []byte) error {
+	return p.WriteFileContext(context.Background(), id, path, data)
+}
+
+func (p *Projects) WriteFileContext(ctx context.Context, id, path string, data []byte) error {
+	project, err := p.GetContext(ctx, id)
 	if err != nil {
 		return err
 	}
-	return p.gns3.Post(fmt.Sprintf(ProjectOpenPath, project.ProjectId), "", nil, nil)
+	return p.gns3.PostContext(Idempotent(ctx), fmt.Sprintf(ProjectFilePath, project.ProjectId, strings.TrimPrefix(path, "/")),
+		"application/octet-stream", data, nil)
 }
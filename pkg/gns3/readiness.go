@@ -0,0 +1,339 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultReadinessTimeout = 5 * time.Minute
+	DefaultReadinessStatus  = "started"
+)
+
+const (
+	// readinessPoll is how long to wait before repeating a check that failed
+	readinessPoll = 2 * time.Second
+	// consoleHistory is how much console output is kept to match against
+	consoleHistory = 64 * 1024
+)
+
+// TimeoutDuration returns the time allowed for the checks to pass.
+func (r *Readiness) TimeoutDuration() time.Duration {
+	if r == nil || r.Timeout == "" {
+		return DefaultReadinessTimeout
+	}
+	d, err := time.ParseDuration(r.Timeout)
+	if err != nil || d <= 0 {
+		return DefaultReadinessTimeout
+	}
+	return d
+}
+
+// check returns the key and a description of the first problem with the
+// readiness checks, or empty strings if there is none.
+func (r *Readiness) check() (string, string) {
+	if r.Console != "" {
+		if _, err := regexp.Compile(r.Console); err != nil {
+			return "console", fmt.Sprintf("invalid console pattern: %v", err)
+		}
+	}
+	if r.TCP != "" {
+		if _, port, err := net.SplitHostPort(r.TCP); err != nil || port == "" {
+			return "tcp", fmt.Sprintf("tcp must be given as host:port, not '%s'", r.TCP)
+		}
+	}
+	if r.Timeout != "" {
+		if d, err := time.ParseDuration(r.Timeout); err != nil || d <= 0 {
+			return "timeout", fmt.Sprintf("invalid timeout '%s', expected a duration such as 90s or 5m", r.Timeout)
+		}
+	}
+	return "", ""
+}
+
+// StartOrder returns the nodes of the network in the order they can be
+// started. Each wave holds the nodes whose dependencies are all in earlier
+// waves, in the order they are declared, so the nodes of a wave can be
+// started together.
+func (n *Network) StartOrder() ([][]*NetworkNode, error) {
+	waiting := make(map[string]*NetworkNode, len(n.Spec.Nodes))
+	for i := range n.Spec.Nodes {
+		waiting[n.Spec.Nodes[i].Name] = &n.Spec.Nodes[i]
+	}
+	var waves [][]*NetworkNode
+	for len(waiting) > 0 {
+		var wave []*NetworkNode
+		for i := range n.Spec.Nodes {
+			node := &n.Spec.Nodes[i]
+			if waiting[node.Name] == nil {
+				continue
+			}
+			ready := true
+			for _, dep := range node.DependsOn {
+				if waiting[dep] != nil {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, node)
+			}
+		}
+		if len(wave) == 0 {
+			names := make([]string, 0, len(waiting))
+			for i := range n.Spec.Nodes {
+				if name := n.Spec.Nodes[i].Name; waiting[name] != nil {
+					names = append(names, name)
+				}
+			}
+			return nil, &NetworkError{Message: fmt.Sprintf("dependency cycle between nodes %s", strings.Join(names, ", "))}
+		}
+		for _, node := range wave {
+			delete(waiting, node.Name)
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+// ConsoleAddress returns the address of the console of a node. Consoles
+// bound to all addresses are reached through the controller's host.
+func (g *Gns3) ConsoleAddress(node *Node) string {
+	host := node.ConsoleHost
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if u, err := url.Parse(g.baseURL); err == nil {
+			host = u.Hostname()
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(node.Console))
+}
+
+func (n *Nodes) WaitReady(id string, r *Readiness) error {
+	return n.WaitReadyContext(context.Background(), id, r)
+}
+
+// WaitReadyContext waits for a node to pass its readiness checks: its
+// status, then its console output and then the TCP port, each being
+// repeated until it passes or the timeout expires. A nil Readiness waits
+// for the node to be started. A console check of a node whose console is
+// not telnet fails at once with ErrUnsupportedConsole.
+func (n *Nodes) WaitReadyContext(ctx context.Context, id string, r *Readiness) error {
+	if r == nil {
+		r = &Readiness{}
+	}
+	if _, msg := r.check(); msg != "" {
+		return &NetworkError{Message: msg}
+	}
+	timeout := r.TimeoutDuration()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	node, err := n.GetContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	notReady := func(reason string) error {
+		return fmt.Errorf("node '%s' not ready after %s, %s: %w", node.Name, timeout, reason, ErrNotReady)
+	}
+	if r.Console != "" && (node.ConsoleType != "telnet" || node.Console == 0) {
+		return fmt.Errorf("node '%s' has a %s console rather than telnet to match readiness.console: %w",
+			node.Name, node.ConsoleType, ErrUnsupportedConsole)
+	}
+
+	status := r.Status
+	if status == "" {
+		status = DefaultReadinessStatus
+	}
+	for node.Status != status {
+		if sleep(ctx, readinessPoll) != nil {
+			return notReady(fmt.Sprintf("status is %s rather than %s", node.Status, status))
+		}
+		next, err := n.GetContext(ctx, node.NodeId)
+		if err != nil && ctx.Err() == nil {
+			return err
+		} else if err == nil {
+			node = next
+		}
+	}
+
+	if r.Console != "" {
+		re := regexp.MustCompile(r.Console)
+		if !n.waitConsole(ctx, node, re) {
+			return notReady(fmt.Sprintf("console did not match '%s'", r.Console))
+		}
+	}
+
+	if r.TCP != "" {
+		var dialer net.Dialer
+		for {
+			conn, err := dialer.DialContext(ctx, "tcp", r.TCP)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if sleep(ctx, readinessPoll) != nil {
+				return notReady(fmt.Sprintf("%s is not reachable", r.TCP))
+			}
+		}
+	}
+	return nil
+}
+
+func (n *Nodes) WaitStatus(status string, ids ...string) ([]*Node, error) {
+	return n.WaitStatusContext(context.Background(), status, ids...)
+}
+
+// WaitStatusContext lists the nodes of the project, repeating until every
+// node given by ID or name, or every node if none are given, has the
+// status. The nodes are listed again as soon as the project's notification
+// stream reports a change to a node, or every few seconds if the stream
+// cannot be opened. The nodes are returned in the order given, or the
+// order listed. If ctx is done first the error wraps ErrNotReady and the
+// nodes that do not have the status are returned with it.
+func (n *Nodes) WaitStatusContext(ctx context.Context, status string, ids ...string) ([]*Node, error) {
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var changes <-chan *Notification
+	if stream, err := n.gns3.Notifications(sctx, n.projectID); err == nil {
+		changes = stream.C
+	}
+
+	var pending []*Node
+	total := len(ids)
+	for {
+		list, err := n.ListContext(ctx)
+		if err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+		if err == nil {
+			nodes, err := selectNodes(list, ids)
+			if err != nil {
+				return nil, err
+			}
+			total, pending = len(nodes), nil
+			for _, node := range nodes {
+				if node.Status != status {
+					pending = append(pending, node)
+				}
+			}
+			if len(pending) == 0 {
+				return nodes, nil
+			}
+		}
+		if waitChange(ctx, changes) != nil {
+			return pending, fmt.Errorf("%d of %d nodes are not %s: %w", len(pending), total, status, ErrNotReady)
+		}
+	}
+}
+
+// waitChange waits for a node notification on changes, or readinessPoll if
+// none arrives, returning early with the error of ctx if it is done.
+func waitChange(ctx context.Context, changes <-chan *Notification) error {
+	timer := time.NewTimer(readinessPoll)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case note, ok := <-changes:
+			if !ok {
+				// the stream failed, fall back to polling
+				changes = nil
+			} else if note.Kind() == "node" {
+				return nil
+			}
+		}
+	}
+}
+
+// selectNodes returns the nodes of list given by ID or name, in the order
+// given, or all of list if ids is empty.
+func selectNodes(list []*Node, ids []string) ([]*Node, error) {
+	if len(ids) == 0 {
+		return list, nil
+	}
+	nodes := make([]*Node, len(ids))
+	for i, id := range ids {
+		for _, node := range list {
+			if node.NodeId == id || node.Name == id {
+				nodes[i] = node
+				break
+			}
+		}
+		if nodes[i] == nil {
+			return nil, fmt.Errorf("node '%s': %w", id, ErrNotFound)
+		}
+	}
+	return nodes, nil
+}
+
+// waitConsole connects to the telnet console of a node, reconnecting as
+// needed, until its output matches re. A new line is sent whenever the
+// console is idle, to prompt the node for output.
+func (n *Nodes) waitConsole(ctx context.Context, node *Node, re *regexp.Regexp) bool {
+	var output []byte
+	buf := make([]byte, 4096)
+	for {
+		console, err := n.gns3.DialConsole(ctx, node)
+		if err == nil {
+			_, err = console.Write([]byte("\r\n"))
+			for err == nil && ctx.Err() == nil {
+				_ = console.SetReadDeadline(time.Now().Add(readinessPoll))
+				var count int
+				count, err = console.Read(buf)
+				output = append(output, buf[:count]...)
+				if len(output) > consoleHistory {
+					output = output[len(output)-consoleHistory:]
+				}
+				if re.Match(output) {
+					console.Close()
+					return true
+				}
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					_, err = console.Write([]byte("\r\n"))
+				}
+			}
+			console.Close()
+		}
+		if sleep(ctx, readinessPoll) != nil {
+			return false
+		}
+	}
+}
+
+// sleep waits for d, returning early with the error of ctx if it is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+	"github.com/ciena/gns3ctl/pkg/gns3/gns3test"
+)
+
+func TestReadinessConsoleValidation(t *testing.T) {
+	for _, tc := range []struct {
+		consoleType string
+		want        string
+	}{
+		{consoleType: "", want: ""},
+		{consoleType: "telnet", want: ""},
+		{consoleType: "vnc", want: "readiness.console requires a telnet console, not vnc"},
+		{consoleType: "none", want: "readiness.console requires a telnet console, not none"},
+	} {
+		doc := `apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - name: r1
+      type: qemu
+      console_type: ` + tc.consoleType + `
+      readiness:
+        console: "login:"
+`
+		_, err := gns3.ParseNetwork(strings.NewReader(doc))
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", tc.consoleType, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("%q: got %v, want %q", tc.consoleType, err, tc.want)
+		}
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	s := gns3test.NewServer()
+	defer s.Close()
+	ctl := connect(t, s)
+	project, err := ctl.Projects().Create(&gns3.Project{Name: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := ctl.Nodes(project.ProjectId)
+	for _, node := range []*gns3.Node{
+		{Name: "pc1", NodeType: "vpcs", ComputeId: "local"},
+		{Name: "r1", NodeType: "qemu", ComputeId: "local", ConsoleType: "vnc"},
+	} {
+		if _, err := nodes.Create(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := nodes.Start("pc1"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := nodes.WaitReadyContext(ctx, "pc1", nil); err != nil {
+		t.Errorf("started node: %v", err)
+	}
+
+	err = nodes.WaitReadyContext(ctx, "r1", &gns3.Readiness{Status: "stopped", Console: "login:"})
+	if !errors.Is(err, gns3.ErrUnsupportedConsole) {
+		t.Errorf("vnc console: got %v, want ErrUnsupportedConsole", err)
+	}
+
+	err = nodes.WaitReadyContext(ctx, "r1", &gns3.Readiness{Timeout: "10ms"})
+	if !errors.Is(err, gns3.ErrNotReady) {
+		t.Errorf("stopped node: got %v, want ErrNotReady", err)
+	}
+
+	err = nodes.WaitReadyContext(ctx, "pc1", &gns3.Readiness{Console: "("})
+	var netErr *gns3.NetworkError
+	if !errors.As(err, &netErr) {
+		t.Errorf("invalid pattern: got %v, want a NetworkError", err)
+	}
+}
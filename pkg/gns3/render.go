@@ -0,0 +1,398 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrRequiredValue = errors.New("required value")
+
+// Values are the variables available to a network document as .Values when
+// it is rendered.
+type Values map[string]interface{}
+
+// ReadValues reads a YAML mapping of values.
+func ReadValues(r io.Reader) (Values, error) {
+	// decode as a plain map so that nested mappings are plain maps too
+	values := map[string]interface{}{}
+	if err := yaml.NewDecoder(r).Decode(&values); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Merge copies other into the values, merging nested mappings so that the
+// values in other take precedence.
+func (v Values) Merge(other Values) {
+	for key, value := range other {
+		if src, ok := value.(map[string]interface{}); ok {
+			if dst, ok := v[key].(map[string]interface{}); ok {
+				Values(dst).Merge(src)
+				continue
+			}
+		}
+		v[key] = value
+	}
+}
+
+// Set applies a key=value override, where the key is a dotted path into
+// nested mappings. Integers and booleans written in their usual form, such
+// as 7 or true, are converted, other values, including 07, are kept as
+// strings.
+func (v Values) Set(expr string) error {
+	return v.set(expr, false)
+}
+
+// SetString applies a key=value override as Set does, but always keeps the
+// value as a string.
+func (v Values) SetString(expr string) error {
+	return v.set(expr, true)
+}
+
+func (v Values) set(expr string, asString bool) error {
+	key, raw, ok := strings.Cut(expr, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid value '%s', expected key=value", expr)
+	}
+	var value interface{} = raw
+	if !asString {
+		if i, err := strconv.Atoi(raw); err == nil && strconv.Itoa(i) == raw {
+			value = i
+		} else if raw == "true" || raw == "false" {
+			value = raw == "true"
+		}
+	}
+	parts := strings.Split(key, ".")
+	m := v
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+	return nil
+}
+
+// RenderNetwork executes a network document as a Go text/template, with the
+// values available as .Values and the helpers from TemplateFuncs.
+func RenderNetwork(name string, text []byte, values Values) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(TemplateFuncs()).Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = Values{}
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, map[string]interface{}{"Values": map[string]interface{}(values)}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// TemplateFuncs returns the helpers available when rendering a network
+// document. They follow the names and argument order of the sprig library,
+// so the value being operated on is last and can be piped.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"default":  defaultValue,
+		"required": required,
+		"empty":    empty,
+		"ternary": func(a, b interface{}, cond bool) interface{} {
+			if cond {
+				return a
+			}
+			return b
+		},
+
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"quote":      func(v interface{}) string { return strconv.Quote(fmt.Sprint(v)) },
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"toString":   func(v interface{}) string { return fmt.Sprint(v) },
+		"toYaml":     toYaml,
+		"toJson":     toJson,
+
+		"add":   func(a, b interface{}) int { return toInt(a) + toInt(b) },
+		"sub":   func(a, b interface{}) int { return toInt(a) - toInt(b) },
+		"mul":   func(a, b interface{}) int { return toInt(a) * toInt(b) },
+		"div":   func(a, b interface{}) int { return toInt(a) / toInt(b) },
+		"mod":   func(a, b interface{}) int { return toInt(a) % toInt(b) },
+		"atoi":  toInt,
+		"until": until,
+		"seq":   seq,
+		"list":  func(items ...interface{}) []interface{} { return items },
+		"dict":  dict,
+
+		"ipAdd":       ipAdd,
+		"cidrHost":    cidrHost,
+		"cidrSubnet":  cidrSubnet,
+		"cidrNetmask": cidrNetmask,
+		"cidrPrefix":  cidrPrefix,
+	}
+}
+
+// defaultValue returns value, or def if value is empty.
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || empty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+func required(msg string, value interface{}) (interface{}, error) {
+	if empty(value) {
+		return nil, fmt.Errorf("%w: %s", ErrRequiredValue, msg)
+	}
+	return value, nil
+}
+
+// empty returns true for nil and for zero values.
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func join(sep string, items interface{}) string {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Sprint(items)
+	}
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep)
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func toYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func toJson(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// toInt converts numbers, and strings holding numbers, to an int. Anything
+// else is 0.
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case uint64:
+		return int(n)
+	case float64:
+		return int(n)
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	return 0
+}
+
+// until returns the integers from 0 up to, but not including, count.
+func until(count interface{}) []int {
+	n := toInt(count)
+	list := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		list = append(list, i)
+	}
+	return list
+}
+
+// seq returns the integers from first to last inclusive.
+func seq(first, last interface{}) []int {
+	var list []int
+	for i := toInt(first); i <= toInt(last); i++ {
+		list = append(list, i)
+	}
+	return list
+}
+
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict requires key value pairs")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		m[fmt.Sprint(pairs[i])] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// addToAddr returns the address n addresses after addr, or before it if n
+// is negative.
+func addToAddr(addr netip.Addr, n int) (netip.Addr, error) {
+	bytes := addr.As16()
+	carry := n
+	for i := len(bytes) - 1; i >= 0 && carry != 0; i-- {
+		sum := int(bytes[i]) + carry
+		bytes[i] = byte(sum)
+		carry = (sum - int(bytes[i])) / 256
+	}
+	next := netip.AddrFrom16(bytes)
+	if carry != 0 || (addr.Is4() && !next.Is4In6()) {
+		return netip.Addr{}, fmt.Errorf("%s %+d is out of range", addr, n)
+	}
+	if addr.Is4() {
+		next = next.Unmap()
+	}
+	return next, nil
+}
+
+// ipAdd returns the IP address offset by n.
+func ipAdd(n interface{}, ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", err
+	}
+	next, err := addToAddr(addr, toInt(n))
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
+
+// cidrHost returns the nth address of the prefix, counting back from the
+// end of the prefix if n is negative.
+func cidrHost(n interface{}, cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+	prefix = prefix.Masked()
+	host := toInt(n)
+	if host < 0 {
+		bits := prefix.Addr().BitLen() - prefix.Bits()
+		if bits >= 31 {
+			return "", fmt.Errorf("prefix %s is too large to count back from its end", cidr)
+		}
+		host += 1 << bits
+	}
+	addr, err := addToAddr(prefix.Addr(), host)
+	if err != nil {
+		return "", err
+	}
+	if !prefix.Contains(addr) {
+		return "", fmt.Errorf("prefix %s has no host number %d", cidr, toInt(n))
+	}
+	return addr.String(), nil
+}
+
+// cidrSubnet returns the num'th subnet of the prefix that is newbits longer.
+func cidrSubnet(newbits, num interface{}, cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+	prefix = prefix.Masked()
+	extend := toInt(newbits)
+	length := prefix.Bits() + extend
+	if extend < 0 || length > prefix.Addr().BitLen() {
+		return "", fmt.Errorf("prefix %s cannot be extended by %d bits", cidr, extend)
+	}
+	index := toInt(num)
+	if index < 0 || (extend < strconv.IntSize-1 && index >= 1<<extend) {
+		return "", fmt.Errorf("prefix %s has no subnet number %d", cidr, index)
+	}
+	// The subnet lies within the prefix, but its offset may not fit an int
+	offset := new(big.Int).Lsh(big.NewInt(int64(index)), uint(prefix.Addr().BitLen()-length))
+	base := prefix.Addr().As16()
+	var sum [16]byte
+	new(big.Int).Add(new(big.Int).SetBytes(base[:]), offset).FillBytes(sum[:])
+	addr := netip.AddrFrom16(sum)
+	if prefix.Addr().Is4() {
+		addr = addr.Unmap()
+	}
+	return netip.PrefixFrom(addr, length).String(), nil
+}
+
+// cidrNetmask returns the dotted netmask of an IPv4 prefix.
+func cidrNetmask(cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+	if !prefix.Addr().Is4() {
+		return "", fmt.Errorf("prefix %s is not IPv4", cidr)
+	}
+	mask := uint32(0xffffffff) << (32 - prefix.Bits())
+	if prefix.Bits() == 0 {
+		mask = 0
+	}
+	return netip.AddrFrom4([4]byte{byte(mask >> 24), byte(mask >> 16), byte(mask >> 8), byte(mask)}).String(), nil
+}
+
+// cidrPrefix returns the length of the prefix.
+func cidrPrefix(cidr string) (int, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return 0, err
+	}
+	return prefix.Bits(), nil
+}
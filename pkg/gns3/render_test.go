@@ -0,0 +1,125 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValuesSet(t *testing.T) {
+	for _, tc := range []struct {
+		expr     string
+		asString bool
+		want     Values
+	}{
+		{expr: "pod=4", want: Values{"pod": 4}},
+		{expr: "pod=-4", want: Values{"pod": -4}},
+		{expr: "id=07", want: Values{"id": "07"}},
+		{expr: "id=+7", want: Values{"id": "+7"}},
+		{expr: "id=7", asString: true, want: Values{"id": "7"}},
+		{expr: "on=true", want: Values{"on": true}},
+		{expr: "on=false", want: Values{"on": false}},
+		{expr: "on=TRUE", want: Values{"on": "TRUE"}},
+		{expr: "on=true", asString: true, want: Values{"on": "true"}},
+		{expr: "name=", want: Values{"name": ""}},
+		{expr: "a.b.c=x=y", want: Values{"a": map[string]interface{}{"b": map[string]interface{}{"c": "x=y"}}}},
+	} {
+		v := Values{}
+		set := v.Set
+		if tc.asString {
+			set = v.SetString
+		}
+		if err := set(tc.expr); err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("%s: got %#v, want %#v", tc.expr, v, tc.want)
+		}
+	}
+
+	for _, expr := range []string{"pod", "=4"} {
+		if err := (Values{}).Set(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestValuesMerge(t *testing.T) {
+	v := Values{"a": map[string]interface{}{"x": 1, "y": 2}, "b": 1}
+	v.Merge(Values{"a": map[string]interface{}{"y": 3}, "c": 4})
+	want := Values{"a": map[string]interface{}{"x": 1, "y": 3}, "b": 1, "c": 4}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got %#v, want %#v", v, want)
+	}
+}
+
+func TestCidrSubnet(t *testing.T) {
+	for _, tc := range []struct {
+		newbits, num int
+		cidr         string
+		want         string
+		wantErr      bool
+	}{
+		{newbits: 8, num: 4, cidr: "10.0.0.0/16", want: "10.0.4.0/24"},
+		{newbits: 8, num: 255, cidr: "10.0.0.0/16", want: "10.0.255.0/24"},
+		{newbits: 0, num: 0, cidr: "10.1.2.3/16", want: "10.1.0.0/16"},
+		{newbits: 16, num: 1, cidr: "2001:db8::/32", want: "2001:db8:1::/48"},
+		{newbits: 96, num: 1, cidr: "2001:db8::/32", want: "2001:db8::1/128"},
+		{newbits: 8, num: 256, cidr: "10.0.0.0/16", wantErr: true},
+		{newbits: 8, num: -1, cidr: "10.0.0.0/16", wantErr: true},
+		{newbits: 17, num: 0, cidr: "10.0.0.0/16", wantErr: true},
+		{newbits: -1, num: 0, cidr: "10.0.0.0/16", wantErr: true},
+		{newbits: 100, num: 0, cidr: "2001:db8::/32", wantErr: true},
+		{newbits: 64, num: 1, cidr: "::/0", want: "0:0:0:1::/64"},
+		{newbits: 128, num: 1 << 40, cidr: "::/0", want: "::100:0:0/128"},
+		{newbits: 8, num: 0, cidr: "not a prefix", wantErr: true},
+	} {
+		got, err := cidrSubnet(tc.newbits, tc.num, tc.cidr)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%d %d %s: got %s, want an error", tc.newbits, tc.num, tc.cidr, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%d %d %s: got %s, %v, want %s", tc.newbits, tc.num, tc.cidr, got, err, tc.want)
+		}
+	}
+}
+
+func TestRenderNetwork(t *testing.T) {
+	text := `address: {{ cidrSubnet 8 .Values.pod "10.0.0.0/16" | cidrHost 10 }}
+id: {{ .Values.id | quote }}
+count: {{ add .Values.id 1 }}
+`
+	values := Values{}
+	for _, expr := range []string{"pod=4", "id=07"} {
+		if err := values.Set(expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := RenderNetwork("test", []byte(text), values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "address: 10.0.4.10\nid: \"07\"\ncount: 8\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
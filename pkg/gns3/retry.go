@@ -0,0 +1,182 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides if, and after how long, a failed request is sent
+// again. attempt is the number of attempts already made, resp is nil when
+// err is not nil.
+type RetryPolicy interface {
+	Retry(ctx context.Context, attempt int, req *http.Request, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// BackoffPolicy is a RetryPolicy with exponential backoff and jitter.
+//
+// A response whose status is in RetryableStatus is retried when the request
+// method is in RetryableMethods or the request context was marked with
+// Idempotent. A response whose status is in UnprocessedStatus is retried
+// regardless of method as the server reports it did not act on the request,
+// as is a 409 Conflict to a request whose context was marked with
+// ConflictRetryable.
+// Connection errors are retried for idempotent requests and, for any
+// request, when the connection could not be established.
+type BackoffPolicy struct {
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	Multiplier        float64
+	Jitter            float64
+	RetryableStatus   []int
+	UnprocessedStatus []int
+	RetryableMethods  []string
+}
+
+// DefaultRetryPolicy returns a BackoffPolicy suitable for a GNS3 controller
+// that is busy starting nodes.
+func DefaultRetryPolicy() *BackoffPolicy {
+	return &BackoffPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatus: []int{
+			http.StatusConflict,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		UnprocessedStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusServiceUnavailable,
+		},
+		RetryableMethods: []string{
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodOptions,
+			http.MethodPut,
+			http.MethodDelete,
+		},
+	}
+}
+
+type idempotentKey struct{}
+
+// Idempotent returns a copy of ctx that marks requests made with it as safe
+// to retry whatever their HTTP method.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context) bool {
+	v, _ := ctx.Value(idempotentKey{}).(bool)
+	return v
+}
+
+type conflictRetryableKey struct{}
+
+// ConflictRetryable returns a copy of ctx that marks requests made with it,
+// such as those creating objects, as not acted on by the server when it
+// answers 409 Conflict, so they are retried. The controller answers 409
+// while it is busy with the project; a request that is retried only after
+// a response was received cannot have created the object twice.
+func ConflictRetryable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, conflictRetryableKey{}, true)
+}
+
+func isConflictRetryable(ctx context.Context) bool {
+	v, _ := ctx.Value(conflictRetryableKey{}).(bool)
+	return v
+}
+
+func (p *BackoffPolicy) Retry(ctx context.Context, attempt int, req *http.Request,
+	resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	idempotent := isIdempotent(ctx) || containsString(p.RetryableMethods, req.Method)
+	if err != nil {
+		var opErr *net.OpError
+		if idempotent || (errors.As(err, &opErr) && opErr.Op == "dial") {
+			return p.backoff(attempt, nil), true
+		}
+		return 0, false
+	}
+
+	if containsInt(p.UnprocessedStatus, resp.StatusCode) ||
+		(idempotent && containsInt(p.RetryableStatus, resp.StatusCode)) ||
+		(resp.StatusCode == http.StatusConflict && isConflictRetryable(ctx)) {
+		return p.backoff(attempt, resp), true
+	}
+	return 0, false
+}
+
+func (p *BackoffPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		//nolint:gosec
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	// Honor the server asking us to wait longer
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil &&
+			float64(secs)*float64(time.Second) > d {
+			d = float64(secs) * float64(time.Second)
+		}
+	}
+
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return time.Duration(d)
+}
+
+func containsInt(list []int, val int) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffPolicyRetry(t *testing.T) {
+	p := DefaultRetryPolicy()
+	p.Jitter = 0
+	dial := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	read := &net.OpError{Op: "read", Err: errors.New("connection reset")}
+
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		attempt int
+		status  int
+		err     error
+		want    bool
+	}{
+		{name: "get unavailable", method: http.MethodGet, status: http.StatusServiceUnavailable, want: true},
+		{name: "get conflict", method: http.MethodGet, status: http.StatusConflict, want: true},
+		{name: "get not found", method: http.MethodGet, status: http.StatusNotFound},
+		{name: "last attempt", method: http.MethodGet, attempt: 3, status: http.StatusServiceUnavailable},
+		{name: "post server error", method: http.MethodPost, status: http.StatusInternalServerError},
+		{name: "post too many requests", method: http.MethodPost, status: http.StatusTooManyRequests, want: true},
+		{name: "post conflict", method: http.MethodPost, status: http.StatusConflict},
+		{
+			name: "post conflict retryable", ctx: ConflictRetryable(context.Background()),
+			method: http.MethodPost, status: http.StatusConflict, want: true,
+		},
+		{
+			name: "post idempotent", ctx: Idempotent(context.Background()),
+			method: http.MethodPost, status: http.StatusInternalServerError, want: true,
+		},
+		{name: "post dial error", method: http.MethodPost, err: dial, want: true},
+		{name: "post read error", method: http.MethodPost, err: read},
+		{name: "get read error", method: http.MethodGet, err: read, want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			attempt := tc.attempt
+			if attempt == 0 {
+				attempt = 1
+			}
+			req, _ := http.NewRequestWithContext(ctx, tc.method, "http://gns3/v2/projects", nil)
+			var resp *http.Response
+			if tc.err == nil {
+				resp = &http.Response{StatusCode: tc.status, Header: http.Header{}}
+			}
+			wait, got := p.Retry(ctx, attempt, req, resp, tc.err)
+			if got != tc.want {
+				t.Fatalf("got retry %v, want %v", got, tc.want)
+			}
+			if got && wait != p.InitialBackoff*time.Duration(1<<(attempt-1)) {
+				t.Errorf("got wait %v", wait)
+			}
+		})
+	}
+}
+
+func TestBackoffPolicyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://gns3/v2/projects", nil)
+	if _, ok := DefaultRetryPolicy().Retry(ctx, 1, req, nil, context.Canceled); ok {
+		t.Error("retried a canceled request")
+	}
+}
+
+func TestBackoffPolicyBackoff(t *testing.T) {
+	p := &BackoffPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+	for _, tc := range []struct {
+		attempt    int
+		retryAfter string
+		want       time.Duration
+	}{
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{attempt: 4, want: 5 * time.Second},
+		{attempt: 1, retryAfter: "3", want: 3 * time.Second},
+		{attempt: 1, retryAfter: "60", want: 5 * time.Second},
+		{attempt: 3, retryAfter: "1", want: 4 * time.Second},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.retryAfter != "" {
+			resp.Header.Set("Retry-After", tc.retryAfter)
+		}
+		if got := p.backoff(tc.attempt, resp); got != tc.want {
+			t.Errorf("attempt %d, retry-after %q: got %v, want %v", tc.attempt, tc.retryAfter, got, tc.want)
+		}
+	}
+}
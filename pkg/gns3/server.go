@@ -17,6 +17,7 @@ limitations under the License.
 package gns3
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -43,7 +44,11 @@ func (g *Gns3) Server() *Server {
 }
 
 func (s *Server) Shutdown() error {
-	return s.gns3.Post(ServerShutdownPath, "", nil, nil)
+	return s.ShutdownContext(context.Background())
+}
+
+func (s *Server) ShutdownContext(ctx context.Context) error {
+	return s.gns3.PostContext(ctx, ServerShutdownPath, "", nil, nil)
 }
 
 func getProcessFromName(name string) (*process.Process, error) {
@@ -65,6 +70,10 @@ func getProcessFromName(name string) (*process.Process, error) {
 }
 
 func (s *Server) Start(configFile string) (*process.Process, bool, error) {
+	return s.StartContext(context.Background(), configFile)
+}
+
+func (s *Server) StartContext(ctx context.Context, configFile string) (*process.Process, bool, error) {
 	// If there is a gns3server process already, then don't attempt to start a new one
 	p, err := getProcessFromName("gns3server")
 	if err != nil {
@@ -83,7 +92,7 @@ func (s *Server) Start(configFile string) (*process.Process, bool, error) {
 	if configFile != "" {
 		cmd = append(cmd, "--config", configFile)
 	}
-	osCmd := exec.Command(cmd[0], cmd[1:]...)
+	osCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
 	osCmd.Stdout = devnull
 	osCmd.Stderr = devnull
 	osCmd.Stdin = devnull
@@ -100,8 +109,12 @@ func (s *Server) Start(configFile string) (*process.Process, bool, error) {
 }
 
 func (s *Server) Version() (*ServerVersion, error) {
+	return s.VersionContext(context.Background())
+}
+
+func (s *Server) VersionContext(ctx context.Context) (*ServerVersion, error) {
 	var serverVersion ServerVersion
-	err := s.gns3.Get(ServerVersionPath, &serverVersion)
+	err := s.gns3.GetContext(ctx, ServerVersionPath, &serverVersion)
 	if err == nil {
 		return &serverVersion, nil
 	}
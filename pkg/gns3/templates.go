@@ -17,6 +17,7 @@ limitations under the License.
 package gns3
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -93,8 +94,12 @@ func (g *Gns3) Templates() *Templates {
 }
 
 func (t *Templates) List() ([]Template, error) {
+	return t.ListContext(context.Background())
+}
+
+func (t *Templates) ListContext(ctx context.Context) ([]Template, error) {
 	var list []Template
-	err := t.gns3.Get(TemplatesPath, &list)
+	err := t.gns3.GetContext(ctx, TemplatesPath, &list)
 	if err != nil {
 		return nil, err
 	}
@@ -102,18 +107,22 @@ func (t *Templates) List() ([]Template, error) {
 }
 
 func (t *Templates) Get(id string) (*Template, error) {
+	return t.GetContext(context.Background(), id)
+}
+
+func (t *Templates) GetContext(ctx context.Context, id string) (*Template, error) {
 	var template Template
 
 	_, err := uuid.Parse(id)
 	if err == nil {
 		// Think this may be a UUID, so try to delete directly
-		err = t.gns3.Get(fmt.Sprintf("%s/%s", TemplatesPath, id), &template)
+		err = t.gns3.GetContext(ctx, fmt.Sprintf("%s/%s", TemplatesPath, id), &template)
 		if err == nil {
 			return &template, nil
 		}
 	}
 	// not a UUID, so get a list of templates and search based on name
-	list, err := t.List()
+	list, err := t.ListContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -126,16 +135,24 @@ func (t *Templates) Get(id string) (*Template, error) {
 }
 
 func (t *Templates) Close(id string) (string, error) {
-	template, err := t.Get(id)
+	return t.CloseContext(context.Background(), id)
+}
+
+func (t *Templates) CloseContext(ctx context.Context, id string) (string, error) {
+	template, err := t.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return template.TemplateId, t.gns3.Post(fmt.Sprintf("%s/%s/close", TemplatesPath, id), "", nil, nil)
+	return template.TemplateId, t.gns3.PostContext(Idempotent(ctx), fmt.Sprintf("%s/%s/close", TemplatesPath, id), "", nil, nil)
 }
 
 func (t *Templates) Create(template *Template) (*Template, error) {
+	return t.CreateContext(context.Background(), template)
+}
+
+func (t *Templates) CreateContext(ctx context.Context, template *Template) (*Template, error) {
 	var out Template
-	err := t.gns3.Post(TemplatesPath, "application/json", template, &out)
+	err := t.gns3.PostContext(ctx, TemplatesPath, "application/json", template, &out)
 	if err != nil {
 		return nil, err
 	}
@@ -144,11 +161,15 @@ func (t *Templates) Create(template *Template) (*Template, error) {
 }
 
 func (t *Templates) Delete(id string) (string, error) {
-	template, err := t.Get(id)
+	return t.DeleteContext(context.Background(), id)
+}
+
+func (t *Templates) DeleteContext(ctx context.Context, id string) (string, error) {
+	template, err := t.GetContext(ctx, id)
 	if err != nil {
 		return "", err
 	}
-	return template.TemplateId, t.gns3.Delete(fmt.Sprintf("%s/%s", TemplatesPath, template.TemplateId))
+	return template.TemplateId, t.gns3.DeleteContext(ctx, fmt.Sprintf("%s/%s", TemplatesPath, template.TemplateId))
 }
 
 func (t Template) MarshalJSON() ([]byte, error) {
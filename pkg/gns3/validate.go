@@ -0,0 +1,371 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	NetworkApiVersion = "ciena.io/v1"
+	NetworkKind       = "Network"
+)
+
+// NetworkError is a problem found in a network document. Line and Column
+// are 1 based and are 0 when the location is not known.
+type NetworkError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *NetworkError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return e.Message
+}
+
+func (e *NetworkError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// NetworkErrors is the list of problems found in a network document.
+type NetworkErrors []*NetworkError
+
+func (e NetworkErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e NetworkErrors) Unwrap() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[0]
+}
+
+var yamlLineRE = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// yamlErrors converts the errors from the YAML decoder to NetworkErrors.
+func yamlErrors(err error) NetworkErrors {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+	errs := make(NetworkErrors, 0, len(msgs))
+	for _, msg := range msgs {
+		e := &NetworkError{Message: strings.TrimPrefix(msg, "yaml: ")}
+		if m := yamlLineRE.FindStringSubmatch(msg); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Message = m[2]
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// ParseNetwork reads a single YAML network document, rejecting unknown keys,
+// and validates it. Any problems are returned as NetworkErrors.
+func ParseNetwork(r io.Reader) (*Network, error) {
+	networks, err := ParseNetworks(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(networks) != 1 {
+		return nil, NetworkErrors{{Message: fmt.Sprintf("expected a single network document, found %d", len(networks))}}
+	}
+	return networks[0], nil
+}
+
+// ParseNetworks reads a stream of `---` separated YAML network documents,
+// rejecting unknown keys, and expands and validates each of them. Empty documents are
+// skipped. The problems found in all of the documents are returned as
+// NetworkErrors, with lines relative to the start of the stream.
+func ParseNetworks(r io.Reader) ([]*Network, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The documents are decoded twice in step, once to find the location
+	// of values and once strictly into the Network type
+	nodes := yaml.NewDecoder(bytes.NewReader(data))
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var networks []*Network
+	var errs NetworkErrors
+	for {
+		var root yaml.Node
+		if err := nodes.Decode(&root); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, append(errs, yamlErrors(err)...)
+		}
+
+		var network Network
+		if err := decoder.Decode(&network); err != nil {
+			errs = append(errs, yamlErrors(err)...)
+			continue
+		}
+		if len(root.Content) == 0 || root.Content[0].Tag == "!!null" {
+			continue
+		}
+		if verrs := network.expand(&root); len(verrs) > 0 {
+			errs = append(errs, verrs...)
+			continue
+		}
+		if verrs := network.validate(&root); len(verrs) > 0 {
+			errs = append(errs, verrs...)
+			continue
+		}
+		networks = append(networks, &network)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(networks) == 0 {
+		return nil, NetworkErrors{{Message: "empty network document"}}
+	}
+	return networks, nil
+}
+
+// Validate checks a network for problems that would prevent it from being
+// loaded.
+func (n *Network) Validate() error {
+	if errs := n.validate(nil); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// yamlPath returns the node found by following path from root, where each
+// element is a mapping key or a sequence index, or nil if there is none.
+func yamlPath(root *yaml.Node, path ...interface{}) *yaml.Node {
+	n := root
+	if n != nil && n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	for _, p := range path {
+		if n == nil {
+			return nil
+		}
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == key {
+						next = n.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && key < len(n.Content) {
+				next = n.Content[key]
+			}
+		}
+		n = next
+	}
+	return n
+}
+
+// networkReporter collects the problems found in a network document,
+// locating each by its path in the parsed YAML.
+type networkReporter struct {
+	root *yaml.Node
+	errs NetworkErrors
+}
+
+func (r *networkReporter) report(msg string, path ...interface{}) {
+	e := &NetworkError{Message: msg}
+	// fall back to the closest enclosing node that has a location
+	for i := len(path); i >= 0; i-- {
+		if at := yamlPath(r.root, path[:i]...); at != nil {
+			e.Line, e.Column = at.Line, at.Column
+			break
+		}
+	}
+	r.errs = append(r.errs, e)
+}
+
+// firstAt describes where an earlier occurrence was, if it is known.
+func (r *networkReporter) firstAt(what string, path ...interface{}) string {
+	if prev := yamlPath(r.root, path...); prev != nil {
+		return fmt.Sprintf(", %s on line %d", what, prev.Line)
+	}
+	return ""
+}
+
+// subPath returns a copy of path extended by more.
+func subPath(path []interface{}, more ...interface{}) []interface{} {
+	sub := make([]interface{}, 0, len(path)+len(more))
+	return append(append(sub, path...), more...)
+}
+
+func (n *Network) validate(root *yaml.Node) NetworkErrors {
+	r := &networkReporter{root: root}
+
+	if n.ApiVersion != NetworkApiVersion {
+		r.report(fmt.Sprintf("unsupported apiVersion '%s', expected '%s'", n.ApiVersion, NetworkApiVersion), "apiVersion")
+	}
+	if n.Kind != NetworkKind {
+		r.report(fmt.Sprintf("unsupported kind '%s', expected '%s'", n.Kind, NetworkKind), "kind")
+	}
+	if n.Metadata.Name == "" {
+		r.report("metadata.name is required", "metadata", "name")
+	}
+	if n.Spec.Layout != "" {
+		if err := checkLayout(n.Spec.Layout); err != nil {
+			r.report(err.(*NetworkError).Message, "spec", "layout")
+		}
+	}
+
+	nodes := map[string][]interface{}{}
+	for i, node := range n.Spec.Nodes {
+		at := node.yamlPath(i)
+		switch {
+		case node.Name == "":
+			r.report("node name is required", at...)
+		default:
+			if first, ok := nodes[node.Name]; ok {
+				r.report(fmt.Sprintf("duplicate node name '%s'%s", node.Name, r.firstAt("first declared", subPath(first, "name")...)),
+					subPath(at, "name")...)
+				continue
+			}
+			nodes[node.Name] = at
+		}
+		if node.Type == "" && node.Template == "" {
+			r.report(fmt.Sprintf("node '%s' must specify a type or a template", node.Name), at...)
+		}
+		if node.Readiness != nil {
+			if key, msg := node.Readiness.check(); msg != "" {
+				r.report(fmt.Sprintf("node '%s' %s", node.Name, msg), subPath(at, "readiness", key)...)
+			} else if node.Readiness.Console != "" && node.ConsoleType != "" && node.ConsoleType != "telnet" {
+				r.report(fmt.Sprintf("node '%s' readiness.console requires a telnet console, not %s", node.Name, node.ConsoleType),
+					subPath(at, "readiness", "console")...)
+			}
+		}
+		if node.CloudInit != nil {
+			// the type of a node made from a template is not known until it
+			// is created, but cloud-init is only given to qemu nodes
+			nodeType := node.Type
+			if nodeType == "" {
+				nodeType = TemplateTypeQemu
+			}
+			switch key, msg := node.CloudInit.check(); {
+			case msg != "":
+				r.report(fmt.Sprintf("node '%s' %s", node.Name, msg), subPath(at, "cloudInit", key)...)
+			case node.Type != "" && !strings.EqualFold(node.Type, TemplateTypeQemu):
+				r.report(fmt.Sprintf("node '%s' cloudInit requires a qemu node, not %s", node.Name, node.Type),
+					subPath(at, "cloudInit")...)
+			case node.Config != nil && node.Config.MethodFor(nodeType) == ConfigISO:
+				r.report(fmt.Sprintf("node '%s' cloudInit and a config delivered by the iso method both need the CD-ROM", node.Name),
+					subPath(at, "cloudInit")...)
+			}
+		}
+		if node.Config != nil {
+			if key, msg := node.Config.check(); msg != "" {
+				where := subPath(at, "config")
+				if key != "" {
+					where = subPath(where, key)
+				}
+				r.report(fmt.Sprintf("node '%s' %s", node.Name, msg), where...)
+			}
+		}
+	}
+	for i, node := range n.Spec.Nodes {
+		for j, dep := range node.DependsOn {
+			if _, ok := nodes[dep]; !ok {
+				r.report(fmt.Sprintf("node '%s' depends on undeclared node '%s'", node.Name, dep),
+					subPath(node.yamlPath(i), "dependsOn", j)...)
+			}
+		}
+	}
+	if len(r.errs) == 0 {
+		if _, err := n.StartOrder(); err != nil {
+			r.report(err.(*NetworkError).Message, "spec", "nodes")
+		}
+	}
+
+	type portRef struct {
+		name          string
+		adapter, port int
+		iface         string
+	}
+	ports := map[portRef][]interface{}{}
+	for i, link := range n.Spec.Links {
+		at := link.yamlPath(i)
+		for _, end := range []struct {
+			key string
+			end LinkEnd
+		}{{"aEnd", link.AEnd}, {"zEnd", link.ZEnd}} {
+			if end.end.Name == "" {
+				r.report(fmt.Sprintf("%s name is required", end.key), subPath(at, end.key)...)
+				continue
+			}
+			if _, ok := nodes[end.end.Name]; !ok {
+				r.report(fmt.Sprintf("%s references undeclared node '%s'", end.key, end.end.Name), subPath(at, end.key, "name")...)
+				continue
+			}
+			if end.end.Fixed() && end.end.Interface != "" {
+				r.report(fmt.Sprintf("%s must give either an interface or an adapter and port", end.key), subPath(at, end.key)...)
+				continue
+			}
+			ref := portRef{name: end.end.Name, iface: strings.ToLower(end.end.Interface)}
+			port := end.end.Interface
+			if end.end.Fixed() {
+				ref.adapter, ref.port = end.end.Numbers()
+				port = fmt.Sprintf("%d/%d", ref.adapter, ref.port)
+				if ref.adapter < 0 || ref.port < 0 {
+					r.report(fmt.Sprintf("%s adapter and port must not be negative", end.key), subPath(at, end.key)...)
+					continue
+				}
+			} else if ref.iface == "" {
+				// the port is picked when the link is created
+				continue
+			}
+			if first, ok := ports[ref]; ok {
+				r.report(fmt.Sprintf("port %s of node '%s' is already in use%s", port, ref.name,
+					r.firstAt("first used by the link", first...)), subPath(at, end.key)...)
+				continue
+			}
+			ports[ref] = at
+		}
+	}
+	return r.errs
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ciena/gns3ctl/pkg/gns3"
+)
+
+const validNetwork = `apiVersion: ciena.io/v1
+kind: Network
+metadata:
+  name: lab
+spec:
+  nodes:
+    - name: sw1
+      type: ethernet_switch
+    - name: pc1
+      type: vpcs
+  links:
+    - aEnd: {name: pc1, interface: Ethernet0}
+      zEnd: {name: sw1, adapter: 0, port: 1}
+`
+
+func TestValidateNetwork(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		old, new     string
+		want         string
+		line, column int
+	}{
+		{
+			name: "unknown key",
+			old:  "      type: vpcs\n",
+			new:  "      type: vpcs\n      colour: red\n",
+			want: "field colour not found in type gns3.NetworkNode",
+			line: 11,
+		},
+		{
+			name: "apiVersion",
+			old:  "ciena.io/v1",
+			new:  "ciena.io/v2",
+			want: "unsupported apiVersion 'ciena.io/v2', expected 'ciena.io/v1'",
+			line: 1, column: 13,
+		},
+		{
+			name: "kind",
+			old:  "kind: Network",
+			new:  "kind: Router",
+			want: "unsupported kind 'Router', expected 'Network'",
+			line: 2, column: 7,
+		},
+		{
+			name: "duplicate node name",
+			old:  "name: pc1\n",
+			new:  "name: sw1\n",
+			want: "duplicate node name 'sw1', first declared on line 7",
+			line: 9, column: 13,
+		},
+		{
+			name: "undeclared link end",
+			old:  "{name: pc1,",
+			new:  "{name: pc2,",
+			want: "aEnd references undeclared node 'pc2'",
+			line: 12, column: 20,
+		},
+		{
+			name: "port reuse",
+			old:  "port: 1}\n",
+			new:  "port: 1}\n    - aEnd: {name: sw1, adapter: 0, port: 1}\n      zEnd: {name: pc1, interface: ethernet0}\n",
+			want: "port 0/1 of node 'sw1' is already in use, first used by the link on line 12",
+			line: 14, column: 13,
+		},
+	} {
+		doc := strings.Replace(validNetwork, tc.old, tc.new, 1)
+		_, err := gns3.ParseNetwork(strings.NewReader(doc))
+		var errs gns3.NetworkErrors
+		if !errors.As(err, &errs) || len(errs) == 0 {
+			t.Errorf("%s: got %v, want NetworkErrors", tc.name, err)
+			continue
+		}
+		if got := errs[0]; got.Message != tc.want || got.Line != tc.line || got.Column != tc.column {
+			t.Errorf("%s: got %q at %d:%d, want %q at %d:%d",
+				tc.name, got.Message, got.Line, got.Column, tc.want, tc.line, tc.column)
+		}
+	}
+
+	if _, err := gns3.ParseNetwork(strings.NewReader(validNetwork)); err != nil {
+		t.Errorf("valid network: %v", err)
+	}
+}
+
+func TestValidateExampleNetwork(t *testing.T) {
+	f, err := os.Open("../../example-network.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := gns3.ParseNetworks(f); err != nil {
+		t.Errorf("example-network.yaml: %v", err)
+	}
+}
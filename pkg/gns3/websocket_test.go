@@ -0,0 +1,316 @@
+/*
+Copyright 2022 Ciena Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gns3
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serverFrame encodes a frame as sent by a server, masked only if mask is
+// not nil.
+func serverFrame(fin bool, opcode byte, payload []byte, mask []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	maskBit := byte(0)
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	if mask == nil {
+		return append(frame, payload...)
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// wsServer starts a server that completes the websocket handshake and then
+// runs script, with peer reading the frames sent by the client, and returns
+// a client connected to it. The test waits for script to return.
+func wsServer(t *testing.T, script func(peer *wsConn, w io.Writer)) *Gns3 {
+	t.Helper()
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			t.Errorf("invalid upgrade request: %v", r.Header)
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\n\r\n", WebsocketAccept(r.Header.Get("Sec-WebSocket-Key")))
+		if err := rw.Flush(); err != nil {
+			t.Errorf("flush: %v", err)
+			return
+		}
+		script(&wsConn{rwc: conn, r: rw.Reader}, conn)
+	}))
+	t.Cleanup(func() {
+		<-done
+		s.Close()
+	})
+	g, err := Connect(Options{BaseURL: s.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func dial(t *testing.T, g *Gns3) *wsConn {
+	t.Helper()
+	c, err := g.dialWebsocket(context.Background(), "v2/notifications/ws")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { c.rwc.Close() })
+	return c
+}
+
+func TestWebsocketAccept(t *testing.T) {
+	// the example from RFC 6455 section 1.3
+	if got := WebsocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestWebsocketFrameLengths(t *testing.T) {
+	sizes := []int{0, 5, 125, 126, 200, 0xffff, 0x10000, 70000}
+	g := wsServer(t, func(peer *wsConn, w io.Writer) {
+		for _, size := range sizes {
+			if _, err := w.Write(serverFrame(true, wsBinary, bytes.Repeat([]byte{byte(size)}, size), nil)); err != nil {
+				t.Errorf("write: %v", err)
+				return
+			}
+		}
+		// and the client's frames of the same sizes, which are masked
+		for _, size := range sizes {
+			fin, opcode, payload, err := peer.readFrame()
+			if err != nil || !fin || opcode != wsText || !bytes.Equal(payload, bytes.Repeat([]byte{'x'}, size)) {
+				t.Errorf("client frame of %d bytes: got %v, %d, %d bytes, %v", size, fin, opcode, len(payload), err)
+				return
+			}
+		}
+	})
+	c := dial(t, g)
+	for _, size := range sizes {
+		message, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !bytes.Equal(message, bytes.Repeat([]byte{byte(size)}, size)) {
+			t.Errorf("%d bytes: got %d bytes", size, len(message))
+		}
+	}
+	for _, size := range sizes {
+		if err := c.writeFrame(wsText, bytes.Repeat([]byte{'x'}, size)); err != nil {
+			t.Fatalf("write %d bytes: %v", size, err)
+		}
+	}
+}
+
+func TestWebsocketClientFramesMasked(t *testing.T) {
+	g := wsServer(t, func(peer *wsConn, w io.Writer) {
+		var header [2]byte
+		if _, err := io.ReadFull(peer.r, header[:]); err != nil {
+			t.Errorf("read: %v", err)
+			return
+		}
+		if header[1]&0x80 == 0 {
+			t.Errorf("client frame is not masked")
+		}
+		var rest [4 + 5]byte
+		if _, err := io.ReadFull(peer.r, rest[:]); err != nil {
+			t.Errorf("read: %v", err)
+			return
+		}
+		if bytes.Equal(rest[4:], []byte("hello")) && !bytes.Equal(rest[:4], []byte{0, 0, 0, 0}) {
+			t.Errorf("payload was sent in the clear")
+		}
+	})
+	c := dial(t, g)
+	if err := c.writeFrame(wsText, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebsocketMaskedServerFrame(t *testing.T) {
+	g := wsServer(t, func(peer *wsConn, w io.Writer) {
+		_, _ = w.Write(serverFrame(true, wsText, []byte(`{"action": "ping"}`), []byte{1, 2, 3, 4}))
+	})
+	message, err := dial(t, g).ReadMessage()
+	if err != nil || string(message) != `{"action": "ping"}` {
+		t.Errorf("got %q, %v", message, err)
+	}
+}
+
+func TestWebsocketControlBetweenFragments(t *testing.T) {
+	g := wsServer(t, func(peer *wsConn, w io.Writer) {
+		_, _ = w.Write(serverFrame(false, wsText, []byte("hel"), nil))
+		_, _ = w.Write(serverFrame(true, wsPing, []byte("are you there"), nil))
+		_, _ = w.Write(serverFrame(true, wsPong, []byte("unsolicited"), nil))
+		_, _ = w.Write(serverFrame(false, wsContinuation, []byte("lo "), nil))
+		_, _ = w.Write(serverFrame(true, wsContinuation, []byte("world"), nil))
+		fin, opcode, payload, err := peer.readFrame()
+		if err != nil || !fin || opcode != wsPong || string(payload) != "are you there" {
+			t.Errorf("pong: got %v, %d, %q, %v", fin, opcode, payload, err)
+		}
+	})
+	message, err := dial(t, g).ReadMessage()
+	if err != nil || string(message) != "hello world" {
+		t.Errorf("got %q, %v", message, err)
+	}
+}
+
+func TestWebsocketCloseHandshake(t *testing.T) {
+	g := wsServer(t, func(peer *wsConn, w io.Writer) {
+		_, _ = w.Write(serverFrame(true, wsText, []byte("last"), nil))
+		_, _ = w.Write(serverFrame(true, wsClose, []byte{0x03, 0xe8}, nil))
+		fin, opcode, payload, err := peer.readFrame()
+		if err != nil || !fin || opcode != wsClose || !bytes.Equal(payload, []byte{0x03, 0xe8}) {
+			t.Errorf("close: got %v, %d, %v, %v", fin, opcode, payload, err)
+		}
+	})
+	c := dial(t, g)
+	if message, err := c.ReadMessage(); err != nil || string(message) != "last" {
+		t.Fatalf("got %q, %v", message, err)
+	}
+	if _, err := c.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+}
+
+func TestWebsocketClientClose(t *testing.T) {
+	g := wsServer(t, func(peer *wsConn, w io.Writer) {
+		fin, opcode, _, err := peer.readFrame()
+		if err != nil || !fin || opcode != wsClose {
+			t.Errorf("close: got %v, %d, %v", fin, opcode, err)
+		}
+	})
+	if err := dial(t, g).Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWebsocketProtocolErrors(t *testing.T) {
+	oversized := []byte{0x80 | wsText, 127, 0, 0, 0, 0, 0x10, 0, 0, 0}
+	for _, tc := range []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"continuation without start", [][]byte{serverFrame(true, wsContinuation, []byte("x"), nil)}},
+		{"text during fragments", [][]byte{
+			serverFrame(false, wsText, []byte("x"), nil),
+			serverFrame(true, wsText, []byte("y"), nil),
+		}},
+		{"fragmented ping", [][]byte{serverFrame(false, wsPing, nil, nil)}},
+		{"long ping", [][]byte{serverFrame(true, wsPing, bytes.Repeat([]byte{'x'}, 126), nil)}},
+		{"unknown opcode", [][]byte{serverFrame(true, 0x3, nil, nil)}},
+		{"oversized frame", [][]byte{oversized}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := wsServer(t, func(peer *wsConn, w io.Writer) {
+				for _, frame := range tc.frames {
+					_, _ = w.Write(frame)
+				}
+				// wait for the client to give up
+				_, _ = io.Copy(io.Discard, peer.r)
+			})
+			c := dial(t, g)
+			_, err := c.ReadMessage()
+			if !errors.Is(err, ErrWebsocket) {
+				t.Errorf("got %v, want ErrWebsocket", err)
+			}
+			c.rwc.Close()
+		})
+	}
+}
+
+func TestWebsocketHandshakeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+	}{
+		{"unauthorized", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}, ErrUnauthorized},
+		{"not upgraded", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}, nil},
+		{"wrong accept", func(w http.ResponseWriter, r *http.Request) {
+			conn, rw, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+				"Sec-WebSocket-Accept: %s\r\n\r\n", WebsocketAccept("wrong"))
+			_ = rw.Flush()
+		}, ErrWebsocket},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(tc.handler)
+			defer s.Close()
+			g, err := Connect(Options{BaseURL: s.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = g.dialWebsocket(context.Background(), "v2/notifications/ws")
+			if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+				t.Errorf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebsocketContextCloses(t *testing.T) {
+	g := wsServer(t, func(peer *wsConn, w io.Writer) {
+		_, _ = io.Copy(io.Discard, peer.r)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := g.dialWebsocket(ctx, "v2/notifications/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := c.ReadMessage(); err == nil {
+		t.Error("read succeeded after the context was canceled")
+	}
+}